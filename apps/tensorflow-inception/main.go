@@ -5,6 +5,7 @@ import (
 	"log"
 	"strings"
 
+	"github.com/sdeoras/token/proto"
 	"github.com/sirupsen/logrus"
 )
 
@@ -16,6 +17,9 @@ var (
 	jobID      *string
 	batchSize  *int
 	numBatches *int
+	shard      *int
+	numShards  *int
+	dial       *proto.DialConfig
 )
 
 func main() {
@@ -27,12 +31,19 @@ func main() {
 	jobID = flag.String("job-id", "default", "job id")
 	batchSize = flag.Int("batch-size", 100, "batch size")
 	numBatches = flag.Int("num-batches", 25, "number of batches to run")
+	shard = flag.Int("shard", 0, "shard of this worker when server runs in sharding mode")
+	numShards = flag.Int("num-shards", 0, "number of shards server runs with (0 for dynamic dispatch)")
+	dial = proto.DialFlags(flag.CommandLine)
 	flag.Parse()
 
 	if !strings.Contains(*host, ":") {
 		logrus.Fatal("--host requires a port number")
 	}
 
+	if *numShards < 0 || (*numShards > 0 && (*shard < 0 || *shard >= *numShards)) {
+		logrus.Fatal("--shard has to be in [0, --num-shards)")
+	}
+
 	if *useNoHost {
 		if err := runWithoutScheduler(); err != nil {
 			log.Fatal(err)
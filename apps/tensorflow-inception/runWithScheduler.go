@@ -15,9 +15,9 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/sdeoras/token/proto"
+	"github.com/sdeoras/token/worker"
 	"github.com/sirupsen/logrus"
 	tf "github.com/tensorflow/tensorflow/tensorflow/go"
-	"google.golang.org/grpc"
 )
 
 func runWithScheduler() error {
@@ -48,8 +48,7 @@ func runWithScheduler() error {
 
 	// grpc dialing
 	logrus.Info("dialing grpc server: ", *host)
-	ctx := context.Background()
-	conn, err := grpc.Dial(*host, grpc.WithInsecure())
+	conn, err := dial.Dial(*host)
 	if err != nil {
 		return err
 	}
@@ -57,124 +56,57 @@ func runWithScheduler() error {
 	client := proto.NewTokensClient(conn)
 	logrus.Info("connected to grpc server: ", *host)
 
-	// loop over number of batches
-	for i := 0; i < *numBatches; i++ {
-
-		// request tokens from server
-		logrus.Info("requested tokens: ", *batchSize)
-		tokens, err := client.Get(ctx, &proto.JobID{ID: *jobID, BatchSize: int32(*batchSize)})
-		if err != nil {
-			return err
-		}
-		if len(tokens.Tokens) == 0 {
-			logrus.Info("received tokens: ", len(tokens.Tokens), ", exiting")
-			break
-		} else {
-			logrus.Info("received tokens: ", len(tokens.Tokens))
-		}
-
-		// start heartbeat-ing
-		heartBeat := proto.NewHeartBeat(client, *jobID, tokens.Key)
-		heartBeat.Start()
-
-		// loop over tokens
-		logrus.Info("computing")
-		var b0 bytes.Buffer
-		bw0 := bufio.NewWriter(&b0)
-		t := time.Now()
-		for _, token := range tokens.Tokens {
-			tLoop := time.Now()
-
-			fileName := filepath.Join(dirName, token)
-			image, err := ioutil.ReadFile(fileName)
-			if err != nil {
-				logrus.Error("error on file read: ", err, ", ", fileName)
-				continue
-			}
-			fileSize := uint64(len(image))
-			fileIOTime := time.Since(tLoop)
-			tLoop = time.Now()
-
-			tensor, err := makeTensorFromImage(bytes.NewBuffer(image), "jpg")
-			if err != nil {
-				logrus.Error("error on making tensor from image: ", err, ", ", fileName)
-				continue
-			}
-
-			output, err := session.Run(
-				map[tf.Output]*tf.Tensor{
-					graph.Operation("input").Output(0): tensor,
-				},
-				[]tf.Output{
-					graph.Operation("output").Output(0),
-				},
-				nil)
-			if err != nil {
-				logrus.Error("error in running session:", err, ", ", fileName)
-				continue
-			}
-			computeTime := time.Since(tLoop)
-
-			jb, err := json.Marshal(findBestLabels(token, output[0].Value().([][]float32)[0], fileSize, fileIOTime, computeTime))
-			if err != nil {
-				logrus.Error("error in json marshaling:", err, ", ", fileName)
-				continue
-			}
-
-			if nn, err := bw0.Write(jb); err != nil {
-				logrus.Error("error in writing to bw0:", err, ", ", fileName)
-				continue
-			} else {
-				if nn != len(jb) {
-					logrus.Error("error in writing to bw0:", err, ", ", fileName)
+	// only results the server accepted are written out
+	w := worker.New(client, *jobID,
+		worker.WithBackoff(dial.Backoff),
+		worker.WithBatchSize(*batchSize),
+		worker.WithNumBatches(*numBatches),
+		worker.WithShard(*shard, *numShards),
+		worker.WithCommitHandler(func(records []*proto.Record) {
+			for _, record := range records {
+				if len(record.Data) == 0 {
 					continue
 				}
-			}
-
-			bb := []byte("\n")
-			if nn, err := bw0.Write(bb); err != nil {
-				logrus.Error("could not write to bytes buffer", err)
-				continue
-			} else {
-				if nn != len(bb) {
+				if _, err := bw.Write(append(record.Data, '\n')); err != nil {
 					logrus.Error("could not write to bytes buffer", err)
-					continue
 				}
 			}
+		}))
 
-			// check server's response to client's heartbeat
-			if err := heartBeat.Check(); err != nil {
-				heartBeat.Close()
-				return err
-			}
+	// loop over tokens
+	if err := w.Run(worker.SignalContext(), func(ctx context.Context, token string) ([]byte, error) {
+		tLoop := time.Now()
 
+		fileName := filepath.Join(dirName, token)
+		image, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return nil, err
 		}
-		logrus.Info("client looping over tokens took: ", time.Since(t), ", for jobID: ", *jobID, ", key: ", tokens.Key)
-
-		// stop heartbeat-ing
-		heartBeat.Close()
+		fileSize := uint64(len(image))
+		fileIOTime := time.Since(tLoop)
+		tLoop = time.Now()
 
-		// send done confirmation to server
-		if ack, err := client.Done(ctx, &proto.JobID{Key: tokens.Key, ID: *jobID}); err != nil {
-			return err
-		} else {
-			if ack.Status {
-				if err := bw0.Flush(); err != nil {
-					return err
-				}
+		tensor, err := makeTensorFromImage(bytes.NewBuffer(image), "jpg")
+		if err != nil {
+			return nil, err
+		}
 
-				bb := b0.Bytes()
-				if nn, err := bw.Write(bb); err != nil {
-					logrus.Error("could not write to bytes buffer", err)
-					continue
-				} else {
-					if nn != len(bb) {
-						logrus.Error("could not write to bytes buffer", err)
-						continue
-					}
-				}
-			}
+		output, err := session.Run(
+			map[tf.Output]*tf.Tensor{
+				graph.Operation("input").Output(0): tensor,
+			},
+			[]tf.Output{
+				graph.Operation("output").Output(0),
+			},
+			nil)
+		if err != nil {
+			return nil, err
 		}
+		computeTime := time.Since(tLoop)
+
+		return json.Marshal(findBestLabels(token, output[0].Value().([][]float32)[0], fileSize, fileIOTime, computeTime))
+	}); err != nil {
+		return err
 	}
 
 	// output
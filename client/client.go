@@ -15,12 +15,10 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/sdeoras/token/proto"
+	"github.com/sdeoras/token/worker"
 	"github.com/sirupsen/logrus"
-	"google.golang.org/grpc"
 )
 
-var key string
-
 func main() {
 	t := time.Now()
 	var b bytes.Buffer
@@ -33,6 +31,12 @@ func main() {
 	batchSize := flag.Int("batch-size", 100, "batch size")
 	numBatches := flag.Int("num-batches", 25, "number of batches to run")
 	computeDelay := flag.Int("compute-delay", 100, "simulate compute delay in ms")
+	shard := flag.Int("shard", 0, "shard of this worker when server runs in sharding mode")
+	numShards := flag.Int("num-shards", 0, "number of shards server runs with (0 for dynamic dispatch)")
+	concurrency := flag.Int("concurrency", 1, "number of goroutines processing tokens of a batch")
+	leases := flag.Int("leases", 1, "number of batches to hold and process at a time")
+	prefetch := flag.Bool("prefetch", false, "request the next batch while finishing the current one")
+	dial := proto.DialFlags(flag.CommandLine)
 	flag.Parse()
 
 	if !strings.Contains(*host, ":") {
@@ -56,10 +60,13 @@ func main() {
 		logrus.Fatal("--compute-delay has to be a positive integer")
 	}
 
+	if *numShards < 0 || (*numShards > 0 && (*shard < 0 || *shard >= *numShards)) {
+		logrus.Fatal("--shard has to be in [0, --num-shards)")
+	}
+
 	// dial GRPC server
 	logrus.Info("dialing grpc: ", *host)
-	ctx := context.Background()
-	conn, err := grpc.Dial(*host, grpc.WithInsecure())
+	conn, err := dial.Dial(*host)
 	if err != nil {
 		logrus.Fatal(err)
 	}
@@ -67,55 +74,26 @@ func main() {
 	client := proto.NewTokensClient(conn)
 	logrus.Info("connected to grpc server: ", *host)
 
-	// loop over number of batches
-	for i := 0; i < *numBatches; i++ {
-		// request tokens from server
-		logrus.Info("requesting job tokens: ", *batchSize)
-		tokens, err := client.Get(ctx, &proto.JobID{ID: *jobID, BatchSize: int32(*batchSize)})
-		if err != nil {
-			logrus.Fatal(err)
-		}
-		if len(tokens.Tokens) == 0 {
-			logrus.Info("received job tokens: ", len(tokens.Tokens), ", exiting")
-			break
-		} else {
-			logrus.Info("received job tokens: ", len(tokens.Tokens))
-		}
-
-		// start heartbeat-ing with server
-		heartBeat := proto.NewHeartBeat(client, *jobID, tokens.Key)
-		heartBeat.Start()
-
-		// simulate some compute time
-		t := time.Now()
-		for range tokens.Tokens {
-			time.Sleep(time.Millisecond * time.Duration(*computeDelay))
-
-			if err := heartBeat.Check(); err != nil {
-				heartBeat.Close()
-				logrus.Fatal(err)
+	w := worker.New(client, *jobID,
+		worker.WithBackoff(dial.Backoff),
+		worker.WithBatchSize(*batchSize),
+		worker.WithNumBatches(*numBatches),
+		worker.WithConcurrency(*concurrency),
+		worker.WithLeases(*leases),
+		worker.WithPrefetch(*prefetch),
+		worker.WithShard(*shard, *numShards),
+		worker.WithCommitHandler(func(records []*proto.Record) {
+			for _, record := range records {
+				fmt.Fprintln(bw, record.Token)
 			}
+		}))
 
-		}
-		logrus.Info("looping over tokens took: ", time.Since(t), ", for jobID: ", *jobID, ", key: ", tokens.Key)
-
-		// stop heartbeat-ing
-		heartBeat.Close()
-
-		// send done signal to server and request acknowledgement to write
-		logrus.Info("send done signal to server")
-		if ack, err := client.Done(ctx, &proto.JobID{Key: tokens.Key, ID: *jobID}); err != nil {
-			logrus.Fatal(err)
-		} else {
-			if ack.Status {
-				logrus.Info("received ok to write signal from server")
-				for _, token := range tokens.Tokens {
-					fmt.Fprintln(bw, token)
-				}
-			} else {
-				logrus.Info("received not-ok to write signal from server")
-			}
-		}
+	// simulate some compute time
+	if err := w.Run(worker.SignalContext(), func(ctx context.Context, token string) ([]byte, error) {
+		time.Sleep(time.Millisecond * time.Duration(*computeDelay))
+		return nil, nil
+	}); err != nil {
+		logrus.Fatal(err)
 	}
 
 	// write output
@@ -18,8 +18,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/sdeoras/token/proto"
+	"github.com/sdeoras/token/worker"
 	"github.com/sirupsen/logrus"
-	"google.golang.org/grpc"
 )
 
 type Results struct {
@@ -38,24 +38,39 @@ func main() {
 	useSystemCp := flag.Bool("use-system-cp", false, "use system cp command to copy")
 	jobID := flag.String("job-id", "default", "job id")
 	batchSize := flag.Int("batch-size", 100, "batch size")
+	byteBudget := flag.Int64("byte-budget", 0, "max bytes per batch, alone or with batch size (0 disables)")
 	numBatches := flag.Int("num-batches", 25, "number of batches to run")
+	shard := flag.Int("shard", 0, "shard of this worker when server runs in sharding mode")
+	numShards := flag.Int("num-shards", 0, "number of shards server runs with (0 for dynamic dispatch)")
+	concurrency := flag.Int("concurrency", 1, "number of goroutines processing tokens of a batch")
+	leases := flag.Int("leases", 1, "number of batches to hold and process at a time")
+	prefetch := flag.Bool("prefetch", false, "request the next batch while finishing the current one")
+	dial := proto.DialFlags(flag.CommandLine)
 	flag.Parse()
 
 	if !strings.Contains(*host, ":") {
 		logrus.Fatal("--host needs a port number")
 	}
 
+	if *batchSize <= 0 && *byteBudget <= 0 {
+		logrus.Fatal("--batch-size or --byte-budget has to be a positive integer")
+	}
+
+	if *numShards < 0 || (*numShards > 0 && (*shard < 0 || *shard >= *numShards)) {
+		logrus.Fatal("--shard has to be in [0, --num-shards)")
+	}
+
 	if *jobID == "default" {
 		*jobID = uuid.New().String()
 		logrus.Info("using job id:", *jobID)
 	}
 
 	logrus.Info("dialing grpc server: ", *host)
-	ctx := context.Background()
-	conn, err := grpc.Dial(*host, grpc.WithInsecure())
+	conn, err := dial.Dial(*host)
 	if err != nil {
 		logrus.Fatal(err)
 	}
+	defer conn.Close()
 	client := proto.NewTokensClient(conn)
 	logrus.Info("connected to grpc server: ", *host)
 
@@ -65,71 +80,55 @@ func main() {
 
 	var b bytes.Buffer
 	bw := bufio.NewWriter(&b)
-	// loop over number of batches
-	for i := 0; i < *numBatches; i++ {
-		// request tokens from server
-		logrus.Info("requesting job tokens: ", *batchSize)
-		tokens, err := client.Get(ctx, &proto.JobID{ID: *jobID, BatchSize: int32(*batchSize)})
-		if err != nil {
-			logrus.Fatal(err)
-		}
-		if len(tokens.Tokens) == 0 {
-			logrus.Info("received job tokens: ", len(tokens.Tokens), ", exiting")
-			break
-		} else {
-			logrus.Info("received job tokens: ", len(tokens.Tokens))
-		}
-
-		// start heartbeat-ing with server
-		heartBeat := proto.NewHeartBeat(client, *jobID, tokens.Key)
-		heartBeat.Start()
-
-		for _, token := range tokens.Tokens {
-			t := time.Now()
-
-			if *useSystemCp {
-				if _, err := exec.Command("/bin/cp",
-					filepath.Join(*sourceDir, token),
-					filepath.Join(*destinationDir, token)).Output(); err != nil {
-					logrus.Fatal(err)
-				}
-			} else {
-				image, err := ioutil.ReadFile(filepath.Join(*sourceDir, token))
-				if err != nil {
-					logrus.Fatal(err)
+	w := worker.New(client, *jobID,
+		worker.WithBackoff(dial.Backoff),
+		worker.WithBatchSize(*batchSize),
+		worker.WithByteBudget(*byteBudget),
+		worker.WithNumBatches(*numBatches),
+		worker.WithConcurrency(*concurrency),
+		worker.WithLeases(*leases),
+		worker.WithPrefetch(*prefetch),
+		worker.WithShard(*shard, *numShards),
+		worker.WithCommitHandler(func(records []*proto.Record) {
+			for _, record := range records {
+				if len(record.Data) > 0 {
+					fmt.Fprintln(bw, string(record.Data))
 				}
-				fileSize := uint64(len(image))
-				readTime := time.Since(t)
-				t = time.Now()
+			}
+		}))
 
-				if err = ioutil.WriteFile(filepath.Join(*destinationDir, token), image, 0666); err != nil {
-					logrus.Fatal(err)
-				}
-				writeTime := time.Since(t)
-				t = time.Now()
-
-				Out := new(Results)
-				Out.FileSize = fileSize
-				Out.Filename = token
-				Out.FileReadTime = readTime
-				Out.FileWriteTime = writeTime
-
-				jb, err := json.Marshal(Out)
-				if err != nil {
-					logrus.Fatal(err)
-				}
+	if err := w.Run(worker.SignalContext(), func(ctx context.Context, token string) ([]byte, error) {
+		t := time.Now()
 
-				fmt.Fprintln(bw, string(jb))
-			}
+		if *useSystemCp {
+			_, err := exec.Command("/bin/cp",
+				filepath.Join(*sourceDir, token),
+				filepath.Join(*destinationDir, token)).Output()
+			return nil, err
+		}
 
-			if err := heartBeat.Check(); err != nil {
-				heartBeat.Close()
-				logrus.Fatal(err)
-			}
+		image, err := ioutil.ReadFile(filepath.Join(*sourceDir, token))
+		if err != nil {
+			return nil, err
+		}
+		fileSize := uint64(len(image))
+		readTime := time.Since(t)
+		t = time.Now()
+
+		if err = ioutil.WriteFile(filepath.Join(*destinationDir, token), image, 0666); err != nil {
+			return nil, err
 		}
+		writeTime := time.Since(t)
 
-		// stop heartbeat-ing
-		heartBeat.Close()
+		Out := new(Results)
+		Out.FileSize = fileSize
+		Out.Filename = token
+		Out.FileReadTime = readTime
+		Out.FileWriteTime = writeTime
+
+		return json.Marshal(Out)
+	}); err != nil {
+		logrus.Fatal(err)
 	}
 
 	if err := bw.Flush(); err != nil {
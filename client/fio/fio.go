@@ -17,8 +17,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/sdeoras/token/proto"
+	"github.com/sdeoras/token/worker"
 	"github.com/sirupsen/logrus"
-	"google.golang.org/grpc"
 )
 
 type Results struct {
@@ -35,68 +35,63 @@ func main() {
 	sourceDir := flag.String("source-dir", "/mnt/gcp/fio", "source folder")
 	jobID := flag.String("job-id", "default", "job id")
 	batchSize := flag.Int("batch-size", 1, "batch size")
+	byteBudget := flag.Int64("byte-budget", 0, "max bytes per batch, alone or with batch size (0 disables)")
 	numBatches := flag.Int("num-batches", 1, "number of batches to run")
+	shard := flag.Int("shard", 0, "shard of this worker when server runs in sharding mode")
+	numShards := flag.Int("num-shards", 0, "number of shards server runs with (0 for dynamic dispatch)")
+	dial := proto.DialFlags(flag.CommandLine)
 	flag.Parse()
 
 	if !strings.Contains(*host, ":") {
 		logrus.Fatal("--host needs a port number")
 	}
 
+	if *batchSize <= 0 && *byteBudget <= 0 {
+		logrus.Fatal("--batch-size or --byte-budget has to be a positive integer")
+	}
+
+	if *numShards < 0 || (*numShards > 0 && (*shard < 0 || *shard >= *numShards)) {
+		logrus.Fatal("--shard has to be in [0, --num-shards)")
+	}
+
 	if *jobID == "default" {
 		*jobID = uuid.New().String()
 		logrus.Info("using job id:", *jobID)
 	}
 
 	logrus.Info("dialing grpc server: ", *host)
-	ctx := context.Background()
-	conn, err := grpc.Dial(*host, grpc.WithInsecure())
+	conn, err := dial.Dial(*host)
 	if err != nil {
 		logrus.Fatal(err)
 	}
+	defer conn.Close()
 	client := proto.NewTokensClient(conn)
 	logrus.Info("connected to grpc server: ", *host)
 
 	var b bytes.Buffer
 	bw := bufio.NewWriter(&b)
-	// loop over number of batches
-	for i := 0; i < *numBatches; i++ {
-		// request tokens from server
-		logrus.Info("requesting job tokens: ", *batchSize)
-		tokens, err := client.Get(ctx, &proto.JobID{ID: *jobID, BatchSize: int32(*batchSize)})
-		if err != nil {
-			logrus.Fatal(err)
-		}
-		if len(tokens.Tokens) == 0 {
-			logrus.Info("received job tokens: ", len(tokens.Tokens), ", exiting")
-			break
-		} else {
-			logrus.Info("received job tokens: ", len(tokens.Tokens))
-		}
-
-		// start heartbeat-ing with server
-		heartBeat := proto.NewHeartBeat(client, *jobID, tokens.Key)
-		heartBeat.Start()
-
-		for _, token := range tokens.Tokens {
-
-			cmd := strings.Split("fio --blocksize=4k --filename="+filepath.Join(*sourceDir, token)+
-				" --ioengine=libaio --readwrite=read --size=10G --name=test --direct=0 --gtod_reduce=1"+
-				" --iodepth=32 --randrepeat=1 --disable_lat=0 --readonly", " ")
-
-			if out, err := exec.Command(cmd[0], cmd[1:]...).Output(); err != nil {
-				logrus.Fatal(err)
-			} else {
-				fmt.Fprintln(bw, string(out))
+	w := worker.New(client, *jobID,
+		worker.WithBackoff(dial.Backoff),
+		worker.WithBatchSize(*batchSize),
+		worker.WithByteBudget(*byteBudget),
+		worker.WithNumBatches(*numBatches),
+		worker.WithShard(*shard, *numShards),
+		worker.WithCommitHandler(func(records []*proto.Record) {
+			for _, record := range records {
+				if len(record.Data) > 0 {
+					fmt.Fprintln(bw, string(record.Data))
+				}
 			}
+		}))
 
-			if err := heartBeat.Check(); err != nil {
-				heartBeat.Close()
-				logrus.Fatal(err)
-			}
-		}
+	if err := w.Run(worker.SignalContext(), func(ctx context.Context, token string) ([]byte, error) {
+		cmd := strings.Split("fio --blocksize=4k --filename="+filepath.Join(*sourceDir, token)+
+			" --ioengine=libaio --readwrite=read --size=10G --name=test --direct=0 --gtod_reduce=1"+
+			" --iodepth=32 --randrepeat=1 --disable_lat=0 --readonly", " ")
 
-		// stop heartbeat-ing
-		heartBeat.Close()
+		return exec.Command(cmd[0], cmd[1:]...).Output()
+	}); err != nil {
+		logrus.Fatal(err)
 	}
 
 	if err := bw.Flush(); err != nil {
@@ -12,12 +12,12 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/sdeoras/token/proto"
 	"github.com/sirupsen/logrus"
-	"google.golang.org/grpc"
 )
 
 type Results struct {
@@ -35,6 +35,8 @@ func main() {
 	inputDir := flag.String("input-dir", "/tf/images", "input folder")
 	jobID := flag.String("job-id", "default", "job id")
 	batchSize := flag.Int("batch-size", 100, "batch size")
+	concurrency := flag.Int("concurrency", 1, "number of goroutines reading files")
+	dial := proto.DialFlags(flag.CommandLine)
 	flag.Parse()
 
 	if !strings.Contains(*host, ":") {
@@ -48,7 +50,7 @@ func main() {
 
 	logrus.Info("dialing grpc server: ", *host)
 	ctx := context.Background()
-	conn, err := grpc.Dial(*host, grpc.WithInsecure())
+	conn, err := dial.Dial(*host)
 	if err != nil {
 		logrus.Fatal(err)
 	}
@@ -59,52 +61,44 @@ func main() {
 	bw := bufio.NewWriter(&b)
 	for i := 0; i < 1; i++ {
 		logrus.Info("requesting tokens")
-		tokens, err := client.Show(ctx, &proto.Empty{})
+		var tokens []string
+		err := dial.Backoff.Retry(ctx, func(ctx context.Context) error {
+			var err error
+			tokens, err = proto.ListTokens(ctx, client, &proto.ListRequest{PageSize: int32(*batchSize)}, *batchSize)
+			return err
+		})
 		if err != nil {
 			logrus.Fatal(err)
 		}
-		logrus.Info("received tokens:", len(tokens.Tokens))
+		logrus.Info("received tokens:", len(tokens))
 
-		if len(tokens.Tokens) == 0 {
+		if len(tokens) == 0 {
 			break
 		}
 
 		logrus.Info("computing")
-		if len(tokens.Tokens) >= *batchSize {
-			tokens.Tokens = tokens.Tokens[:*batchSize]
+		logrus.Info("working on tokens: ", len(tokens))
+		var lock sync.Mutex
+		var wg sync.WaitGroup
+		work := make(chan string)
+		for j := 0; j < *concurrency; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for token := range work {
+					jb := measure(*inputDir, *refDir, token)
+
+					lock.Lock()
+					fmt.Fprintln(bw, string(jb))
+					lock.Unlock()
+				}
+			}()
 		}
-		logrus.Info("working on tokens: ", len(tokens.Tokens))
-		for _, token := range tokens.Tokens {
-			t := time.Now()
-
-			image, err := ioutil.ReadFile(filepath.Join(*inputDir, token))
-			if err != nil {
-				logrus.Fatal(err)
-			}
-			fileSize := uint64(len(image))
-			fileIOTimeInput := time.Since(t)
-			t = time.Now()
-
-			image, err = ioutil.ReadFile(filepath.Join(*refDir, token))
-			if err != nil {
-				logrus.Fatal(err)
-			}
-			fileIOTimeRef := time.Since(t)
-			t = time.Now()
-
-			Out := new(Results)
-			Out.FileSize = fileSize
-			Out.Filename = token
-			Out.FileIOTimeInput = fileIOTimeInput
-			Out.FileIOTimeRef = fileIOTimeRef
-
-			jb, err := json.Marshal(Out)
-			if err != nil {
-				logrus.Fatal(err)
-			}
-
-			fmt.Fprintln(bw, string(jb))
+		for _, token := range tokens {
+			work <- token
 		}
+		close(work)
+		wg.Wait()
 	}
 
 	if err := bw.Flush(); err != nil {
@@ -124,3 +118,34 @@ func main() {
 	logrus.Info("writing output: ", fileName)
 	logrus.Info("all done: ", time.Since(t0))
 }
+
+// measure reads token from input and reference folders and returns the timings
+func measure(inputDir, refDir, token string) []byte {
+	t := time.Now()
+
+	image, err := ioutil.ReadFile(filepath.Join(inputDir, token))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	fileSize := uint64(len(image))
+	fileIOTimeInput := time.Since(t)
+	t = time.Now()
+
+	image, err = ioutil.ReadFile(filepath.Join(refDir, token))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	fileIOTimeRef := time.Since(t)
+
+	Out := new(Results)
+	Out.FileSize = fileSize
+	Out.Filename = token
+	Out.FileIOTimeInput = fileIOTimeInput
+	Out.FileIOTimeRef = fileIOTimeRef
+
+	jb, err := json.Marshal(Out)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	return jb
+}
@@ -1,23 +1,39 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/sdeoras/token/proto"
 	"github.com/sirupsen/logrus"
-	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func main() {
 	t := time.Now()
 	host := flag.String("host", "0.0.0.0:7001", "host")
 	action := flag.String("action", "reset",
-		"action to perform: reset, rescan, shuffle, show")
+		"action to perform: reset, rescan, shuffle, show, results, progress, export, import, health, drain, resume")
+	jobID := flag.String("job-id", "", "job id for job specific actions")
+	state := flag.String("state", "", "show only tokens of --job-id in this state: pending, leased, completed or failed")
+	prefix := flag.String("prefix", "", "show only tokens whose name starts with this")
+	glob := flag.String("glob", "", "show only tokens whose name matches this glob")
+	count := flag.Bool("count", false, "show the number of tokens instead of their names")
+	file := flag.String("file", "", "file export writes the state of --job-id to and import reads it from (empty is stdout or stdin)")
+	retryFailed := flag.Bool("retry-failed", false, "import failed tokens as left to do")
+	timeout := flag.Duration("timeout", time.Minute*5,
+		"how long health waits for the server to become ready and drain for leases to be committed")
+	dial := proto.DialFlags(flag.CommandLine)
 	flag.Parse()
 
 	if !strings.Contains(*host, ":") {
@@ -26,7 +42,7 @@ func main() {
 
 	logrus.Info("dialing grpc:", *host)
 	ctx := context.Background()
-	conn, err := grpc.Dial(*host, grpc.WithInsecure())
+	conn, err := dial.Dial(*host)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -36,14 +52,24 @@ func main() {
 	switch strings.ToLower(*action) {
 	case "reset":
 		logrus.Info("sending reset request to: ", *host)
-		ack, err := client.Reset(ctx, &proto.Empty{})
+		var ack *proto.Ack
+		err := dial.Backoff.Retry(ctx, func(ctx context.Context) error {
+			var err error
+			ack, err = client.Reset(ctx, &proto.Empty{})
+			return err
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
 		logrus.Info("reset request completed: ", ack.N)
 	case "rescan":
 		logrus.Info("sending rescan request to: ", *host)
-		ack, err := client.Rescan(ctx, &proto.Empty{})
+		var ack *proto.Ack
+		err := dial.Backoff.Retry(ctx, func(ctx context.Context) error {
+			var err error
+			ack, err = client.Rescan(ctx, &proto.Empty{})
+			return err
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -56,17 +82,326 @@ func main() {
 		}
 		logrus.Info("shuffle request completed: ", ack.N)
 	case "show":
-		logrus.Info("sending show request to: ", *host)
-		Data, err := client.Show(ctx, &proto.Empty{})
+		logrus.Info("sending list request to: ", *host)
+		req := &proto.ListRequest{
+			ID:        *jobID,
+			State:     *state,
+			Prefix:    *prefix,
+			Glob:      *glob,
+			CountOnly: *count,
+		}
+		var n int64
+		err := dial.Backoff.Retry(ctx, func(ctx context.Context) error {
+			var err error
+			n, err = showTokens(ctx, client, req)
+			return unlessStarted(n > 0, err)
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *count {
+			fmt.Println(n)
+		}
+		logrus.Info("list request completed: ", n)
+	case "results":
+		if *jobID == "" {
+			logrus.Fatal("--job-id is required for results")
+		}
+		logrus.Info("sending results request to: ", *host)
+		stream, err := client.Results(ctx, &proto.JobID{ID: *jobID})
 		if err != nil {
 			log.Fatal(err)
 		}
-		logrus.Info("show request completed")
 
-		for _, token := range Data.Tokens {
-			fmt.Println(token)
+		n := 0
+		for {
+			record, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			line, err := proto.MarshalRecord(record)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(line))
+			n++
+		}
+		logrus.Info("results request completed: ", n)
+
+	case "progress":
+		if *jobID == "" {
+			logrus.Fatal("--job-id is required for progress")
+		}
+		logrus.Info("sending progress request to: ", *host)
+		var progress *proto.JobProgress
+		err := dial.Backoff.Retry(ctx, func(ctx context.Context) error {
+			var err error
+			progress, err = client.Progress(ctx, &proto.JobID{ID: *jobID})
+			return err
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		logrus.Info("progress request completed")
+
+		line, err := json.Marshal(progress)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(line))
+
+	case "export":
+		if *jobID == "" {
+			logrus.Fatal("--job-id is required for export")
+		}
+		logrus.Info("sending export request to: ", *host)
+		w := os.Stdout
+		if *file != "" {
+			f, err := os.Create(*file)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			w = f
+		}
+		var n int
+		err := dial.Backoff.Retry(ctx, func(ctx context.Context) error {
+			var err error
+			n, err = exportJob(ctx, client, *jobID, w)
+			return unlessStarted(n > 0, err)
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		logrus.Info("export request completed: ", n)
+
+	case "import":
+		if *jobID == "" {
+			logrus.Fatal("--job-id is required for import")
 		}
+		logrus.Info("sending import request to: ", *host)
+		r := os.Stdin
+		if *file != "" {
+			f, err := os.Open(*file)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			r = f
+		}
+		states, err := readTokenStates(r)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var ack *proto.Ack
+		err = dial.Backoff.Retry(ctx, func(ctx context.Context) error {
+			var err error
+			ack, err = importJob(ctx, client, &proto.ImportRequest{ID: *jobID, RetryFailed: *retryFailed}, states)
+			return err
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		logrus.Info("import request completed, tokens left to dispatch: ", ack.N)
+
+	case "health":
+		logrus.Info("waiting for server to serve: ", *host)
+		if err := waitServing(ctx, healthpb.NewHealthClient(conn), *timeout); err != nil {
+			log.Fatal(err)
+		}
+		logrus.Info("server is serving")
+	case "drain":
+		logrus.Info("sending drain request to: ", *host)
+		if err := waitDrained(ctx, client, dial.Backoff, *timeout); err != nil {
+			log.Fatal(err)
+		}
+		logrus.Info("server is drained, no leases remain")
+	case "resume":
+		logrus.Info("sending resume request to: ", *host)
+		var ack *proto.Ack
+		err := dial.Backoff.Retry(ctx, func(ctx context.Context) error {
+			var err error
+			ack, err = client.Resume(ctx, &proto.Empty{})
+			return err
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		logrus.Info("resume request completed, leases outstanding: ", ack.N)
+	default:
+		logrus.Fatal("unknown action: ", *action)
 	}
 
 	logrus.Info("all done: ", time.Since(t))
 }
+
+// unlessStarted keeps a stream that failed after some of it was written out
+// from being retried, which would write it twice. Streams to a replica that
+// is not the leader fail before anything is written.
+func unlessStarted(started bool, err error) error {
+	if started && err != nil {
+		return errors.New("stream broke off: " + err.Error())
+	}
+	return err
+}
+
+// showTokens prints the tokens that pass the filter of req as they are
+// listed, unless only counting, and returns how many there are
+func showTokens(ctx context.Context, client proto.TokensClient, req *proto.ListRequest) (int64, error) {
+	stream, err := client.List(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for {
+		page, err := stream.Recv()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		for _, token := range page.Tokens {
+			fmt.Println(token)
+		}
+		n += page.Count
+	}
+}
+
+// exportJob writes the state of each token of job id to w as a line of JSON
+// and returns how many there are
+func exportJob(ctx context.Context, client proto.TokensClient, id string, w io.Writer) (int, error) {
+	stream, err := client.Export(ctx, &proto.JobID{ID: id})
+	if err != nil {
+		return 0, err
+	}
+
+	bw := bufio.NewWriter(w)
+	n := 0
+	for {
+		page, err := stream.Recv()
+		if err == io.EOF {
+			return n, bw.Flush()
+		}
+		if err != nil {
+			return n, err
+		}
+		for _, state := range page.Tokens {
+			line, err := proto.MarshalTokenState(state)
+			if err != nil {
+				return n, err
+			}
+			bw.Write(line)
+			bw.WriteByte('\n')
+			n++
+		}
+	}
+}
+
+// readTokenStates reads token states from lines of r
+func readTokenStates(r io.Reader) ([]*proto.TokenState, error) {
+	var states []*proto.TokenState
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		state, err := proto.UnmarshalTokenState(scanner.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, scanner.Err()
+}
+
+// importJob sends states in pages, each carrying the job and options of req
+func importJob(ctx context.Context, client proto.TokensClient, req *proto.ImportRequest,
+	states []*proto.TokenState) (*proto.Ack, error) {
+	stream, err := client.Import(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const pageSize = 1000
+	// a single page is sent even without tokens, it names the job
+	for first := true; first || len(states) > 0; first = false {
+		n := len(states)
+		if n > pageSize {
+			n = pageSize
+		}
+		page := &proto.ImportRequest{ID: req.ID, RetryFailed: req.RetryFailed, Tokens: states[:n]}
+		states = states[n:]
+
+		// the server ended the call if sending fails with io.EOF, its
+		// status comes with the reply
+		if err := stream.Send(page); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// waitServing polls the standard health service until the server reports
+// SERVING for the Tokens service or timeout passes
+func waitServing(ctx context.Context, client healthpb.HealthClient, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: proto.ServiceName})
+		if err == nil && resp.Status == healthpb.HealthCheckResponse_SERVING {
+			return nil
+		}
+		if err != nil && !proto.Retryable(err) {
+			return err
+		}
+		if err == nil {
+			logrus.Info("server is ", resp.Status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.New("server did not become ready within " + timeout.String())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// waitDrained puts the server into drain and polls until no leases remain
+// or timeout passes
+func waitDrained(ctx context.Context, client proto.TokensClient, backoff proto.Backoff,
+	timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		var ack *proto.Ack
+		err := backoff.Retry(ctx, func(ctx context.Context) error {
+			var err error
+			ack, err = client.Drain(ctx, &proto.Empty{})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if ack.Status {
+			return nil
+		}
+		logrus.Info("leases outstanding: ", ack.N)
+
+		select {
+		case <-ctx.Done():
+			return errors.New("leases were not committed within " + timeout.String())
+		case <-time.After(time.Second):
+		}
+	}
+}
@@ -1,10 +1,25 @@
 module github.com/sdeoras/token
 
+go 1.22
+
 require (
 	github.com/golang/protobuf v1.2.0
 	github.com/google/uuid v1.1.0
+	github.com/prometheus/client_golang v0.9.0
 	github.com/sirupsen/logrus v1.2.0
 	github.com/tensorflow/tensorflow v1.12.0
 	golang.org/x/net v0.0.0-20181201002055-351d144fa1fc
 	google.golang.org/grpc v1.16.0
 )
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/prometheus/client_model v0.0.0-20170216185247-6f3806018612 // indirect
+	github.com/prometheus/common v0.0.0-20181126121408-4724e9255275 // indirect
+	github.com/prometheus/procfs v0.0.0-20181204211112-1dc9a6cbc91a // indirect
+	golang.org/x/crypto v0.0.0-20180904163835-0709b304e793 // indirect
+	golang.org/x/sys v0.0.0-20180905080454-ebe1bf3edb33 // indirect
+	golang.org/x/text v0.3.0 // indirect
+	google.golang.org/genproto v0.0.0-20180817151627-c66870c02cf8 // indirect
+)
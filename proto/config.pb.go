@@ -1,21 +1,9 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // source: config.proto
 
-/*
-Package proto is a generated protocol buffer package.
-
-It is generated from these files:
-	config.proto
-
-It has these top-level messages:
-	Data
-	JobID
-	Empty
-	Ack
-*/
 package proto
 
-import proto1 "github.com/golang/protobuf/proto"
+import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
 
@@ -25,7 +13,7 @@ import (
 )
 
 // Reference imports to suppress errors if they are not otherwise used.
-var _ = proto1.Marshal
+var _ = proto.Marshal
 var _ = fmt.Errorf
 var _ = math.Inf
 
@@ -33,19 +21,46 @@ var _ = math.Inf
 // is compatible with the proto package it is being compiled against.
 // A compilation error at this line likely means your copy of the
 // proto package needs to be updated.
-const _ = proto1.ProtoPackageIsVersion2 // please upgrade the proto package
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 // server sends data to clients
 // this data contains a key that server uses to track a job
+// bytes is the total size of files backing the tokens
+// draining is set instead of tokens while the server grants no new leases,
+// workers stop as if the job had run out of tokens
 type Data struct {
-	Tokens []string `protobuf:"bytes,1,rep,name=tokens" json:"tokens,omitempty"`
-	Key    string   `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+	Tokens               []string `protobuf:"bytes,1,rep,name=tokens,proto3" json:"tokens,omitempty"`
+	Key                  string   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Bytes                int64    `protobuf:"varint,3,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	Draining             bool     `protobuf:"varint,4,opt,name=draining,proto3" json:"draining,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *Data) Reset()                    { *m = Data{} }
-func (m *Data) String() string            { return proto1.CompactTextString(m) }
-func (*Data) ProtoMessage()               {}
-func (*Data) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+func (m *Data) Reset()         { *m = Data{} }
+func (m *Data) String() string { return proto.CompactTextString(m) }
+func (*Data) ProtoMessage()    {}
+func (*Data) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{0}
+}
+func (m *Data) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Data.Unmarshal(m, b)
+}
+func (m *Data) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Data.Marshal(b, m, deterministic)
+}
+func (dst *Data) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Data.Merge(dst, src)
+}
+func (m *Data) XXX_Size() int {
+	return xxx_messageInfo_Data.Size(m)
+}
+func (m *Data) XXX_DiscardUnknown() {
+	xxx_messageInfo_Data.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Data proto.InternalMessageInfo
 
 func (m *Data) GetTokens() []string {
 	if m != nil {
@@ -61,18 +76,68 @@ func (m *Data) GetKey() string {
 	return ""
 }
 
+func (m *Data) GetBytes() int64 {
+	if m != nil {
+		return m.Bytes
+	}
+	return 0
+}
+
+func (m *Data) GetDraining() bool {
+	if m != nil {
+		return m.Draining
+	}
+	return false
+}
+
 // client sends jobID to server to request list of tokens to work on
 // client requests up to batch_size number of tokens but may receive less
+// worker identifies the client process so server can learn its throughput
+// byte_budget, if set, caps the total size of files in a batch either alone
+// or together with batch_size
+// shard and num_shards identify the worker as shard of num_shards when server
+// runs in static sharding mode
+// records carry per token results along with Done()
+// request identifies a Get() across retries, a retry of a Get() that was
+// granted a lease returns that lease instead of another one
 type JobID struct {
-	ID        string `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
-	Key       string `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
-	BatchSize int32  `protobuf:"varint,3,opt,name=batch_size,json=batchSize" json:"batch_size,omitempty"`
+	ID                   string    `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	Key                  string    `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	BatchSize            int32     `protobuf:"varint,3,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"`
+	Worker               string    `protobuf:"bytes,4,opt,name=worker,proto3" json:"worker,omitempty"`
+	ByteBudget           int64     `protobuf:"varint,5,opt,name=byte_budget,json=byteBudget,proto3" json:"byte_budget,omitempty"`
+	Shard                int32     `protobuf:"varint,6,opt,name=shard,proto3" json:"shard,omitempty"`
+	NumShards            int32     `protobuf:"varint,7,opt,name=num_shards,json=numShards,proto3" json:"num_shards,omitempty"`
+	Records              []*Record `protobuf:"bytes,8,rep,name=records,proto3" json:"records,omitempty"`
+	Request              string    `protobuf:"bytes,9,opt,name=request,proto3" json:"request,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *JobID) Reset()         { *m = JobID{} }
+func (m *JobID) String() string { return proto.CompactTextString(m) }
+func (*JobID) ProtoMessage()    {}
+func (*JobID) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{1}
+}
+func (m *JobID) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_JobID.Unmarshal(m, b)
+}
+func (m *JobID) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_JobID.Marshal(b, m, deterministic)
+}
+func (dst *JobID) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_JobID.Merge(dst, src)
+}
+func (m *JobID) XXX_Size() int {
+	return xxx_messageInfo_JobID.Size(m)
+}
+func (m *JobID) XXX_DiscardUnknown() {
+	xxx_messageInfo_JobID.DiscardUnknown(m)
 }
 
-func (m *JobID) Reset()                    { *m = JobID{} }
-func (m *JobID) String() string            { return proto1.CompactTextString(m) }
-func (*JobID) ProtoMessage()               {}
-func (*JobID) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+var xxx_messageInfo_JobID proto.InternalMessageInfo
 
 func (m *JobID) GetID() string {
 	if m != nil {
@@ -95,25 +160,171 @@ func (m *JobID) GetBatchSize() int32 {
 	return 0
 }
 
+func (m *JobID) GetWorker() string {
+	if m != nil {
+		return m.Worker
+	}
+	return ""
+}
+
+func (m *JobID) GetByteBudget() int64 {
+	if m != nil {
+		return m.ByteBudget
+	}
+	return 0
+}
+
+func (m *JobID) GetShard() int32 {
+	if m != nil {
+		return m.Shard
+	}
+	return 0
+}
+
+func (m *JobID) GetNumShards() int32 {
+	if m != nil {
+		return m.NumShards
+	}
+	return 0
+}
+
+func (m *JobID) GetRecords() []*Record {
+	if m != nil {
+		return m.Records
+	}
+	return nil
+}
+
+func (m *JobID) GetRequest() string {
+	if m != nil {
+		return m.Request
+	}
+	return ""
+}
+
+// worker sends a result record per token it processed
+// data is opaque to the server, error is set if the token failed
+type Record struct {
+	Token                string   `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Data                 []byte   `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Error                string   `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Record) Reset()         { *m = Record{} }
+func (m *Record) String() string { return proto.CompactTextString(m) }
+func (*Record) ProtoMessage()    {}
+func (*Record) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{2}
+}
+func (m *Record) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Record.Unmarshal(m, b)
+}
+func (m *Record) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Record.Marshal(b, m, deterministic)
+}
+func (dst *Record) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Record.Merge(dst, src)
+}
+func (m *Record) XXX_Size() int {
+	return xxx_messageInfo_Record.Size(m)
+}
+func (m *Record) XXX_DiscardUnknown() {
+	xxx_messageInfo_Record.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Record proto.InternalMessageInfo
+
+func (m *Record) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *Record) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *Record) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
 // empty is like null, but don't substitute nil pointer for it
 type Empty struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Empty) Reset()         { *m = Empty{} }
+func (m *Empty) String() string { return proto.CompactTextString(m) }
+func (*Empty) ProtoMessage()    {}
+func (*Empty) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{3}
+}
+func (m *Empty) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Empty.Unmarshal(m, b)
+}
+func (m *Empty) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Empty.Marshal(b, m, deterministic)
+}
+func (dst *Empty) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Empty.Merge(dst, src)
+}
+func (m *Empty) XXX_Size() int {
+	return xxx_messageInfo_Empty.Size(m)
+}
+func (m *Empty) XXX_DiscardUnknown() {
+	xxx_messageInfo_Empty.DiscardUnknown(m)
 }
 
-func (m *Empty) Reset()                    { *m = Empty{} }
-func (m *Empty) String() string            { return proto1.CompactTextString(m) }
-func (*Empty) ProtoMessage()               {}
-func (*Empty) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+var xxx_messageInfo_Empty proto.InternalMessageInfo
 
 // server sends acknowledgement for a variety of client calls
+// lost is set when a heartbeat names a lease the worker no longer holds
+// draining tells workers that the server grants no new leases
 type Ack struct {
-	N      int32 `protobuf:"varint,1,opt,name=n" json:"n,omitempty"`
-	Status bool  `protobuf:"varint,2,opt,name=status" json:"status,omitempty"`
+	N                    int32    `protobuf:"varint,1,opt,name=n,proto3" json:"n,omitempty"`
+	Status               bool     `protobuf:"varint,2,opt,name=status,proto3" json:"status,omitempty"`
+	Lost                 bool     `protobuf:"varint,3,opt,name=lost,proto3" json:"lost,omitempty"`
+	Draining             bool     `protobuf:"varint,4,opt,name=draining,proto3" json:"draining,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Ack) Reset()         { *m = Ack{} }
+func (m *Ack) String() string { return proto.CompactTextString(m) }
+func (*Ack) ProtoMessage()    {}
+func (*Ack) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{4}
+}
+func (m *Ack) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Ack.Unmarshal(m, b)
+}
+func (m *Ack) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Ack.Marshal(b, m, deterministic)
+}
+func (dst *Ack) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Ack.Merge(dst, src)
+}
+func (m *Ack) XXX_Size() int {
+	return xxx_messageInfo_Ack.Size(m)
+}
+func (m *Ack) XXX_DiscardUnknown() {
+	xxx_messageInfo_Ack.DiscardUnknown(m)
 }
 
-func (m *Ack) Reset()                    { *m = Ack{} }
-func (m *Ack) String() string            { return proto1.CompactTextString(m) }
-func (*Ack) ProtoMessage()               {}
-func (*Ack) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+var xxx_messageInfo_Ack proto.InternalMessageInfo
 
 func (m *Ack) GetN() int32 {
 	if m != nil {
@@ -129,118 +340,1352 @@ func (m *Ack) GetStatus() bool {
 	return false
 }
 
-func init() {
-	proto1.RegisterType((*Data)(nil), "proto.Data")
-	proto1.RegisterType((*JobID)(nil), "proto.JobID")
-	proto1.RegisterType((*Empty)(nil), "proto.Empty")
-	proto1.RegisterType((*Ack)(nil), "proto.Ack")
+func (m *Ack) GetLost() bool {
+	if m != nil {
+		return m.Lost
+	}
+	return false
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+func (m *Ack) GetDraining() bool {
+	if m != nil {
+		return m.Draining
+	}
+	return false
+}
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+// worker renews all leases it holds for a job in a single call
+type Leases struct {
+	ID                   string   `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	Worker               string   `protobuf:"bytes,2,opt,name=worker,proto3" json:"worker,omitempty"`
+	Keys                 []string `protobuf:"bytes,3,rep,name=keys,proto3" json:"keys,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Leases) Reset()         { *m = Leases{} }
+func (m *Leases) String() string { return proto.CompactTextString(m) }
+func (*Leases) ProtoMessage()    {}
+func (*Leases) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{5}
+}
+func (m *Leases) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Leases.Unmarshal(m, b)
+}
+func (m *Leases) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Leases.Marshal(b, m, deterministic)
+}
+func (dst *Leases) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Leases.Merge(dst, src)
+}
+func (m *Leases) XXX_Size() int {
+	return xxx_messageInfo_Leases.Size(m)
+}
+func (m *Leases) XXX_DiscardUnknown() {
+	xxx_messageInfo_Leases.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Leases proto.InternalMessageInfo
+
+func (m *Leases) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *Leases) GetWorker() string {
+	if m != nil {
+		return m.Worker
+	}
+	return ""
+}
+
+func (m *Leases) GetKeys() []string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+// status is true if the lease is still held by the worker
+// false means it was committed, reassigned after timing out or is unknown
+type LeaseStatus struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Status               bool     `protobuf:"varint,2,opt,name=status,proto3" json:"status,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LeaseStatus) Reset()         { *m = LeaseStatus{} }
+func (m *LeaseStatus) String() string { return proto.CompactTextString(m) }
+func (*LeaseStatus) ProtoMessage()    {}
+func (*LeaseStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{6}
+}
+func (m *LeaseStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LeaseStatus.Unmarshal(m, b)
+}
+func (m *LeaseStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LeaseStatus.Marshal(b, m, deterministic)
+}
+func (dst *LeaseStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LeaseStatus.Merge(dst, src)
+}
+func (m *LeaseStatus) XXX_Size() int {
+	return xxx_messageInfo_LeaseStatus.Size(m)
+}
+func (m *LeaseStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_LeaseStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LeaseStatus proto.InternalMessageInfo
+
+func (m *LeaseStatus) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *LeaseStatus) GetStatus() bool {
+	if m != nil {
+		return m.Status
+	}
+	return false
+}
+
+// server returns a status per lease and whether the job is complete
+// draining tells workers that the server grants no new leases
+type Renewal struct {
+	Leases               []*LeaseStatus `protobuf:"bytes,1,rep,name=leases,proto3" json:"leases,omitempty"`
+	Completed            bool           `protobuf:"varint,2,opt,name=completed,proto3" json:"completed,omitempty"`
+	Draining             bool           `protobuf:"varint,3,opt,name=draining,proto3" json:"draining,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *Renewal) Reset()         { *m = Renewal{} }
+func (m *Renewal) String() string { return proto.CompactTextString(m) }
+func (*Renewal) ProtoMessage()    {}
+func (*Renewal) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{7}
+}
+func (m *Renewal) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Renewal.Unmarshal(m, b)
+}
+func (m *Renewal) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Renewal.Marshal(b, m, deterministic)
+}
+func (dst *Renewal) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Renewal.Merge(dst, src)
+}
+func (m *Renewal) XXX_Size() int {
+	return xxx_messageInfo_Renewal.Size(m)
+}
+func (m *Renewal) XXX_DiscardUnknown() {
+	xxx_messageInfo_Renewal.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Renewal proto.InternalMessageInfo
+
+func (m *Renewal) GetLeases() []*LeaseStatus {
+	if m != nil {
+		return m.Leases
+	}
+	return nil
+}
+
+func (m *Renewal) GetCompleted() bool {
+	if m != nil {
+		return m.Completed
+	}
+	return false
+}
+
+func (m *Renewal) GetDraining() bool {
+	if m != nil {
+		return m.Draining
+	}
+	return false
+}
+
+// server counts tokens and leases of a job
+// completed is true once every dispatched token was committed
+type JobProgress struct {
+	ID                   string   `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	Tokens               int64    `protobuf:"varint,2,opt,name=tokens,proto3" json:"tokens,omitempty"`
+	Dispatched           int64    `protobuf:"varint,3,opt,name=dispatched,proto3" json:"dispatched,omitempty"`
+	TokensCompleted      int64    `protobuf:"varint,4,opt,name=tokens_completed,json=tokensCompleted,proto3" json:"tokens_completed,omitempty"`
+	LeasesGranted        int64    `protobuf:"varint,5,opt,name=leases_granted,json=leasesGranted,proto3" json:"leases_granted,omitempty"`
+	LeasesReassigned     int64    `protobuf:"varint,6,opt,name=leases_reassigned,json=leasesReassigned,proto3" json:"leases_reassigned,omitempty"`
+	LeasesCompleted      int64    `protobuf:"varint,7,opt,name=leases_completed,json=leasesCompleted,proto3" json:"leases_completed,omitempty"`
+	LeasesOutstanding    int64    `protobuf:"varint,8,opt,name=leases_outstanding,json=leasesOutstanding,proto3" json:"leases_outstanding,omitempty"`
+	Completed            bool     `protobuf:"varint,9,opt,name=completed,proto3" json:"completed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *JobProgress) Reset()         { *m = JobProgress{} }
+func (m *JobProgress) String() string { return proto.CompactTextString(m) }
+func (*JobProgress) ProtoMessage()    {}
+func (*JobProgress) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{8}
+}
+func (m *JobProgress) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_JobProgress.Unmarshal(m, b)
+}
+func (m *JobProgress) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_JobProgress.Marshal(b, m, deterministic)
+}
+func (dst *JobProgress) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_JobProgress.Merge(dst, src)
+}
+func (m *JobProgress) XXX_Size() int {
+	return xxx_messageInfo_JobProgress.Size(m)
+}
+func (m *JobProgress) XXX_DiscardUnknown() {
+	xxx_messageInfo_JobProgress.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_JobProgress proto.InternalMessageInfo
+
+func (m *JobProgress) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *JobProgress) GetTokens() int64 {
+	if m != nil {
+		return m.Tokens
+	}
+	return 0
+}
+
+func (m *JobProgress) GetDispatched() int64 {
+	if m != nil {
+		return m.Dispatched
+	}
+	return 0
+}
+
+func (m *JobProgress) GetTokensCompleted() int64 {
+	if m != nil {
+		return m.TokensCompleted
+	}
+	return 0
+}
+
+func (m *JobProgress) GetLeasesGranted() int64 {
+	if m != nil {
+		return m.LeasesGranted
+	}
+	return 0
+}
+
+func (m *JobProgress) GetLeasesReassigned() int64 {
+	if m != nil {
+		return m.LeasesReassigned
+	}
+	return 0
+}
+
+func (m *JobProgress) GetLeasesCompleted() int64 {
+	if m != nil {
+		return m.LeasesCompleted
+	}
+	return 0
+}
+
+func (m *JobProgress) GetLeasesOutstanding() int64 {
+	if m != nil {
+		return m.LeasesOutstanding
+	}
+	return 0
+}
+
+func (m *JobProgress) GetCompleted() bool {
+	if m != nil {
+		return m.Completed
+	}
+	return false
+}
+
+// client lists tokens, optionally only those of job ID in a state, which is
+// one of pending, leased, completed or failed
+// prefix and glob, if set, filter names, glob as in Go's path.Match
+// count_only has the server count tokens instead of sending them
+// page_size caps the tokens per page, the server picks a size if it is 0
+type ListRequest struct {
+	ID                   string   `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	State                string   `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Prefix               string   `protobuf:"bytes,3,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	Glob                 string   `protobuf:"bytes,4,opt,name=glob,proto3" json:"glob,omitempty"`
+	CountOnly            bool     `protobuf:"varint,5,opt,name=count_only,json=countOnly,proto3" json:"count_only,omitempty"`
+	PageSize             int32    `protobuf:"varint,6,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListRequest) Reset()         { *m = ListRequest{} }
+func (m *ListRequest) String() string { return proto.CompactTextString(m) }
+func (*ListRequest) ProtoMessage()    {}
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{9}
+}
+func (m *ListRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListRequest.Unmarshal(m, b)
+}
+func (m *ListRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListRequest.Marshal(b, m, deterministic)
+}
+func (dst *ListRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListRequest.Merge(dst, src)
+}
+func (m *ListRequest) XXX_Size() int {
+	return xxx_messageInfo_ListRequest.Size(m)
+}
+func (m *ListRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListRequest proto.InternalMessageInfo
+
+func (m *ListRequest) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
 
-// Client API for Tokens service
+func (m *ListRequest) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *ListRequest) GetPrefix() string {
+	if m != nil {
+		return m.Prefix
+	}
+	return ""
+}
+
+func (m *ListRequest) GetGlob() string {
+	if m != nil {
+		return m.Glob
+	}
+	return ""
+}
+
+func (m *ListRequest) GetCountOnly() bool {
+	if m != nil {
+		return m.CountOnly
+	}
+	return false
+}
+
+func (m *ListRequest) GetPageSize() int32 {
+	if m != nil {
+		return m.PageSize
+	}
+	return 0
+}
+
+// server streams listed tokens in pages
+// count is the number of tokens a page stands for, counts of all pages add up
+// to the number of tokens listed, also when only counting
+type TokenPage struct {
+	Tokens               []string `protobuf:"bytes,1,rep,name=tokens,proto3" json:"tokens,omitempty"`
+	Count                int64    `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TokenPage) Reset()         { *m = TokenPage{} }
+func (m *TokenPage) String() string { return proto.CompactTextString(m) }
+func (*TokenPage) ProtoMessage()    {}
+func (*TokenPage) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{10}
+}
+func (m *TokenPage) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TokenPage.Unmarshal(m, b)
+}
+func (m *TokenPage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TokenPage.Marshal(b, m, deterministic)
+}
+func (dst *TokenPage) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TokenPage.Merge(dst, src)
+}
+func (m *TokenPage) XXX_Size() int {
+	return xxx_messageInfo_TokenPage.Size(m)
+}
+func (m *TokenPage) XXX_DiscardUnknown() {
+	xxx_messageInfo_TokenPage.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TokenPage proto.InternalMessageInfo
+
+func (m *TokenPage) GetTokens() []string {
+	if m != nil {
+		return m.Tokens
+	}
+	return nil
+}
+
+func (m *TokenPage) GetCount() int64 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+// server exports the state of a token of a job, one of pending, leased,
+// completed or failed
+// worker and key name the lease that holds the token and time is when it
+// was leased, in unix nanoseconds. Completed and failed tokens name the
+// worker that committed them and when it last committed the run of tokens
+// they are in, but no key.
+type TokenState struct {
+	Token                string   `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	State                string   `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Worker               string   `protobuf:"bytes,3,opt,name=worker,proto3" json:"worker,omitempty"`
+	Key                  string   `protobuf:"bytes,4,opt,name=key,proto3" json:"key,omitempty"`
+	Time                 int64    `protobuf:"varint,5,opt,name=time,proto3" json:"time,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TokenState) Reset()         { *m = TokenState{} }
+func (m *TokenState) String() string { return proto.CompactTextString(m) }
+func (*TokenState) ProtoMessage()    {}
+func (*TokenState) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{11}
+}
+func (m *TokenState) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TokenState.Unmarshal(m, b)
+}
+func (m *TokenState) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TokenState.Marshal(b, m, deterministic)
+}
+func (dst *TokenState) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TokenState.Merge(dst, src)
+}
+func (m *TokenState) XXX_Size() int {
+	return xxx_messageInfo_TokenState.Size(m)
+}
+func (m *TokenState) XXX_DiscardUnknown() {
+	xxx_messageInfo_TokenState.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TokenState proto.InternalMessageInfo
+
+func (m *TokenState) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *TokenState) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *TokenState) GetWorker() string {
+	if m != nil {
+		return m.Worker
+	}
+	return ""
+}
+
+func (m *TokenState) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *TokenState) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+// server streams exported token states in pages
+type TokenStates struct {
+	Tokens               []*TokenState `protobuf:"bytes,1,rep,name=tokens,proto3" json:"tokens,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *TokenStates) Reset()         { *m = TokenStates{} }
+func (m *TokenStates) String() string { return proto.CompactTextString(m) }
+func (*TokenStates) ProtoMessage()    {}
+func (*TokenStates) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{12}
+}
+func (m *TokenStates) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TokenStates.Unmarshal(m, b)
+}
+func (m *TokenStates) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TokenStates.Marshal(b, m, deterministic)
+}
+func (dst *TokenStates) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TokenStates.Merge(dst, src)
+}
+func (m *TokenStates) XXX_Size() int {
+	return xxx_messageInfo_TokenStates.Size(m)
+}
+func (m *TokenStates) XXX_DiscardUnknown() {
+	xxx_messageInfo_TokenStates.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TokenStates proto.InternalMessageInfo
+
+func (m *TokenStates) GetTokens() []*TokenState {
+	if m != nil {
+		return m.Tokens
+	}
+	return nil
+}
+
+// client imports exported token states into the new job ID in pages, each
+// page names the job
+// tokens completed and, unless retry_failed, failed are not dispatched again
+type ImportRequest struct {
+	ID                   string        `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	RetryFailed          bool          `protobuf:"varint,2,opt,name=retry_failed,json=retryFailed,proto3" json:"retry_failed,omitempty"`
+	Tokens               []*TokenState `protobuf:"bytes,3,rep,name=tokens,proto3" json:"tokens,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *ImportRequest) Reset()         { *m = ImportRequest{} }
+func (m *ImportRequest) String() string { return proto.CompactTextString(m) }
+func (*ImportRequest) ProtoMessage()    {}
+func (*ImportRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{13}
+}
+func (m *ImportRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportRequest.Unmarshal(m, b)
+}
+func (m *ImportRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportRequest.Marshal(b, m, deterministic)
+}
+func (dst *ImportRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportRequest.Merge(dst, src)
+}
+func (m *ImportRequest) XXX_Size() int {
+	return xxx_messageInfo_ImportRequest.Size(m)
+}
+func (m *ImportRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImportRequest proto.InternalMessageInfo
+
+func (m *ImportRequest) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *ImportRequest) GetRetryFailed() bool {
+	if m != nil {
+		return m.RetryFailed
+	}
+	return false
+}
+
+func (m *ImportRequest) GetTokens() []*TokenState {
+	if m != nil {
+		return m.Tokens
+	}
+	return nil
+}
+
+// replica that is not the leader names the one that is in the details of
+// the codes.Unavailable status it answers with
+type LeaderHint struct {
+	Leader               string   `protobuf:"bytes,1,opt,name=leader,proto3" json:"leader,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LeaderHint) Reset()         { *m = LeaderHint{} }
+func (m *LeaderHint) String() string { return proto.CompactTextString(m) }
+func (*LeaderHint) ProtoMessage()    {}
+func (*LeaderHint) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{14}
+}
+func (m *LeaderHint) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LeaderHint.Unmarshal(m, b)
+}
+func (m *LeaderHint) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LeaderHint.Marshal(b, m, deterministic)
+}
+func (dst *LeaderHint) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LeaderHint.Merge(dst, src)
+}
+func (m *LeaderHint) XXX_Size() int {
+	return xxx_messageInfo_LeaderHint.Size(m)
+}
+func (m *LeaderHint) XXX_DiscardUnknown() {
+	xxx_messageInfo_LeaderHint.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LeaderHint proto.InternalMessageInfo
+
+func (m *LeaderHint) GetLeader() string {
+	if m != nil {
+		return m.Leader
+	}
+	return ""
+}
+
+// an entry of the replicated log of a highly available server
+type Entry struct {
+	Index                uint64   `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Term                 uint64   `protobuf:"varint,2,opt,name=term,proto3" json:"term,omitempty"`
+	Data                 []byte   `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Entry) Reset()         { *m = Entry{} }
+func (m *Entry) String() string { return proto.CompactTextString(m) }
+func (*Entry) ProtoMessage()    {}
+func (*Entry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{15}
+}
+func (m *Entry) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Entry.Unmarshal(m, b)
+}
+func (m *Entry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Entry.Marshal(b, m, deterministic)
+}
+func (dst *Entry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Entry.Merge(dst, src)
+}
+func (m *Entry) XXX_Size() int {
+	return xxx_messageInfo_Entry.Size(m)
+}
+func (m *Entry) XXX_DiscardUnknown() {
+	xxx_messageInfo_Entry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Entry proto.InternalMessageInfo
+
+func (m *Entry) GetIndex() uint64 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *Entry) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *Entry) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+// candidate asks a replica for its vote
+type VoteRequest struct {
+	Term                 uint64   `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
+	Candidate            string   `protobuf:"bytes,2,opt,name=candidate,proto3" json:"candidate,omitempty"`
+	LastLogIndex         uint64   `protobuf:"varint,3,opt,name=last_log_index,json=lastLogIndex,proto3" json:"last_log_index,omitempty"`
+	LastLogTerm          uint64   `protobuf:"varint,4,opt,name=last_log_term,json=lastLogTerm,proto3" json:"last_log_term,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *VoteRequest) Reset()         { *m = VoteRequest{} }
+func (m *VoteRequest) String() string { return proto.CompactTextString(m) }
+func (*VoteRequest) ProtoMessage()    {}
+func (*VoteRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{16}
+}
+func (m *VoteRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_VoteRequest.Unmarshal(m, b)
+}
+func (m *VoteRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_VoteRequest.Marshal(b, m, deterministic)
+}
+func (dst *VoteRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_VoteRequest.Merge(dst, src)
+}
+func (m *VoteRequest) XXX_Size() int {
+	return xxx_messageInfo_VoteRequest.Size(m)
+}
+func (m *VoteRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_VoteRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_VoteRequest proto.InternalMessageInfo
+
+func (m *VoteRequest) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *VoteRequest) GetCandidate() string {
+	if m != nil {
+		return m.Candidate
+	}
+	return ""
+}
+
+func (m *VoteRequest) GetLastLogIndex() uint64 {
+	if m != nil {
+		return m.LastLogIndex
+	}
+	return 0
+}
+
+func (m *VoteRequest) GetLastLogTerm() uint64 {
+	if m != nil {
+		return m.LastLogTerm
+	}
+	return 0
+}
+
+// replica answers a vote request
+type VoteReply struct {
+	Term                 uint64   `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
+	Granted              bool     `protobuf:"varint,2,opt,name=granted,proto3" json:"granted,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *VoteReply) Reset()         { *m = VoteReply{} }
+func (m *VoteReply) String() string { return proto.CompactTextString(m) }
+func (*VoteReply) ProtoMessage()    {}
+func (*VoteReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{17}
+}
+func (m *VoteReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_VoteReply.Unmarshal(m, b)
+}
+func (m *VoteReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_VoteReply.Marshal(b, m, deterministic)
+}
+func (dst *VoteReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_VoteReply.Merge(dst, src)
+}
+func (m *VoteReply) XXX_Size() int {
+	return xxx_messageInfo_VoteReply.Size(m)
+}
+func (m *VoteReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_VoteReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_VoteReply proto.InternalMessageInfo
+
+func (m *VoteReply) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *VoteReply) GetGranted() bool {
+	if m != nil {
+		return m.Granted
+	}
+	return false
+}
+
+// leader replicates entries following prev_log_index, no entries is a heartbeat
+type AppendRequest struct {
+	Term                 uint64   `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
+	Leader               string   `protobuf:"bytes,2,opt,name=leader,proto3" json:"leader,omitempty"`
+	PrevLogIndex         uint64   `protobuf:"varint,3,opt,name=prev_log_index,json=prevLogIndex,proto3" json:"prev_log_index,omitempty"`
+	PrevLogTerm          uint64   `protobuf:"varint,4,opt,name=prev_log_term,json=prevLogTerm,proto3" json:"prev_log_term,omitempty"`
+	Entries              []*Entry `protobuf:"bytes,5,rep,name=entries,proto3" json:"entries,omitempty"`
+	LeaderCommit         uint64   `protobuf:"varint,6,opt,name=leader_commit,json=leaderCommit,proto3" json:"leader_commit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AppendRequest) Reset()         { *m = AppendRequest{} }
+func (m *AppendRequest) String() string { return proto.CompactTextString(m) }
+func (*AppendRequest) ProtoMessage()    {}
+func (*AppendRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{18}
+}
+func (m *AppendRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AppendRequest.Unmarshal(m, b)
+}
+func (m *AppendRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AppendRequest.Marshal(b, m, deterministic)
+}
+func (dst *AppendRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AppendRequest.Merge(dst, src)
+}
+func (m *AppendRequest) XXX_Size() int {
+	return xxx_messageInfo_AppendRequest.Size(m)
+}
+func (m *AppendRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AppendRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AppendRequest proto.InternalMessageInfo
+
+func (m *AppendRequest) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *AppendRequest) GetLeader() string {
+	if m != nil {
+		return m.Leader
+	}
+	return ""
+}
+
+func (m *AppendRequest) GetPrevLogIndex() uint64 {
+	if m != nil {
+		return m.PrevLogIndex
+	}
+	return 0
+}
+
+func (m *AppendRequest) GetPrevLogTerm() uint64 {
+	if m != nil {
+		return m.PrevLogTerm
+	}
+	return 0
+}
+
+func (m *AppendRequest) GetEntries() []*Entry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+func (m *AppendRequest) GetLeaderCommit() uint64 {
+	if m != nil {
+		return m.LeaderCommit
+	}
+	return 0
+}
+
+// replica reports whether it accepted entries and its last log index
+type AppendReply struct {
+	Term                 uint64   `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
+	Success              bool     `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	LastIndex            uint64   `protobuf:"varint,3,opt,name=last_index,json=lastIndex,proto3" json:"last_index,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AppendReply) Reset()         { *m = AppendReply{} }
+func (m *AppendReply) String() string { return proto.CompactTextString(m) }
+func (*AppendReply) ProtoMessage()    {}
+func (*AppendReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{19}
+}
+func (m *AppendReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AppendReply.Unmarshal(m, b)
+}
+func (m *AppendReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AppendReply.Marshal(b, m, deterministic)
+}
+func (dst *AppendReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AppendReply.Merge(dst, src)
+}
+func (m *AppendReply) XXX_Size() int {
+	return xxx_messageInfo_AppendReply.Size(m)
+}
+func (m *AppendReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_AppendReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AppendReply proto.InternalMessageInfo
+
+func (m *AppendReply) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *AppendReply) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *AppendReply) GetLastIndex() uint64 {
+	if m != nil {
+		return m.LastIndex
+	}
+	return 0
+}
+
+// leader sends its snapshot to a replica that is too far behind
+type SnapshotRequest struct {
+	Term                 uint64   `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
+	Leader               string   `protobuf:"bytes,2,opt,name=leader,proto3" json:"leader,omitempty"`
+	LastIndex            uint64   `protobuf:"varint,3,opt,name=last_index,json=lastIndex,proto3" json:"last_index,omitempty"`
+	LastTerm             uint64   `protobuf:"varint,4,opt,name=last_term,json=lastTerm,proto3" json:"last_term,omitempty"`
+	Data                 []byte   `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SnapshotRequest) Reset()         { *m = SnapshotRequest{} }
+func (m *SnapshotRequest) String() string { return proto.CompactTextString(m) }
+func (*SnapshotRequest) ProtoMessage()    {}
+func (*SnapshotRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{20}
+}
+func (m *SnapshotRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SnapshotRequest.Unmarshal(m, b)
+}
+func (m *SnapshotRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SnapshotRequest.Marshal(b, m, deterministic)
+}
+func (dst *SnapshotRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SnapshotRequest.Merge(dst, src)
+}
+func (m *SnapshotRequest) XXX_Size() int {
+	return xxx_messageInfo_SnapshotRequest.Size(m)
+}
+func (m *SnapshotRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SnapshotRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SnapshotRequest proto.InternalMessageInfo
+
+func (m *SnapshotRequest) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *SnapshotRequest) GetLeader() string {
+	if m != nil {
+		return m.Leader
+	}
+	return ""
+}
+
+func (m *SnapshotRequest) GetLastIndex() uint64 {
+	if m != nil {
+		return m.LastIndex
+	}
+	return 0
+}
+
+func (m *SnapshotRequest) GetLastTerm() uint64 {
+	if m != nil {
+		return m.LastTerm
+	}
+	return 0
+}
+
+func (m *SnapshotRequest) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+// replica acknowledges a snapshot
+type SnapshotReply struct {
+	Term                 uint64   `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SnapshotReply) Reset()         { *m = SnapshotReply{} }
+func (m *SnapshotReply) String() string { return proto.CompactTextString(m) }
+func (*SnapshotReply) ProtoMessage()    {}
+func (*SnapshotReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_5a784b235fc18c79, []int{21}
+}
+func (m *SnapshotReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SnapshotReply.Unmarshal(m, b)
+}
+func (m *SnapshotReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SnapshotReply.Marshal(b, m, deterministic)
+}
+func (dst *SnapshotReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SnapshotReply.Merge(dst, src)
+}
+func (m *SnapshotReply) XXX_Size() int {
+	return xxx_messageInfo_SnapshotReply.Size(m)
+}
+func (m *SnapshotReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_SnapshotReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SnapshotReply proto.InternalMessageInfo
+
+func (m *SnapshotReply) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*Data)(nil), "proto.Data")
+	proto.RegisterType((*JobID)(nil), "proto.JobID")
+	proto.RegisterType((*Record)(nil), "proto.Record")
+	proto.RegisterType((*Empty)(nil), "proto.Empty")
+	proto.RegisterType((*Ack)(nil), "proto.Ack")
+	proto.RegisterType((*Leases)(nil), "proto.Leases")
+	proto.RegisterType((*LeaseStatus)(nil), "proto.LeaseStatus")
+	proto.RegisterType((*Renewal)(nil), "proto.Renewal")
+	proto.RegisterType((*JobProgress)(nil), "proto.JobProgress")
+	proto.RegisterType((*ListRequest)(nil), "proto.ListRequest")
+	proto.RegisterType((*TokenPage)(nil), "proto.TokenPage")
+	proto.RegisterType((*TokenState)(nil), "proto.TokenState")
+	proto.RegisterType((*TokenStates)(nil), "proto.TokenStates")
+	proto.RegisterType((*ImportRequest)(nil), "proto.ImportRequest")
+	proto.RegisterType((*LeaderHint)(nil), "proto.LeaderHint")
+	proto.RegisterType((*Entry)(nil), "proto.Entry")
+	proto.RegisterType((*VoteRequest)(nil), "proto.VoteRequest")
+	proto.RegisterType((*VoteReply)(nil), "proto.VoteReply")
+	proto.RegisterType((*AppendRequest)(nil), "proto.AppendRequest")
+	proto.RegisterType((*AppendReply)(nil), "proto.AppendReply")
+	proto.RegisterType((*SnapshotRequest)(nil), "proto.SnapshotRequest")
+	proto.RegisterType((*SnapshotReply)(nil), "proto.SnapshotReply")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// TokensClient is the client API for Tokens service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type TokensClient interface {
+	// client initiates Get() to request a list of tokens
+	Get(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*Data, error)
+	// client calls Done() acknowledging that the job is done
+	Done(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*Ack, error)
+	// client requests server to stream back results collected for a job
+	Results(ctx context.Context, in *JobID, opts ...grpc.CallOption) (Tokens_ResultsClient, error)
+	// client calls Reset() to reinit the server meta-data and book keeping state
+	Reset(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error)
+	// client requests server to Rescan() the folder to repopulate list of tokens
+	Rescan(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error)
+	// client requests server to shuffle the token list
+	Shuffle(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error)
+	// client requests server the spit out list of tokens regardless of jobID and other meta-data
+	// lists that do not fit into a single message need List()
+	Show(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Data, error)
+	// client requests server to stream the tokens that pass a filter page by page
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (Tokens_ListClient, error)
+	// client requests job que status
+	HeartBeat(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*Ack, error)
+	// client renews all of its leases of a job at once
+	HeartBeats(ctx context.Context, in *Leases, opts ...grpc.CallOption) (*Renewal, error)
+	// client puts the server into drain, Get() grants no new leases across all jobs
+	// the ack counts leases still outstanding and its status is true once none remain
+	Drain(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error)
+	// client takes the server out of drain
+	Resume(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error)
+	// client requests the progress of a job
+	Progress(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*JobProgress, error)
+	// client requests server to stream the state of each token of a job
+	Export(ctx context.Context, in *JobID, opts ...grpc.CallOption) (Tokens_ExportClient, error)
+	// client creates a job from exported token states, the ack counts tokens left to dispatch
+	Import(ctx context.Context, opts ...grpc.CallOption) (Tokens_ImportClient, error)
+}
+
+type tokensClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewTokensClient(cc *grpc.ClientConn) TokensClient {
+	return &tokensClient{cc}
+}
+
+func (c *tokensClient) Get(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*Data, error) {
+	out := new(Data)
+	err := c.cc.Invoke(ctx, "/proto.Tokens/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokensClient) Done(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	err := c.cc.Invoke(ctx, "/proto.Tokens/Done", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokensClient) Results(ctx context.Context, in *JobID, opts ...grpc.CallOption) (Tokens_ResultsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Tokens_serviceDesc.Streams[0], "/proto.Tokens/Results", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tokensResultsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Tokens_ResultsClient interface {
+	Recv() (*Record, error)
+	grpc.ClientStream
+}
+
+type tokensResultsClient struct {
+	grpc.ClientStream
+}
+
+func (x *tokensResultsClient) Recv() (*Record, error) {
+	m := new(Record)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *tokensClient) Reset(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	err := c.cc.Invoke(ctx, "/proto.Tokens/Reset", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokensClient) Rescan(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	err := c.cc.Invoke(ctx, "/proto.Tokens/Rescan", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokensClient) Shuffle(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	err := c.cc.Invoke(ctx, "/proto.Tokens/Shuffle", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokensClient) Show(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Data, error) {
+	out := new(Data)
+	err := c.cc.Invoke(ctx, "/proto.Tokens/Show", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokensClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (Tokens_ListClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Tokens_serviceDesc.Streams[1], "/proto.Tokens/List", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tokensListClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
 
-type TokensClient interface {
-	// client initiates Get() to request a list of tokens
-	Get(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*Data, error)
-	// client calls Done() acknowledging that the job is done
-	Done(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*Ack, error)
-	// client calls Reset() to reinit the server meta-data and book keeping state
-	Reset(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error)
-	// client requests server to Rescan() the folder to repopulate list of tokens
-	Rescan(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error)
-	// client requests server to shuffle the token list
-	Shuffle(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error)
-	// client requests server the spit out list of tokens regardless of jobID and other meta-data
-	Show(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Data, error)
-	// client requests job que status
-	HeartBeat(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*Ack, error)
+type Tokens_ListClient interface {
+	Recv() (*TokenPage, error)
+	grpc.ClientStream
 }
 
-type tokensClient struct {
-	cc *grpc.ClientConn
+type tokensListClient struct {
+	grpc.ClientStream
 }
 
-func NewTokensClient(cc *grpc.ClientConn) TokensClient {
-	return &tokensClient{cc}
+func (x *tokensListClient) Recv() (*TokenPage, error) {
+	m := new(TokenPage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
-func (c *tokensClient) Get(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*Data, error) {
-	out := new(Data)
-	err := grpc.Invoke(ctx, "/proto.Tokens/Get", in, out, c.cc, opts...)
+func (c *tokensClient) HeartBeat(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	err := c.cc.Invoke(ctx, "/proto.Tokens/HeartBeat", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *tokensClient) Done(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*Ack, error) {
-	out := new(Ack)
-	err := grpc.Invoke(ctx, "/proto.Tokens/Done", in, out, c.cc, opts...)
+func (c *tokensClient) HeartBeats(ctx context.Context, in *Leases, opts ...grpc.CallOption) (*Renewal, error) {
+	out := new(Renewal)
+	err := c.cc.Invoke(ctx, "/proto.Tokens/HeartBeats", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *tokensClient) Reset(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error) {
+func (c *tokensClient) Drain(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error) {
 	out := new(Ack)
-	err := grpc.Invoke(ctx, "/proto.Tokens/Reset", in, out, c.cc, opts...)
+	err := c.cc.Invoke(ctx, "/proto.Tokens/Drain", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *tokensClient) Rescan(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error) {
+func (c *tokensClient) Resume(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error) {
 	out := new(Ack)
-	err := grpc.Invoke(ctx, "/proto.Tokens/Rescan", in, out, c.cc, opts...)
+	err := c.cc.Invoke(ctx, "/proto.Tokens/Resume", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *tokensClient) Shuffle(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Ack, error) {
-	out := new(Ack)
-	err := grpc.Invoke(ctx, "/proto.Tokens/Shuffle", in, out, c.cc, opts...)
+func (c *tokensClient) Progress(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*JobProgress, error) {
+	out := new(JobProgress)
+	err := c.cc.Invoke(ctx, "/proto.Tokens/Progress", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *tokensClient) Show(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Data, error) {
-	out := new(Data)
-	err := grpc.Invoke(ctx, "/proto.Tokens/Show", in, out, c.cc, opts...)
+func (c *tokensClient) Export(ctx context.Context, in *JobID, opts ...grpc.CallOption) (Tokens_ExportClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Tokens_serviceDesc.Streams[2], "/proto.Tokens/Export", opts...)
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	x := &tokensExportClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
 }
 
-func (c *tokensClient) HeartBeat(ctx context.Context, in *JobID, opts ...grpc.CallOption) (*Ack, error) {
-	out := new(Ack)
-	err := grpc.Invoke(ctx, "/proto.Tokens/HeartBeat", in, out, c.cc, opts...)
+type Tokens_ExportClient interface {
+	Recv() (*TokenStates, error)
+	grpc.ClientStream
+}
+
+type tokensExportClient struct {
+	grpc.ClientStream
+}
+
+func (x *tokensExportClient) Recv() (*TokenStates, error) {
+	m := new(TokenStates)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *tokensClient) Import(ctx context.Context, opts ...grpc.CallOption) (Tokens_ImportClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Tokens_serviceDesc.Streams[3], "/proto.Tokens/Import", opts...)
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	x := &tokensImportClient{stream}
+	return x, nil
+}
+
+type Tokens_ImportClient interface {
+	Send(*ImportRequest) error
+	CloseAndRecv() (*Ack, error)
+	grpc.ClientStream
 }
 
-// Server API for Tokens service
+type tokensImportClient struct {
+	grpc.ClientStream
+}
+
+func (x *tokensImportClient) Send(m *ImportRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *tokensImportClient) CloseAndRecv() (*Ack, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Ack)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
 
+// TokensServer is the server API for Tokens service.
 type TokensServer interface {
 	// client initiates Get() to request a list of tokens
 	Get(context.Context, *JobID) (*Data, error)
 	// client calls Done() acknowledging that the job is done
 	Done(context.Context, *JobID) (*Ack, error)
+	// client requests server to stream back results collected for a job
+	Results(*JobID, Tokens_ResultsServer) error
 	// client calls Reset() to reinit the server meta-data and book keeping state
 	Reset(context.Context, *Empty) (*Ack, error)
 	// client requests server to Rescan() the folder to repopulate list of tokens
@@ -248,9 +1693,25 @@ type TokensServer interface {
 	// client requests server to shuffle the token list
 	Shuffle(context.Context, *Empty) (*Ack, error)
 	// client requests server the spit out list of tokens regardless of jobID and other meta-data
+	// lists that do not fit into a single message need List()
 	Show(context.Context, *Empty) (*Data, error)
+	// client requests server to stream the tokens that pass a filter page by page
+	List(*ListRequest, Tokens_ListServer) error
 	// client requests job que status
 	HeartBeat(context.Context, *JobID) (*Ack, error)
+	// client renews all of its leases of a job at once
+	HeartBeats(context.Context, *Leases) (*Renewal, error)
+	// client puts the server into drain, Get() grants no new leases across all jobs
+	// the ack counts leases still outstanding and its status is true once none remain
+	Drain(context.Context, *Empty) (*Ack, error)
+	// client takes the server out of drain
+	Resume(context.Context, *Empty) (*Ack, error)
+	// client requests the progress of a job
+	Progress(context.Context, *JobID) (*JobProgress, error)
+	// client requests server to stream the state of each token of a job
+	Export(*JobID, Tokens_ExportServer) error
+	// client creates a job from exported token states, the ack counts tokens left to dispatch
+	Import(Tokens_ImportServer) error
 }
 
 func RegisterTokensServer(s *grpc.Server, srv TokensServer) {
@@ -293,6 +1754,27 @@ func _Tokens_Done_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Tokens_Results_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(JobID)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TokensServer).Results(m, &tokensResultsServer{stream})
+}
+
+type Tokens_ResultsServer interface {
+	Send(*Record) error
+	grpc.ServerStream
+}
+
+type tokensResultsServer struct {
+	grpc.ServerStream
+}
+
+func (x *tokensResultsServer) Send(m *Record) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _Tokens_Reset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Empty)
 	if err := dec(in); err != nil {
@@ -365,6 +1847,27 @@ func _Tokens_Show_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Tokens_List_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TokensServer).List(m, &tokensListServer{stream})
+}
+
+type Tokens_ListServer interface {
+	Send(*TokenPage) error
+	grpc.ServerStream
+}
+
+type tokensListServer struct {
+	grpc.ServerStream
+}
+
+func (x *tokensListServer) Send(m *TokenPage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _Tokens_HeartBeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(JobID)
 	if err := dec(in); err != nil {
@@ -383,6 +1886,125 @@ func _Tokens_HeartBeat_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Tokens_HeartBeats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Leases)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokensServer).HeartBeats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Tokens/HeartBeats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokensServer).HeartBeats(ctx, req.(*Leases))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tokens_Drain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokensServer).Drain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Tokens/Drain",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokensServer).Drain(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tokens_Resume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokensServer).Resume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Tokens/Resume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokensServer).Resume(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tokens_Progress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokensServer).Progress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Tokens/Progress",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokensServer).Progress(ctx, req.(*JobID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tokens_Export_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(JobID)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TokensServer).Export(m, &tokensExportServer{stream})
+}
+
+type Tokens_ExportServer interface {
+	Send(*TokenStates) error
+	grpc.ServerStream
+}
+
+type tokensExportServer struct {
+	grpc.ServerStream
+}
+
+func (x *tokensExportServer) Send(m *TokenStates) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Tokens_Import_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TokensServer).Import(&tokensImportServer{stream})
+}
+
+type Tokens_ImportServer interface {
+	SendAndClose(*Ack) error
+	Recv() (*ImportRequest, error)
+	grpc.ServerStream
+}
+
+type tokensImportServer struct {
+	grpc.ServerStream
+}
+
+func (x *tokensImportServer) SendAndClose(m *Ack) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *tokensImportServer) Recv() (*ImportRequest, error) {
+	m := new(ImportRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 var _Tokens_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "proto.Tokens",
 	HandlerType: (*TokensServer)(nil),
@@ -415,31 +2037,266 @@ var _Tokens_serviceDesc = grpc.ServiceDesc{
 			MethodName: "HeartBeat",
 			Handler:    _Tokens_HeartBeat_Handler,
 		},
+		{
+			MethodName: "HeartBeats",
+			Handler:    _Tokens_HeartBeats_Handler,
+		},
+		{
+			MethodName: "Drain",
+			Handler:    _Tokens_Drain_Handler,
+		},
+		{
+			MethodName: "Resume",
+			Handler:    _Tokens_Resume_Handler,
+		},
+		{
+			MethodName: "Progress",
+			Handler:    _Tokens_Progress_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Results",
+			Handler:       _Tokens_Results_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "List",
+			Handler:       _Tokens_List_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Export",
+			Handler:       _Tokens_Export_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Import",
+			Handler:       _Tokens_Import_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "config.proto",
+}
+
+// RaftClient is the client API for Raft service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type RaftClient interface {
+	// candidate requests votes to become leader
+	RequestVote(ctx context.Context, in *VoteRequest, opts ...grpc.CallOption) (*VoteReply, error)
+	// leader replicates its log and asserts leadership
+	AppendEntries(ctx context.Context, in *AppendRequest, opts ...grpc.CallOption) (*AppendReply, error)
+	// leader replaces the state of a replica that is too far behind
+	InstallSnapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*SnapshotReply, error)
+}
+
+type raftClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewRaftClient(cc *grpc.ClientConn) RaftClient {
+	return &raftClient{cc}
+}
+
+func (c *raftClient) RequestVote(ctx context.Context, in *VoteRequest, opts ...grpc.CallOption) (*VoteReply, error) {
+	out := new(VoteReply)
+	err := c.cc.Invoke(ctx, "/proto.Raft/RequestVote", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftClient) AppendEntries(ctx context.Context, in *AppendRequest, opts ...grpc.CallOption) (*AppendReply, error) {
+	out := new(AppendReply)
+	err := c.cc.Invoke(ctx, "/proto.Raft/AppendEntries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftClient) InstallSnapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*SnapshotReply, error) {
+	out := new(SnapshotReply)
+	err := c.cc.Invoke(ctx, "/proto.Raft/InstallSnapshot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RaftServer is the server API for Raft service.
+type RaftServer interface {
+	// candidate requests votes to become leader
+	RequestVote(context.Context, *VoteRequest) (*VoteReply, error)
+	// leader replicates its log and asserts leadership
+	AppendEntries(context.Context, *AppendRequest) (*AppendReply, error)
+	// leader replaces the state of a replica that is too far behind
+	InstallSnapshot(context.Context, *SnapshotRequest) (*SnapshotReply, error)
+}
+
+func RegisterRaftServer(s *grpc.Server, srv RaftServer) {
+	s.RegisterService(&_Raft_serviceDesc, srv)
+}
+
+func _Raft_RequestVote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftServer).RequestVote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Raft/RequestVote",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftServer).RequestVote(ctx, req.(*VoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Raft_AppendEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftServer).AppendEntries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Raft/AppendEntries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftServer).AppendEntries(ctx, req.(*AppendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Raft_InstallSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftServer).InstallSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Raft/InstallSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftServer).InstallSnapshot(ctx, req.(*SnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Raft_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.Raft",
+	HandlerType: (*RaftServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RequestVote",
+			Handler:    _Raft_RequestVote_Handler,
+		},
+		{
+			MethodName: "AppendEntries",
+			Handler:    _Raft_AppendEntries_Handler,
+		},
+		{
+			MethodName: "InstallSnapshot",
+			Handler:    _Raft_InstallSnapshot_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "config.proto",
 }
 
-func init() { proto1.RegisterFile("config.proto", fileDescriptor0) }
-
-var fileDescriptor0 = []byte{
-	// 276 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x8f, 0xd1, 0x4a, 0xc3, 0x30,
-	0x14, 0x40, 0x97, 0x76, 0xe9, 0xec, 0x75, 0x88, 0xe4, 0x41, 0x8a, 0x20, 0x94, 0x4c, 0xb1, 0x20,
-	0x0c, 0xd1, 0x2f, 0x98, 0x54, 0x5c, 0x7d, 0x4c, 0x7d, 0x97, 0xb4, 0xdc, 0xda, 0x52, 0x4d, 0xc6,
-	0x92, 0x21, 0xdb, 0x57, 0xf8, 0xc9, 0xd2, 0xac, 0x0f, 0x52, 0xa1, 0x4f, 0xc9, 0x49, 0x0e, 0x97,
-	0x73, 0x61, 0x5e, 0x6a, 0x55, 0x35, 0x1f, 0xcb, 0xcd, 0x56, 0x5b, 0xcd, 0xa8, 0x3b, 0xf8, 0x3d,
-	0x4c, 0x53, 0x69, 0x25, 0xbb, 0x80, 0xc0, 0xea, 0x16, 0x95, 0x89, 0x48, 0xec, 0x27, 0xa1, 0xe8,
-	0x89, 0x9d, 0x83, 0xdf, 0xe2, 0x3e, 0xf2, 0x62, 0x92, 0x84, 0xa2, 0xbb, 0xf2, 0x35, 0xd0, 0x57,
-	0x5d, 0x64, 0x29, 0x3b, 0x03, 0x2f, 0x4b, 0x23, 0xe2, 0x7e, 0xbc, 0x2c, 0xfd, 0xaf, 0xb2, 0x2b,
-	0x80, 0x42, 0xda, 0xb2, 0x7e, 0x37, 0xcd, 0x01, 0x23, 0x3f, 0x26, 0x09, 0x15, 0xa1, 0x7b, 0xc9,
-	0x9b, 0x03, 0xf2, 0x19, 0xd0, 0xe7, 0xaf, 0x8d, 0xdd, 0xf3, 0x3b, 0xf0, 0x57, 0x65, 0xcb, 0xe6,
-	0x40, 0x94, 0x9b, 0x47, 0x05, 0x51, 0x5d, 0x91, 0xb1, 0xd2, 0xee, 0x8c, 0x9b, 0x78, 0x22, 0x7a,
-	0x7a, 0xf8, 0xf1, 0x20, 0x78, 0x3b, 0xc6, 0x71, 0xf0, 0x5f, 0xd0, 0xb2, 0xf9, 0x71, 0xa5, 0xa5,
-	0xcb, 0xba, 0x3c, 0xed, 0xa9, 0x5b, 0x8b, 0x4f, 0x18, 0x87, 0x69, 0xaa, 0x15, 0x0e, 0x24, 0xe8,
-	0x69, 0x55, 0xb6, 0x7c, 0xc2, 0x16, 0x40, 0x05, 0x9a, 0x3f, 0x93, 0x5c, 0xd6, 0x40, 0xba, 0x86,
-	0x40, 0xa0, 0x29, 0xa5, 0x1a, 0xb5, 0x6e, 0x60, 0x96, 0xd7, 0xbb, 0xaa, 0xfa, 0xc4, 0x51, 0x6d,
-	0x01, 0xd3, 0xbc, 0xd6, 0xdf, 0x03, 0x67, 0x90, 0x7e, 0x0b, 0xe1, 0x1a, 0xe5, 0xd6, 0x3e, 0xa1,
-	0xb4, 0x63, 0xfd, 0x45, 0xe0, 0xe0, 0xf1, 0x37, 0x00, 0x00, 0xff, 0xff, 0x59, 0x43, 0x8a, 0x4f,
-	0xe2, 0x01, 0x00, 0x00,
+func init() { proto.RegisterFile("config.proto", fileDescriptor_config_5a784b235fc18c79) }
+
+var fileDescriptor_config_5a784b235fc18c79 = []byte{
+	// 1277 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x56, 0xcb, 0x6e, 0xdb, 0x46,
+	0x17, 0x36, 0x45, 0x52, 0x97, 0x43, 0xcb, 0x71, 0x06, 0x46, 0x40, 0xe8, 0xff, 0xdb, 0xba, 0x74,
+	0xd2, 0x38, 0x29, 0x62, 0x04, 0x2e, 0x8a, 0xb6, 0xe8, 0xca, 0x89, 0xdc, 0xc4, 0x81, 0x81, 0x04,
+	0x94, 0xd1, 0x45, 0xbb, 0x10, 0x46, 0xd2, 0x58, 0x26, 0x4c, 0x71, 0xd8, 0x99, 0x51, 0x12, 0xe5,
+	0x0d, 0xba, 0xe8, 0x13, 0xf4, 0x59, 0xfa, 0x06, 0x5d, 0xf5, 0x69, 0xda, 0x5d, 0x31, 0x67, 0x86,
+	0x17, 0xc9, 0x96, 0x5a, 0x74, 0x25, 0x9e, 0x8f, 0x9f, 0xce, 0xe5, 0x9b, 0x73, 0x0e, 0x07, 0xb6,
+	0xc7, 0x3c, 0xbb, 0x4c, 0xa6, 0x47, 0xb9, 0xe0, 0x8a, 0x13, 0x1f, 0x7f, 0xa2, 0x11, 0x78, 0x7d,
+	0xaa, 0x28, 0xb9, 0x07, 0x4d, 0xc5, 0xaf, 0x59, 0x26, 0x43, 0x67, 0xdf, 0x3d, 0xec, 0xc4, 0xd6,
+	0x22, 0xbb, 0xe0, 0x5e, 0xb3, 0x45, 0xd8, 0xd8, 0x77, 0x0e, 0x3b, 0xb1, 0x7e, 0x24, 0x7b, 0xe0,
+	0x8f, 0x16, 0x8a, 0xc9, 0xd0, 0xdd, 0x77, 0x0e, 0xdd, 0xd8, 0x18, 0xa4, 0x07, 0xed, 0x89, 0xa0,
+	0x49, 0x96, 0x64, 0xd3, 0xd0, 0xdb, 0x77, 0x0e, 0xdb, 0x71, 0x69, 0x47, 0x7f, 0x39, 0xe0, 0xbf,
+	0xe2, 0xa3, 0xb3, 0x3e, 0xd9, 0x81, 0xc6, 0x59, 0x3f, 0x74, 0xd0, 0x59, 0xe3, 0xac, 0x7f, 0x8b,
+	0xf7, 0x8f, 0x00, 0x46, 0x54, 0x8d, 0xaf, 0x86, 0x32, 0xf9, 0xc0, 0x30, 0x84, 0x1f, 0x77, 0x10,
+	0x19, 0x24, 0x1f, 0x98, 0x4e, 0xf3, 0x1d, 0x17, 0xd7, 0x4c, 0x60, 0x90, 0x4e, 0x6c, 0x2d, 0xf2,
+	0x09, 0x04, 0x3a, 0x8f, 0xe1, 0x68, 0x3e, 0x99, 0x32, 0x15, 0xfa, 0x98, 0x1a, 0x68, 0xe8, 0x19,
+	0x22, 0x3a, 0x6b, 0x79, 0x45, 0xc5, 0x24, 0x6c, 0xa2, 0x4b, 0x63, 0xe8, 0x68, 0xd9, 0x7c, 0x36,
+	0x44, 0x43, 0x86, 0x2d, 0x13, 0x2d, 0x9b, 0xcf, 0x06, 0x08, 0x90, 0x87, 0xd0, 0x12, 0x6c, 0xcc,
+	0xf5, 0xbb, 0xf6, 0xbe, 0x7b, 0x18, 0x1c, 0x77, 0x8d, 0x78, 0x47, 0x31, 0xa2, 0x71, 0xf1, 0x96,
+	0x84, 0x9a, 0xf8, 0xd3, 0x9c, 0x49, 0x15, 0x76, 0x30, 0xaf, 0xc2, 0x8c, 0x5e, 0x42, 0xd3, 0x90,
+	0x75, 0x06, 0xa8, 0xa9, 0x2d, 0xdf, 0x18, 0x84, 0x80, 0x37, 0xa1, 0x8a, 0xa2, 0x04, 0xdb, 0x31,
+	0x3e, 0x6b, 0x26, 0x13, 0x82, 0x0b, 0x2c, 0xbf, 0x13, 0x1b, 0x23, 0x6a, 0x81, 0x7f, 0x3a, 0xcb,
+	0xd5, 0x22, 0xfa, 0x11, 0xdc, 0x93, 0xf1, 0x35, 0xd9, 0x06, 0xc7, 0xf8, 0xf2, 0x63, 0x27, 0xd3,
+	0xc2, 0x48, 0x45, 0xd5, 0x5c, 0xa2, 0xa7, 0x76, 0x6c, 0x2d, 0xed, 0x3f, 0xe5, 0x52, 0xa1, 0xab,
+	0x76, 0x8c, 0xcf, 0x1b, 0xcf, 0xaa, 0x0f, 0xcd, 0x73, 0x46, 0x25, 0x93, 0x37, 0xce, 0xaa, 0x92,
+	0xbe, 0xb1, 0x24, 0x3d, 0x01, 0xef, 0x9a, 0x2d, 0x74, 0x3b, 0xe8, 0xbe, 0xc1, 0xe7, 0xe8, 0x2b,
+	0x08, 0xd0, 0xcb, 0xc0, 0x24, 0x61, 0x8f, 0xd9, 0xa9, 0x8e, 0x79, 0x4d, 0xba, 0x11, 0x87, 0x56,
+	0xcc, 0x32, 0xf6, 0x8e, 0xa6, 0xe4, 0x31, 0x34, 0x53, 0xcc, 0x04, 0x3b, 0x32, 0x38, 0x26, 0x56,
+	0xfb, 0x9a, 0xe3, 0xd8, 0x32, 0xc8, 0xff, 0xa1, 0x33, 0xe6, 0xb3, 0x3c, 0x65, 0x8a, 0x4d, 0xac,
+	0xc7, 0x0a, 0x58, 0xaa, 0xd7, 0x5d, 0xa9, 0xf7, 0xf7, 0x06, 0x04, 0xaf, 0xf8, 0xe8, 0x8d, 0xe0,
+	0x53, 0xc1, 0xe4, 0xad, 0x55, 0xdb, 0xb9, 0x68, 0x60, 0x4f, 0x59, 0x8b, 0x7c, 0x0c, 0x30, 0x49,
+	0x64, 0xae, 0x1b, 0x93, 0x4d, 0xec, 0x28, 0xd4, 0x10, 0xf2, 0x08, 0x76, 0x0d, 0x73, 0x58, 0x25,
+	0xe6, 0x21, 0xeb, 0x8e, 0xc1, 0x9f, 0x97, 0xe9, 0x3d, 0x80, 0x1d, 0x53, 0xc6, 0x70, 0x2a, 0x68,
+	0xa6, 0x89, 0xa6, 0x7d, 0xbb, 0x06, 0x7d, 0x61, 0x40, 0xf2, 0x39, 0xdc, 0xb5, 0x34, 0xc1, 0xa8,
+	0x94, 0xc9, 0x34, 0x63, 0xa6, 0x9b, 0xdd, 0x78, 0xd7, 0xbc, 0x88, 0x4b, 0x5c, 0x87, 0xb7, 0xe4,
+	0x2a, 0x7c, 0xcb, 0x84, 0x37, 0x78, 0x15, 0xfe, 0x09, 0x10, 0x4b, 0xe5, 0x73, 0x25, 0x15, 0xcd,
+	0x26, 0x5a, 0xa7, 0x36, 0x92, 0x6d, 0xc4, 0xd7, 0xd5, 0x8b, 0x65, 0xa9, 0x3b, 0x2b, 0x52, 0x47,
+	0xbf, 0x3a, 0x10, 0x9c, 0x27, 0x52, 0xc5, 0xa6, 0xfd, 0x6f, 0xc8, 0xa9, 0xc7, 0x50, 0x51, 0xc5,
+	0x6c, 0x0f, 0x19, 0x43, 0x8b, 0x9c, 0x0b, 0x76, 0x99, 0xbc, 0xb7, 0x1d, 0x6f, 0x2d, 0xdd, 0x5a,
+	0xd3, 0x94, 0x8f, 0xec, 0xac, 0xe3, 0xb3, 0x1e, 0xd9, 0x31, 0x9f, 0x67, 0x6a, 0xc8, 0xb3, 0x74,
+	0x11, 0xfa, 0x45, 0x02, 0xf3, 0x4c, 0xbd, 0xce, 0xd2, 0x05, 0xf9, 0x1f, 0x74, 0x72, 0x3a, 0x65,
+	0x66, 0x7d, 0x98, 0x59, 0x6f, 0x6b, 0x40, 0x6f, 0x8f, 0xe8, 0x1b, 0xe8, 0x5c, 0x68, 0xf1, 0xdf,
+	0xd0, 0x29, 0x5b, 0xbb, 0xf1, 0xf6, 0xc0, 0x47, 0x77, 0xf6, 0xc0, 0x8d, 0x11, 0xbd, 0x05, 0xc0,
+	0xbf, 0x0e, 0x30, 0xe1, 0xdb, 0x67, 0x79, 0x6d, 0x71, 0x76, 0x6e, 0xdc, 0xa5, 0xb9, 0xb1, 0x43,
+	0xe1, 0x55, 0x43, 0x41, 0xc0, 0x53, 0xc9, 0x8c, 0xd9, 0xe3, 0xc7, 0xe7, 0xe8, 0x6b, 0x08, 0xaa,
+	0xb8, 0x92, 0x3c, 0x5a, 0x4a, 0x3a, 0x38, 0xbe, 0x6b, 0x87, 0xa2, 0xe2, 0x14, 0x75, 0x44, 0x33,
+	0xe8, 0x9e, 0xcd, 0x72, 0x2e, 0xd6, 0x9e, 0xc5, 0xa7, 0xb0, 0x2d, 0x98, 0x12, 0x8b, 0xe1, 0x25,
+	0x4d, 0xd2, 0x72, 0x6e, 0x02, 0xc4, 0xbe, 0x43, 0xa8, 0x16, 0xce, 0xfd, 0xa7, 0x70, 0xf7, 0x01,
+	0xce, 0x19, 0x9d, 0x30, 0xf1, 0x32, 0xc9, 0x94, 0x2e, 0x3a, 0x45, 0xcb, 0xc6, 0xb3, 0x56, 0x74,
+	0x0a, 0xfe, 0x69, 0xa6, 0x04, 0x7e, 0x45, 0x92, 0x6c, 0xc2, 0xde, 0xe3, 0x7b, 0x2f, 0x36, 0x06,
+	0x2a, 0xc0, 0xc4, 0x0c, 0x53, 0xf1, 0x62, 0x7c, 0x2e, 0x37, 0xa4, 0x5b, 0x6d, 0xc8, 0xe8, 0x67,
+	0x07, 0x82, 0xef, 0xb9, 0x62, 0x45, 0x69, 0xc5, 0xff, 0x9c, 0xda, 0xff, 0x74, 0xa3, 0xea, 0x9e,
+	0x9d, 0x54, 0x27, 0x52, 0x01, 0xe4, 0x3e, 0xec, 0xa4, 0x54, 0xaa, 0x61, 0xca, 0xa7, 0x43, 0x93,
+	0x88, 0x8b, 0xff, 0xdd, 0xd6, 0xe8, 0x39, 0x9f, 0x9e, 0x61, 0x3e, 0x11, 0x74, 0x4b, 0x16, 0x06,
+	0xf0, 0x90, 0x14, 0x58, 0xd2, 0x05, 0x13, 0x33, 0xdd, 0x54, 0x26, 0x95, 0x3c, 0x5d, 0xdc, 0x9a,
+	0x48, 0x08, 0xad, 0x62, 0xb0, 0x8d, 0xc4, 0x85, 0x19, 0xfd, 0xe1, 0x40, 0xf7, 0x24, 0xcf, 0x59,
+	0x36, 0xd9, 0x54, 0x48, 0xa5, 0x65, 0xa3, 0xae, 0xa5, 0x2e, 0x21, 0x17, 0xec, 0xed, 0xcd, 0x12,
+	0x34, 0x5a, 0x2f, 0xa1, 0x64, 0xd5, 0x4b, 0xb0, 0x24, 0x5d, 0x02, 0xf9, 0x0c, 0x5a, 0x2c, 0x53,
+	0x22, 0x61, 0x32, 0xf4, 0xf1, 0x9c, 0xb7, 0xed, 0x39, 0xe3, 0x59, 0xc5, 0xc5, 0x4b, 0x72, 0x00,
+	0x5d, 0x13, 0x5b, 0x6f, 0x95, 0x59, 0xa2, 0xc2, 0xa6, 0xd5, 0x0c, 0xc1, 0xe7, 0x88, 0x45, 0x3f,
+	0x40, 0x50, 0xd4, 0xb4, 0x41, 0x11, 0x39, 0x1f, 0x8f, 0x99, 0x2c, 0xd6, 0x7f, 0x61, 0xea, 0xe9,
+	0x46, 0xc1, 0xeb, 0xf5, 0x74, 0x34, 0x82, 0xc5, 0x44, 0xbf, 0x38, 0x70, 0x67, 0x90, 0xd1, 0x5c,
+	0x5e, 0x71, 0xf5, 0x5f, 0x24, 0xdb, 0xec, 0x5e, 0x2f, 0x0f, 0x7c, 0x5d, 0xd3, 0xa9, 0xad, 0x81,
+	0x8b, 0x7a, 0x1f, 0xfa, 0xb5, 0x3e, 0x3c, 0x80, 0x6e, 0x95, 0xce, 0x9a, 0x6a, 0x8f, 0xff, 0xf4,
+	0xa0, 0x79, 0x61, 0x76, 0x4b, 0x04, 0xee, 0x0b, 0xa6, 0x48, 0x21, 0x2f, 0x5e, 0x8a, 0x7a, 0x81,
+	0xb5, 0xf4, 0x3d, 0x2c, 0xda, 0x22, 0x11, 0x78, 0x7d, 0x9e, 0xb1, 0x15, 0x12, 0x58, 0xeb, 0x64,
+	0x7c, 0x1d, 0x6d, 0x91, 0xc7, 0xfa, 0x33, 0x29, 0xe7, 0xa9, 0x92, 0x2b, 0xb4, 0xe5, 0x0b, 0x4a,
+	0xb4, 0xf5, 0xd4, 0x21, 0x07, 0xe0, 0xc7, 0x4c, 0xd6, 0xa2, 0xe2, 0x2d, 0x62, 0xc5, 0xe1, 0x7d,
+	0x7d, 0x4d, 0x91, 0x63, 0x9a, 0x6d, 0x64, 0x3d, 0x80, 0xd6, 0xe0, 0x6a, 0x7e, 0x79, 0x99, 0xb2,
+	0x8d, 0xb4, 0x03, 0xf0, 0x06, 0x57, 0xfc, 0xdd, 0x0a, 0x67, 0xa5, 0xcc, 0xa7, 0xe0, 0xe9, 0x0f,
+	0x05, 0x29, 0x3f, 0xeb, 0xd5, 0x57, 0xa3, 0xb7, 0x5b, 0x5f, 0x33, 0x7a, 0x59, 0x63, 0x21, 0x0f,
+	0xa1, 0xf3, 0x92, 0x51, 0xa1, 0x9e, 0x31, 0xaa, 0x36, 0xaa, 0xf3, 0x04, 0xa0, 0x24, 0x4a, 0xd2,
+	0xad, 0xdf, 0x1b, 0x64, 0x6f, 0xa7, 0x54, 0x08, 0xaf, 0x19, 0x98, 0xae, 0xdf, 0xd7, 0xd7, 0x81,
+	0x7f, 0x21, 0xd0, 0x7c, 0xb6, 0xb9, 0xf2, 0x23, 0x68, 0x97, 0x37, 0x89, 0xe5, 0x0c, 0x49, 0x65,
+	0x15, 0x0c, 0xe4, 0x37, 0x4f, 0xdf, 0xeb, 0x1d, 0xbd, 0x86, 0x5d, 0x5b, 0xfd, 0x28, 0xc1, 0x11,
+	0x34, 0xcd, 0x4e, 0x27, 0x7b, 0x96, 0xb1, 0xb4, 0xe2, 0x97, 0xb3, 0x39, 0x74, 0x8e, 0x7f, 0x73,
+	0xc0, 0x8b, 0xe9, 0xa5, 0x22, 0x5f, 0x42, 0x60, 0x39, 0x7a, 0x57, 0x95, 0xa2, 0xd7, 0x76, 0x68,
+	0x6f, 0x77, 0x09, 0xcb, 0xd3, 0x45, 0xb4, 0x45, 0xbe, 0x2d, 0xf6, 0xd3, 0xa9, 0xdd, 0x00, 0x45,
+	0xd8, 0xa5, 0xad, 0xd5, 0x23, 0x2b, 0xa8, 0xf9, 0xf3, 0x09, 0xdc, 0x39, 0xcb, 0xa4, 0xa2, 0x69,
+	0x5a, 0xcc, 0x08, 0xb9, 0x67, 0x89, 0x2b, 0x33, 0xdc, 0xdb, 0xbb, 0x81, 0xa3, 0x8b, 0x51, 0x13,
+	0xe1, 0x2f, 0xfe, 0x1e, 0x00, 0x29, 0xdf, 0x5c, 0xcb, 0xbb, 0x0c, 0x00, 0x00,
 }
@@ -19,7 +19,7 @@ DESCRIPTOR = _descriptor.FileDescriptor(
   name='config.proto',
   package='proto',
   syntax='proto3',
-  serialized_pb=_b('\n\x0c\x63onfig.proto\x12\x05proto\"#\n\x04\x44\x61ta\x12\x0e\n\x06tokens\x18\x01 \x03(\t\x12\x0b\n\x03key\x18\x02 \x01(\t\"4\n\x05JobID\x12\n\n\x02ID\x18\x01 \x01(\t\x12\x0b\n\x03key\x18\x02 \x01(\t\x12\x12\n\nbatch_size\x18\x03 \x01(\x05\"\x07\n\x05\x45mpty\" \n\x03\x41\x63k\x12\t\n\x01n\x18\x01 \x01(\x05\x12\x0e\n\x06status\x18\x02 \x01(\x08\x32\x90\x02\n\x06Tokens\x12\"\n\x03Get\x12\x0c.proto.JobID\x1a\x0b.proto.Data\"\x00\x12\"\n\x04\x44one\x12\x0c.proto.JobID\x1a\n.proto.Ack\"\x00\x12#\n\x05Reset\x12\x0c.proto.Empty\x1a\n.proto.Ack\"\x00\x12$\n\x06Rescan\x12\x0c.proto.Empty\x1a\n.proto.Ack\"\x00\x12%\n\x07Shuffle\x12\x0c.proto.Empty\x1a\n.proto.Ack\"\x00\x12#\n\x04Show\x12\x0c.proto.Empty\x1a\x0b.proto.Data\"\x00\x12\'\n\tHeartBeat\x12\x0c.proto.JobID\x1a\n.proto.Ack\"\x00\x62\x06proto3')
+  serialized_pb=_b('\n\x0c\x63onfig.proto\x12\x05proto\"D\n\x04\x44\x61ta\x12\x0e\n\x06tokens\x18\x01 \x03(\t\x12\x0b\n\x03key\x18\x02 \x01(\t\x12\r\n\x05\x62ytes\x18\x03 \x01(\x03\x12\x10\n\x08\x64raining\x18\x04 \x01(\x08\"\xad\x01\n\x05JobID\x12\n\n\x02ID\x18\x01 \x01(\t\x12\x0b\n\x03key\x18\x02 \x01(\t\x12\x12\n\nbatch_size\x18\x03 \x01(\x05\x12\x0e\n\x06worker\x18\x04 \x01(\t\x12\x13\n\x0b\x62yte_budget\x18\x05 \x01(\x03\x12\r\n\x05shard\x18\x06 \x01(\x05\x12\x12\n\nnum_shards\x18\x07 \x01(\x05\x12\x1e\n\x07records\x18\x08 \x03(\x0b\x32\r.proto.Record\x12\x0f\n\x07request\x18\t \x01(\t\"4\n\x06Record\x12\r\n\x05token\x18\x01 \x01(\t\x12\x0c\n\x04\x64\x61ta\x18\x02 \x01(\x0c\x12\r\n\x05\x65rror\x18\x03 \x01(\t\"\x07\n\x05\x45mpty\"@\n\x03\x41\x63k\x12\t\n\x01n\x18\x01 \x01(\x05\x12\x0e\n\x06status\x18\x02 \x01(\x08\x12\x0c\n\x04lost\x18\x03 \x01(\x08\x12\x10\n\x08\x64raining\x18\x04 \x01(\x08\"2\n\x06Leases\x12\n\n\x02ID\x18\x01 \x01(\t\x12\x0e\n\x06worker\x18\x02 \x01(\t\x12\x0c\n\x04keys\x18\x03 \x03(\t\"*\n\x0bLeaseStatus\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\x0e\n\x06status\x18\x02 \x01(\x08\"R\n\x07Renewal\x12\"\n\x06leases\x18\x01 \x03(\x0b\x32\x12.proto.LeaseStatus\x12\x11\n\tcompleted\x18\x02 \x01(\x08\x12\x10\n\x08\x64raining\x18\x03 \x01(\x08\"\xd3\x01\n\x0bJobProgress\x12\n\n\x02ID\x18\x01 \x01(\t\x12\x0e\n\x06tokens\x18\x02 \x01(\x03\x12\x12\n\ndispatched\x18\x03 \x01(\x03\x12\x18\n\x10tokens_completed\x18\x04 \x01(\x03\x12\x16\n\x0eleases_granted\x18\x05 \x01(\x03\x12\x19\n\x11leases_reassigned\x18\x06 \x01(\x03\x12\x18\n\x10leases_completed\x18\x07 \x01(\x03\x12\x1a\n\x12leases_outstanding\x18\x08 \x01(\x03\x12\x11\n\tcompleted\x18\t \x01(\x08\"m\n\x0bListRequest\x12\n\n\x02ID\x18\x01 \x01(\t\x12\r\n\x05state\x18\x02 \x01(\t\x12\x0e\n\x06prefix\x18\x03 \x01(\t\x12\x0c\n\x04glob\x18\x04 \x01(\t\x12\x12\n\ncount_only\x18\x05 \x01(\x08\x12\x11\n\tpage_size\x18\x06 \x01(\x05\"*\n\tTokenPage\x12\x0e\n\x06tokens\x18\x01 \x03(\t\x12\r\n\x05\x63ount\x18\x02 \x01(\x03\"U\n\nTokenState\x12\r\n\x05token\x18\x01 \x01(\t\x12\r\n\x05state\x18\x02 \x01(\t\x12\x0e\n\x06worker\x18\x03 \x01(\t\x12\x0b\n\x03key\x18\x04 \x01(\t\x12\x0c\n\x04time\x18\x05 \x01(\x03\"0\n\x0bTokenStates\x12!\n\x06tokens\x18\x01 \x03(\x0b\x32\x11.proto.TokenState\"T\n\rImportRequest\x12\n\n\x02ID\x18\x01 \x01(\t\x12\x14\n\x0cretry_failed\x18\x02 \x01(\x08\x12!\n\x06tokens\x18\x03 \x03(\x0b\x32\x11.proto.TokenState\"\x1c\n\nLeaderHint\x12\x0e\n\x06leader\x18\x01 \x01(\t\"2\n\x05\x45ntry\x12\r\n\x05index\x18\x01 \x01(\x04\x12\x0c\n\x04term\x18\x02 \x01(\x04\x12\x0c\n\x04\x64\x61ta\x18\x03 \x01(\x0c\"]\n\x0bVoteRequest\x12\x0c\n\x04term\x18\x01 \x01(\x04\x12\x11\n\tcandidate\x18\x02 \x01(\t\x12\x16\n\x0elast_log_index\x18\x03 \x01(\x04\x12\x15\n\rlast_log_term\x18\x04 \x01(\x04\"*\n\tVoteReply\x12\x0c\n\x04term\x18\x01 \x01(\x04\x12\x0f\n\x07granted\x18\x02 \x01(\x08\"\x92\x01\n\rAppendRequest\x12\x0c\n\x04term\x18\x01 \x01(\x04\x12\x0e\n\x06leader\x18\x02 \x01(\t\x12\x16\n\x0eprev_log_index\x18\x03 \x01(\x04\x12\x15\n\rprev_log_term\x18\x04 \x01(\x04\x12\x1d\n\x07\x65ntries\x18\x05 \x03(\x0b\x32\x0c.proto.Entry\x12\x15\n\rleader_commit\x18\x06 \x01(\x04\"@\n\x0b\x41ppendReply\x12\x0c\n\x04term\x18\x01 \x01(\x04\x12\x0f\n\x07success\x18\x02 \x01(\x08\x12\x12\n\nlast_index\x18\x03 \x01(\x04\"d\n\x0fSnapshotRequest\x12\x0c\n\x04term\x18\x01 \x01(\x04\x12\x0e\n\x06leader\x18\x02 \x01(\t\x12\x12\n\nlast_index\x18\x03 \x01(\x04\x12\x11\n\tlast_term\x18\x04 \x01(\x04\x12\x0c\n\x04\x64\x61ta\x18\x05 \x01(\x0c\"\x1d\n\rSnapshotReply\x12\x0c\n\x04term\x18\x01 \x01(\x04\x32\xf8\x04\n\x06Tokens\x12\"\n\x03Get\x12\x0c.proto.JobID\x1a\x0b.proto.Data\"\x00\x12\"\n\x04\x44one\x12\x0c.proto.JobID\x1a\n.proto.Ack\"\x00\x12*\n\x07Results\x12\x0c.proto.JobID\x1a\r.proto.Record\"\x00\x30\x01\x12#\n\x05Reset\x12\x0c.proto.Empty\x1a\n.proto.Ack\"\x00\x12$\n\x06Rescan\x12\x0c.proto.Empty\x1a\n.proto.Ack\"\x00\x12%\n\x07Shuffle\x12\x0c.proto.Empty\x1a\n.proto.Ack\"\x00\x12#\n\x04Show\x12\x0c.proto.Empty\x1a\x0b.proto.Data\"\x00\x12\x30\n\x04List\x12\x12.proto.ListRequest\x1a\x10.proto.TokenPage\"\x00\x30\x01\x12\'\n\tHeartBeat\x12\x0c.proto.JobID\x1a\n.proto.Ack\"\x00\x12-\n\nHeartBeats\x12\r.proto.Leases\x1a\x0e.proto.Renewal\"\x00\x12#\n\x05\x44rain\x12\x0c.proto.Empty\x1a\n.proto.Ack\"\x00\x12$\n\x06Resume\x12\x0c.proto.Empty\x1a\n.proto.Ack\"\x00\x12.\n\x08Progress\x12\x0c.proto.JobID\x1a\x12.proto.JobProgress\"\x00\x12.\n\x06\x45xport\x12\x0c.proto.JobID\x1a\x12.proto.TokenStates\"\x00\x30\x01\x12.\n\x06Import\x12\x14.proto.ImportRequest\x1a\n.proto.Ack\"\x00(\x01\x32\xbd\x01\n\x04Raft\x12\x35\n\x0bRequestVote\x12\x12.proto.VoteRequest\x1a\x10.proto.VoteReply\"\x00\x12;\n\rAppendEntries\x12\x14.proto.AppendRequest\x1a\x12.proto.AppendReply\"\x00\x12\x41\n\x0fInstallSnapshot\x12\x16.proto.SnapshotRequest\x1a\x14.proto.SnapshotReply\"\x00\x62\x06proto3')
 )
 
 
@@ -46,6 +46,810 @@ _DATA = _descriptor.Descriptor(
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='bytes', full_name='proto.Data.bytes', index=2,
+      number=3, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='draining', full_name='proto.Data.draining', index=3,
+      number=4, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=23,
+  serialized_end=91,
+)
+
+
+_JOBID = _descriptor.Descriptor(
+  name='JobID',
+  full_name='proto.JobID',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='ID', full_name='proto.JobID.ID', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='key', full_name='proto.JobID.key', index=1,
+      number=2, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='batch_size', full_name='proto.JobID.batch_size', index=2,
+      number=3, type=5, cpp_type=1, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='worker', full_name='proto.JobID.worker', index=3,
+      number=4, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='byte_budget', full_name='proto.JobID.byte_budget', index=4,
+      number=5, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='shard', full_name='proto.JobID.shard', index=5,
+      number=6, type=5, cpp_type=1, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='num_shards', full_name='proto.JobID.num_shards', index=6,
+      number=7, type=5, cpp_type=1, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='records', full_name='proto.JobID.records', index=7,
+      number=8, type=11, cpp_type=10, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='request', full_name='proto.JobID.request', index=8,
+      number=9, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=94,
+  serialized_end=267,
+)
+
+
+_RECORD = _descriptor.Descriptor(
+  name='Record',
+  full_name='proto.Record',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='token', full_name='proto.Record.token', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='data', full_name='proto.Record.data', index=1,
+      number=2, type=12, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b(""),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='error', full_name='proto.Record.error', index=2,
+      number=3, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=269,
+  serialized_end=321,
+)
+
+
+_EMPTY = _descriptor.Descriptor(
+  name='Empty',
+  full_name='proto.Empty',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=323,
+  serialized_end=330,
+)
+
+
+_ACK = _descriptor.Descriptor(
+  name='Ack',
+  full_name='proto.Ack',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='n', full_name='proto.Ack.n', index=0,
+      number=1, type=5, cpp_type=1, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='status', full_name='proto.Ack.status', index=1,
+      number=2, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='lost', full_name='proto.Ack.lost', index=2,
+      number=3, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='draining', full_name='proto.Ack.draining', index=3,
+      number=4, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=332,
+  serialized_end=396,
+)
+
+
+_LEASES = _descriptor.Descriptor(
+  name='Leases',
+  full_name='proto.Leases',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='ID', full_name='proto.Leases.ID', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='worker', full_name='proto.Leases.worker', index=1,
+      number=2, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='keys', full_name='proto.Leases.keys', index=2,
+      number=3, type=9, cpp_type=9, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=398,
+  serialized_end=448,
+)
+
+
+_LEASESTATUS = _descriptor.Descriptor(
+  name='LeaseStatus',
+  full_name='proto.LeaseStatus',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='key', full_name='proto.LeaseStatus.key', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='status', full_name='proto.LeaseStatus.status', index=1,
+      number=2, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=450,
+  serialized_end=492,
+)
+
+
+_RENEWAL = _descriptor.Descriptor(
+  name='Renewal',
+  full_name='proto.Renewal',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='leases', full_name='proto.Renewal.leases', index=0,
+      number=1, type=11, cpp_type=10, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='completed', full_name='proto.Renewal.completed', index=1,
+      number=2, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='draining', full_name='proto.Renewal.draining', index=2,
+      number=3, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=494,
+  serialized_end=576,
+)
+
+
+_JOBPROGRESS = _descriptor.Descriptor(
+  name='JobProgress',
+  full_name='proto.JobProgress',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='ID', full_name='proto.JobProgress.ID', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='tokens', full_name='proto.JobProgress.tokens', index=1,
+      number=2, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='dispatched', full_name='proto.JobProgress.dispatched', index=2,
+      number=3, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='tokens_completed', full_name='proto.JobProgress.tokens_completed', index=3,
+      number=4, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='leases_granted', full_name='proto.JobProgress.leases_granted', index=4,
+      number=5, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='leases_reassigned', full_name='proto.JobProgress.leases_reassigned', index=5,
+      number=6, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='leases_completed', full_name='proto.JobProgress.leases_completed', index=6,
+      number=7, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='leases_outstanding', full_name='proto.JobProgress.leases_outstanding', index=7,
+      number=8, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='completed', full_name='proto.JobProgress.completed', index=8,
+      number=9, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=579,
+  serialized_end=790,
+)
+
+
+_LISTREQUEST = _descriptor.Descriptor(
+  name='ListRequest',
+  full_name='proto.ListRequest',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='ID', full_name='proto.ListRequest.ID', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='state', full_name='proto.ListRequest.state', index=1,
+      number=2, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='prefix', full_name='proto.ListRequest.prefix', index=2,
+      number=3, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='glob', full_name='proto.ListRequest.glob', index=3,
+      number=4, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='count_only', full_name='proto.ListRequest.count_only', index=4,
+      number=5, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='page_size', full_name='proto.ListRequest.page_size', index=5,
+      number=6, type=5, cpp_type=1, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=792,
+  serialized_end=901,
+)
+
+
+_TOKENPAGE = _descriptor.Descriptor(
+  name='TokenPage',
+  full_name='proto.TokenPage',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='tokens', full_name='proto.TokenPage.tokens', index=0,
+      number=1, type=9, cpp_type=9, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='count', full_name='proto.TokenPage.count', index=1,
+      number=2, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=903,
+  serialized_end=945,
+)
+
+
+_TOKENSTATE = _descriptor.Descriptor(
+  name='TokenState',
+  full_name='proto.TokenState',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='token', full_name='proto.TokenState.token', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='state', full_name='proto.TokenState.state', index=1,
+      number=2, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='worker', full_name='proto.TokenState.worker', index=2,
+      number=3, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='key', full_name='proto.TokenState.key', index=3,
+      number=4, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='time', full_name='proto.TokenState.time', index=4,
+      number=5, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=947,
+  serialized_end=1032,
+)
+
+
+_TOKENSTATES = _descriptor.Descriptor(
+  name='TokenStates',
+  full_name='proto.TokenStates',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='tokens', full_name='proto.TokenStates.tokens', index=0,
+      number=1, type=11, cpp_type=10, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=1034,
+  serialized_end=1082,
+)
+
+
+_IMPORTREQUEST = _descriptor.Descriptor(
+  name='ImportRequest',
+  full_name='proto.ImportRequest',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='ID', full_name='proto.ImportRequest.ID', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='retry_failed', full_name='proto.ImportRequest.retry_failed', index=1,
+      number=2, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='tokens', full_name='proto.ImportRequest.tokens', index=2,
+      number=3, type=11, cpp_type=10, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=1084,
+  serialized_end=1168,
+)
+
+
+_LEADERHINT = _descriptor.Descriptor(
+  name='LeaderHint',
+  full_name='proto.LeaderHint',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='leader', full_name='proto.LeaderHint.leader', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=1170,
+  serialized_end=1198,
+)
+
+
+_ENTRY = _descriptor.Descriptor(
+  name='Entry',
+  full_name='proto.Entry',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='index', full_name='proto.Entry.index', index=0,
+      number=1, type=4, cpp_type=4, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='term', full_name='proto.Entry.term', index=1,
+      number=2, type=4, cpp_type=4, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='data', full_name='proto.Entry.data', index=2,
+      number=3, type=12, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b(""),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=1200,
+  serialized_end=1250,
+)
+
+
+_VOTEREQUEST = _descriptor.Descriptor(
+  name='VoteRequest',
+  full_name='proto.VoteRequest',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='term', full_name='proto.VoteRequest.term', index=0,
+      number=1, type=4, cpp_type=4, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='candidate', full_name='proto.VoteRequest.candidate', index=1,
+      number=2, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='last_log_index', full_name='proto.VoteRequest.last_log_index', index=2,
+      number=3, type=4, cpp_type=4, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='last_log_term', full_name='proto.VoteRequest.last_log_term', index=3,
+      number=4, type=4, cpp_type=4, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
   ],
   extensions=[
   ],
@@ -58,35 +862,94 @@ _DATA = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=23,
-  serialized_end=58,
+  serialized_start=1252,
+  serialized_end=1345,
 )
 
 
-_JOBID = _descriptor.Descriptor(
-  name='JobID',
-  full_name='proto.JobID',
+_VOTEREPLY = _descriptor.Descriptor(
+  name='VoteReply',
+  full_name='proto.VoteReply',
   filename=None,
   file=DESCRIPTOR,
   containing_type=None,
   fields=[
     _descriptor.FieldDescriptor(
-      name='ID', full_name='proto.JobID.ID', index=0,
-      number=1, type=9, cpp_type=9, label=1,
-      has_default_value=False, default_value=_b("").decode('utf-8'),
+      name='term', full_name='proto.VoteReply.term', index=0,
+      number=1, type=4, cpp_type=4, label=1,
+      has_default_value=False, default_value=0,
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       options=None, file=DESCRIPTOR),
     _descriptor.FieldDescriptor(
-      name='key', full_name='proto.JobID.key', index=1,
+      name='granted', full_name='proto.VoteReply.granted', index=1,
+      number=2, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=1347,
+  serialized_end=1389,
+)
+
+
+_APPENDREQUEST = _descriptor.Descriptor(
+  name='AppendRequest',
+  full_name='proto.AppendRequest',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='term', full_name='proto.AppendRequest.term', index=0,
+      number=1, type=4, cpp_type=4, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='leader', full_name='proto.AppendRequest.leader', index=1,
       number=2, type=9, cpp_type=9, label=1,
       has_default_value=False, default_value=_b("").decode('utf-8'),
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       options=None, file=DESCRIPTOR),
     _descriptor.FieldDescriptor(
-      name='batch_size', full_name='proto.JobID.batch_size', index=2,
-      number=3, type=5, cpp_type=1, label=1,
+      name='prev_log_index', full_name='proto.AppendRequest.prev_log_index', index=2,
+      number=3, type=4, cpp_type=4, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='prev_log_term', full_name='proto.AppendRequest.prev_log_term', index=3,
+      number=4, type=4, cpp_type=4, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='entries', full_name='proto.AppendRequest.entries', index=4,
+      number=5, type=11, cpp_type=10, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='leader_commit', full_name='proto.AppendRequest.leader_commit', index=5,
+      number=6, type=4, cpp_type=4, label=1,
       has_default_value=False, default_value=0,
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
@@ -103,18 +966,39 @@ _JOBID = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=60,
-  serialized_end=112,
+  serialized_start=1392,
+  serialized_end=1538,
 )
 
 
-_EMPTY = _descriptor.Descriptor(
-  name='Empty',
-  full_name='proto.Empty',
+_APPENDREPLY = _descriptor.Descriptor(
+  name='AppendReply',
+  full_name='proto.AppendReply',
   filename=None,
   file=DESCRIPTOR,
   containing_type=None,
   fields=[
+    _descriptor.FieldDescriptor(
+      name='term', full_name='proto.AppendReply.term', index=0,
+      number=1, type=4, cpp_type=4, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='success', full_name='proto.AppendReply.success', index=1,
+      number=2, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='last_index', full_name='proto.AppendReply.last_index', index=2,
+      number=3, type=4, cpp_type=4, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
   ],
   extensions=[
   ],
@@ -127,29 +1011,81 @@ _EMPTY = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=114,
-  serialized_end=121,
+  serialized_start=1540,
+  serialized_end=1604,
 )
 
 
-_ACK = _descriptor.Descriptor(
-  name='Ack',
-  full_name='proto.Ack',
+_SNAPSHOTREQUEST = _descriptor.Descriptor(
+  name='SnapshotRequest',
+  full_name='proto.SnapshotRequest',
   filename=None,
   file=DESCRIPTOR,
   containing_type=None,
   fields=[
     _descriptor.FieldDescriptor(
-      name='n', full_name='proto.Ack.n', index=0,
-      number=1, type=5, cpp_type=1, label=1,
+      name='term', full_name='proto.SnapshotRequest.term', index=0,
+      number=1, type=4, cpp_type=4, label=1,
       has_default_value=False, default_value=0,
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       options=None, file=DESCRIPTOR),
     _descriptor.FieldDescriptor(
-      name='status', full_name='proto.Ack.status', index=1,
-      number=2, type=8, cpp_type=7, label=1,
-      has_default_value=False, default_value=False,
+      name='leader', full_name='proto.SnapshotRequest.leader', index=1,
+      number=2, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='last_index', full_name='proto.SnapshotRequest.last_index', index=2,
+      number=3, type=4, cpp_type=4, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='last_term', full_name='proto.SnapshotRequest.last_term', index=3,
+      number=4, type=4, cpp_type=4, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='data', full_name='proto.SnapshotRequest.data', index=4,
+      number=5, type=12, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b(""),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=1606,
+  serialized_end=1706,
+)
+
+
+_SNAPSHOTREPLY = _descriptor.Descriptor(
+  name='SnapshotReply',
+  full_name='proto.SnapshotReply',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='term', full_name='proto.SnapshotReply.term', index=0,
+      number=1, type=4, cpp_type=4, label=1,
+      has_default_value=False, default_value=0,
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       options=None, file=DESCRIPTOR),
@@ -165,14 +1101,37 @@ _ACK = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=123,
-  serialized_end=155,
+  serialized_start=1708,
+  serialized_end=1737,
 )
 
+_JOBID.fields_by_name['records'].message_type = _RECORD
+_RENEWAL.fields_by_name['leases'].message_type = _LEASESTATUS
+_TOKENSTATES.fields_by_name['tokens'].message_type = _TOKENSTATE
+_IMPORTREQUEST.fields_by_name['tokens'].message_type = _TOKENSTATE
+_APPENDREQUEST.fields_by_name['entries'].message_type = _ENTRY
 DESCRIPTOR.message_types_by_name['Data'] = _DATA
 DESCRIPTOR.message_types_by_name['JobID'] = _JOBID
+DESCRIPTOR.message_types_by_name['Record'] = _RECORD
 DESCRIPTOR.message_types_by_name['Empty'] = _EMPTY
 DESCRIPTOR.message_types_by_name['Ack'] = _ACK
+DESCRIPTOR.message_types_by_name['Leases'] = _LEASES
+DESCRIPTOR.message_types_by_name['LeaseStatus'] = _LEASESTATUS
+DESCRIPTOR.message_types_by_name['Renewal'] = _RENEWAL
+DESCRIPTOR.message_types_by_name['JobProgress'] = _JOBPROGRESS
+DESCRIPTOR.message_types_by_name['ListRequest'] = _LISTREQUEST
+DESCRIPTOR.message_types_by_name['TokenPage'] = _TOKENPAGE
+DESCRIPTOR.message_types_by_name['TokenState'] = _TOKENSTATE
+DESCRIPTOR.message_types_by_name['TokenStates'] = _TOKENSTATES
+DESCRIPTOR.message_types_by_name['ImportRequest'] = _IMPORTREQUEST
+DESCRIPTOR.message_types_by_name['LeaderHint'] = _LEADERHINT
+DESCRIPTOR.message_types_by_name['Entry'] = _ENTRY
+DESCRIPTOR.message_types_by_name['VoteRequest'] = _VOTEREQUEST
+DESCRIPTOR.message_types_by_name['VoteReply'] = _VOTEREPLY
+DESCRIPTOR.message_types_by_name['AppendRequest'] = _APPENDREQUEST
+DESCRIPTOR.message_types_by_name['AppendReply'] = _APPENDREPLY
+DESCRIPTOR.message_types_by_name['SnapshotRequest'] = _SNAPSHOTREQUEST
+DESCRIPTOR.message_types_by_name['SnapshotReply'] = _SNAPSHOTREPLY
 _sym_db.RegisterFileDescriptor(DESCRIPTOR)
 
 Data = _reflection.GeneratedProtocolMessageType('Data', (_message.Message,), dict(
@@ -189,6 +1148,13 @@ JobID = _reflection.GeneratedProtocolMessageType('JobID', (_message.Message,), d
   ))
 _sym_db.RegisterMessage(JobID)
 
+Record = _reflection.GeneratedProtocolMessageType('Record', (_message.Message,), dict(
+  DESCRIPTOR = _RECORD,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.Record)
+  ))
+_sym_db.RegisterMessage(Record)
+
 Empty = _reflection.GeneratedProtocolMessageType('Empty', (_message.Message,), dict(
   DESCRIPTOR = _EMPTY,
   __module__ = 'config_pb2'
@@ -203,6 +1169,125 @@ Ack = _reflection.GeneratedProtocolMessageType('Ack', (_message.Message,), dict(
   ))
 _sym_db.RegisterMessage(Ack)
 
+Leases = _reflection.GeneratedProtocolMessageType('Leases', (_message.Message,), dict(
+  DESCRIPTOR = _LEASES,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.Leases)
+  ))
+_sym_db.RegisterMessage(Leases)
+
+LeaseStatus = _reflection.GeneratedProtocolMessageType('LeaseStatus', (_message.Message,), dict(
+  DESCRIPTOR = _LEASESTATUS,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.LeaseStatus)
+  ))
+_sym_db.RegisterMessage(LeaseStatus)
+
+Renewal = _reflection.GeneratedProtocolMessageType('Renewal', (_message.Message,), dict(
+  DESCRIPTOR = _RENEWAL,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.Renewal)
+  ))
+_sym_db.RegisterMessage(Renewal)
+
+JobProgress = _reflection.GeneratedProtocolMessageType('JobProgress', (_message.Message,), dict(
+  DESCRIPTOR = _JOBPROGRESS,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.JobProgress)
+  ))
+_sym_db.RegisterMessage(JobProgress)
+
+ListRequest = _reflection.GeneratedProtocolMessageType('ListRequest', (_message.Message,), dict(
+  DESCRIPTOR = _LISTREQUEST,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.ListRequest)
+  ))
+_sym_db.RegisterMessage(ListRequest)
+
+TokenPage = _reflection.GeneratedProtocolMessageType('TokenPage', (_message.Message,), dict(
+  DESCRIPTOR = _TOKENPAGE,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.TokenPage)
+  ))
+_sym_db.RegisterMessage(TokenPage)
+
+TokenState = _reflection.GeneratedProtocolMessageType('TokenState', (_message.Message,), dict(
+  DESCRIPTOR = _TOKENSTATE,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.TokenState)
+  ))
+_sym_db.RegisterMessage(TokenState)
+
+TokenStates = _reflection.GeneratedProtocolMessageType('TokenStates', (_message.Message,), dict(
+  DESCRIPTOR = _TOKENSTATES,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.TokenStates)
+  ))
+_sym_db.RegisterMessage(TokenStates)
+
+ImportRequest = _reflection.GeneratedProtocolMessageType('ImportRequest', (_message.Message,), dict(
+  DESCRIPTOR = _IMPORTREQUEST,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.ImportRequest)
+  ))
+_sym_db.RegisterMessage(ImportRequest)
+
+LeaderHint = _reflection.GeneratedProtocolMessageType('LeaderHint', (_message.Message,), dict(
+  DESCRIPTOR = _LEADERHINT,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.LeaderHint)
+  ))
+_sym_db.RegisterMessage(LeaderHint)
+
+Entry = _reflection.GeneratedProtocolMessageType('Entry', (_message.Message,), dict(
+  DESCRIPTOR = _ENTRY,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.Entry)
+  ))
+_sym_db.RegisterMessage(Entry)
+
+VoteRequest = _reflection.GeneratedProtocolMessageType('VoteRequest', (_message.Message,), dict(
+  DESCRIPTOR = _VOTEREQUEST,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.VoteRequest)
+  ))
+_sym_db.RegisterMessage(VoteRequest)
+
+VoteReply = _reflection.GeneratedProtocolMessageType('VoteReply', (_message.Message,), dict(
+  DESCRIPTOR = _VOTEREPLY,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.VoteReply)
+  ))
+_sym_db.RegisterMessage(VoteReply)
+
+AppendRequest = _reflection.GeneratedProtocolMessageType('AppendRequest', (_message.Message,), dict(
+  DESCRIPTOR = _APPENDREQUEST,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.AppendRequest)
+  ))
+_sym_db.RegisterMessage(AppendRequest)
+
+AppendReply = _reflection.GeneratedProtocolMessageType('AppendReply', (_message.Message,), dict(
+  DESCRIPTOR = _APPENDREPLY,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.AppendReply)
+  ))
+_sym_db.RegisterMessage(AppendReply)
+
+SnapshotRequest = _reflection.GeneratedProtocolMessageType('SnapshotRequest', (_message.Message,), dict(
+  DESCRIPTOR = _SNAPSHOTREQUEST,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.SnapshotRequest)
+  ))
+_sym_db.RegisterMessage(SnapshotRequest)
+
+SnapshotReply = _reflection.GeneratedProtocolMessageType('SnapshotReply', (_message.Message,), dict(
+  DESCRIPTOR = _SNAPSHOTREPLY,
+  __module__ = 'config_pb2'
+  # @@protoc_insertion_point(class_scope:proto.SnapshotReply)
+  ))
+_sym_db.RegisterMessage(SnapshotReply)
+
 
 
 _TOKENS = _descriptor.ServiceDescriptor(
@@ -211,8 +1296,8 @@ _TOKENS = _descriptor.ServiceDescriptor(
   file=DESCRIPTOR,
   index=0,
   options=None,
-  serialized_start=158,
-  serialized_end=430,
+  serialized_start=1740,
+  serialized_end=2372,
   methods=[
   _descriptor.MethodDescriptor(
     name='Get',
@@ -232,10 +1317,19 @@ _TOKENS = _descriptor.ServiceDescriptor(
     output_type=_ACK,
     options=None,
   ),
+  _descriptor.MethodDescriptor(
+    name='Results',
+    full_name='proto.Tokens.Results',
+    index=2,
+    containing_service=None,
+    input_type=_JOBID,
+    output_type=_RECORD,
+    options=None,
+  ),
   _descriptor.MethodDescriptor(
     name='Reset',
     full_name='proto.Tokens.Reset',
-    index=2,
+    index=3,
     containing_service=None,
     input_type=_EMPTY,
     output_type=_ACK,
@@ -244,7 +1338,7 @@ _TOKENS = _descriptor.ServiceDescriptor(
   _descriptor.MethodDescriptor(
     name='Rescan',
     full_name='proto.Tokens.Rescan',
-    index=3,
+    index=4,
     containing_service=None,
     input_type=_EMPTY,
     output_type=_ACK,
@@ -253,7 +1347,7 @@ _TOKENS = _descriptor.ServiceDescriptor(
   _descriptor.MethodDescriptor(
     name='Shuffle',
     full_name='proto.Tokens.Shuffle',
-    index=4,
+    index=5,
     containing_service=None,
     input_type=_EMPTY,
     output_type=_ACK,
@@ -262,18 +1356,81 @@ _TOKENS = _descriptor.ServiceDescriptor(
   _descriptor.MethodDescriptor(
     name='Show',
     full_name='proto.Tokens.Show',
-    index=5,
+    index=6,
     containing_service=None,
     input_type=_EMPTY,
     output_type=_DATA,
     options=None,
   ),
+  _descriptor.MethodDescriptor(
+    name='List',
+    full_name='proto.Tokens.List',
+    index=7,
+    containing_service=None,
+    input_type=_LISTREQUEST,
+    output_type=_TOKENPAGE,
+    options=None,
+  ),
   _descriptor.MethodDescriptor(
     name='HeartBeat',
     full_name='proto.Tokens.HeartBeat',
-    index=6,
+    index=8,
+    containing_service=None,
+    input_type=_JOBID,
+    output_type=_ACK,
+    options=None,
+  ),
+  _descriptor.MethodDescriptor(
+    name='HeartBeats',
+    full_name='proto.Tokens.HeartBeats',
+    index=9,
+    containing_service=None,
+    input_type=_LEASES,
+    output_type=_RENEWAL,
+    options=None,
+  ),
+  _descriptor.MethodDescriptor(
+    name='Drain',
+    full_name='proto.Tokens.Drain',
+    index=10,
+    containing_service=None,
+    input_type=_EMPTY,
+    output_type=_ACK,
+    options=None,
+  ),
+  _descriptor.MethodDescriptor(
+    name='Resume',
+    full_name='proto.Tokens.Resume',
+    index=11,
+    containing_service=None,
+    input_type=_EMPTY,
+    output_type=_ACK,
+    options=None,
+  ),
+  _descriptor.MethodDescriptor(
+    name='Progress',
+    full_name='proto.Tokens.Progress',
+    index=12,
+    containing_service=None,
+    input_type=_JOBID,
+    output_type=_JOBPROGRESS,
+    options=None,
+  ),
+  _descriptor.MethodDescriptor(
+    name='Export',
+    full_name='proto.Tokens.Export',
+    index=13,
     containing_service=None,
     input_type=_JOBID,
+    output_type=_TOKENSTATES,
+    options=None,
+  ),
+  _descriptor.MethodDescriptor(
+    name='Import',
+    full_name='proto.Tokens.Import',
+    index=14,
+    containing_service=None,
+    input_type=_IMPORTREQUEST,
     output_type=_ACK,
     options=None,
   ),
@@ -282,4 +1439,46 @@ _sym_db.RegisterServiceDescriptor(_TOKENS)
 
 DESCRIPTOR.services_by_name['Tokens'] = _TOKENS
 
+
+_RAFT = _descriptor.ServiceDescriptor(
+  name='Raft',
+  full_name='proto.Raft',
+  file=DESCRIPTOR,
+  index=1,
+  options=None,
+  serialized_start=2375,
+  serialized_end=2564,
+  methods=[
+  _descriptor.MethodDescriptor(
+    name='RequestVote',
+    full_name='proto.Raft.RequestVote',
+    index=0,
+    containing_service=None,
+    input_type=_VOTEREQUEST,
+    output_type=_VOTEREPLY,
+    options=None,
+  ),
+  _descriptor.MethodDescriptor(
+    name='AppendEntries',
+    full_name='proto.Raft.AppendEntries',
+    index=1,
+    containing_service=None,
+    input_type=_APPENDREQUEST,
+    output_type=_APPENDREPLY,
+    options=None,
+  ),
+  _descriptor.MethodDescriptor(
+    name='InstallSnapshot',
+    full_name='proto.Raft.InstallSnapshot',
+    index=2,
+    containing_service=None,
+    input_type=_SNAPSHOTREQUEST,
+    output_type=_SNAPSHOTREPLY,
+    options=None,
+  ),
+])
+_sym_db.RegisterServiceDescriptor(_RAFT)
+
+DESCRIPTOR.services_by_name['Raft'] = _RAFT
+
 # @@protoc_insertion_point(module_scope)
@@ -24,6 +24,11 @@ class TokensStub(object):
         request_serializer=config__pb2.JobID.SerializeToString,
         response_deserializer=config__pb2.Ack.FromString,
         )
+    self.Results = channel.unary_stream(
+        '/proto.Tokens/Results',
+        request_serializer=config__pb2.JobID.SerializeToString,
+        response_deserializer=config__pb2.Record.FromString,
+        )
     self.Reset = channel.unary_unary(
         '/proto.Tokens/Reset',
         request_serializer=config__pb2.Empty.SerializeToString,
@@ -44,11 +49,46 @@ class TokensStub(object):
         request_serializer=config__pb2.Empty.SerializeToString,
         response_deserializer=config__pb2.Data.FromString,
         )
+    self.List = channel.unary_stream(
+        '/proto.Tokens/List',
+        request_serializer=config__pb2.ListRequest.SerializeToString,
+        response_deserializer=config__pb2.TokenPage.FromString,
+        )
     self.HeartBeat = channel.unary_unary(
         '/proto.Tokens/HeartBeat',
         request_serializer=config__pb2.JobID.SerializeToString,
         response_deserializer=config__pb2.Ack.FromString,
         )
+    self.HeartBeats = channel.unary_unary(
+        '/proto.Tokens/HeartBeats',
+        request_serializer=config__pb2.Leases.SerializeToString,
+        response_deserializer=config__pb2.Renewal.FromString,
+        )
+    self.Drain = channel.unary_unary(
+        '/proto.Tokens/Drain',
+        request_serializer=config__pb2.Empty.SerializeToString,
+        response_deserializer=config__pb2.Ack.FromString,
+        )
+    self.Resume = channel.unary_unary(
+        '/proto.Tokens/Resume',
+        request_serializer=config__pb2.Empty.SerializeToString,
+        response_deserializer=config__pb2.Ack.FromString,
+        )
+    self.Progress = channel.unary_unary(
+        '/proto.Tokens/Progress',
+        request_serializer=config__pb2.JobID.SerializeToString,
+        response_deserializer=config__pb2.JobProgress.FromString,
+        )
+    self.Export = channel.unary_stream(
+        '/proto.Tokens/Export',
+        request_serializer=config__pb2.JobID.SerializeToString,
+        response_deserializer=config__pb2.TokenStates.FromString,
+        )
+    self.Import = channel.stream_unary(
+        '/proto.Tokens/Import',
+        request_serializer=config__pb2.ImportRequest.SerializeToString,
+        response_deserializer=config__pb2.Ack.FromString,
+        )
 
 
 class TokensServicer(object):
@@ -69,6 +109,13 @@ class TokensServicer(object):
     context.set_details('Method not implemented!')
     raise NotImplementedError('Method not implemented!')
 
+  def Results(self, request, context):
+    """client requests server to stream back results collected for a job
+    """
+    context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+    context.set_details('Method not implemented!')
+    raise NotImplementedError('Method not implemented!')
+
   def Reset(self, request, context):
     """client calls Reset() to reinit the server meta-data and book keeping state
     """
@@ -92,6 +139,14 @@ class TokensServicer(object):
 
   def Show(self, request, context):
     """client requests server the spit out list of tokens regardless of jobID and other meta-data
+    lists that do not fit into a single message need List()
+    """
+    context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+    context.set_details('Method not implemented!')
+    raise NotImplementedError('Method not implemented!')
+
+  def List(self, request, context):
+    """client requests server to stream the tokens that pass a filter page by page
     """
     context.set_code(grpc.StatusCode.UNIMPLEMENTED)
     context.set_details('Method not implemented!')
@@ -104,6 +159,49 @@ class TokensServicer(object):
     context.set_details('Method not implemented!')
     raise NotImplementedError('Method not implemented!')
 
+  def HeartBeats(self, request, context):
+    """client renews all of its leases of a job at once
+    """
+    context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+    context.set_details('Method not implemented!')
+    raise NotImplementedError('Method not implemented!')
+
+  def Drain(self, request, context):
+    """client puts the server into drain, Get() grants no new leases across all jobs
+    the ack counts leases still outstanding and its status is true once none remain
+    """
+    context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+    context.set_details('Method not implemented!')
+    raise NotImplementedError('Method not implemented!')
+
+  def Resume(self, request, context):
+    """client takes the server out of drain
+    """
+    context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+    context.set_details('Method not implemented!')
+    raise NotImplementedError('Method not implemented!')
+
+  def Progress(self, request, context):
+    """client requests the progress of a job
+    """
+    context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+    context.set_details('Method not implemented!')
+    raise NotImplementedError('Method not implemented!')
+
+  def Export(self, request, context):
+    """client requests server to stream the state of each token of a job
+    """
+    context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+    context.set_details('Method not implemented!')
+    raise NotImplementedError('Method not implemented!')
+
+  def Import(self, request_iterator, context):
+    """client creates a job from exported token states, the ack counts tokens left to dispatch
+    """
+    context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+    context.set_details('Method not implemented!')
+    raise NotImplementedError('Method not implemented!')
+
 
 def add_TokensServicer_to_server(servicer, server):
   rpc_method_handlers = {
@@ -117,6 +215,11 @@ def add_TokensServicer_to_server(servicer, server):
           request_deserializer=config__pb2.JobID.FromString,
           response_serializer=config__pb2.Ack.SerializeToString,
       ),
+      'Results': grpc.unary_stream_rpc_method_handler(
+          servicer.Results,
+          request_deserializer=config__pb2.JobID.FromString,
+          response_serializer=config__pb2.Record.SerializeToString,
+      ),
       'Reset': grpc.unary_unary_rpc_method_handler(
           servicer.Reset,
           request_deserializer=config__pb2.Empty.FromString,
@@ -137,12 +240,123 @@ def add_TokensServicer_to_server(servicer, server):
           request_deserializer=config__pb2.Empty.FromString,
           response_serializer=config__pb2.Data.SerializeToString,
       ),
+      'List': grpc.unary_stream_rpc_method_handler(
+          servicer.List,
+          request_deserializer=config__pb2.ListRequest.FromString,
+          response_serializer=config__pb2.TokenPage.SerializeToString,
+      ),
       'HeartBeat': grpc.unary_unary_rpc_method_handler(
           servicer.HeartBeat,
           request_deserializer=config__pb2.JobID.FromString,
           response_serializer=config__pb2.Ack.SerializeToString,
       ),
+      'HeartBeats': grpc.unary_unary_rpc_method_handler(
+          servicer.HeartBeats,
+          request_deserializer=config__pb2.Leases.FromString,
+          response_serializer=config__pb2.Renewal.SerializeToString,
+      ),
+      'Drain': grpc.unary_unary_rpc_method_handler(
+          servicer.Drain,
+          request_deserializer=config__pb2.Empty.FromString,
+          response_serializer=config__pb2.Ack.SerializeToString,
+      ),
+      'Resume': grpc.unary_unary_rpc_method_handler(
+          servicer.Resume,
+          request_deserializer=config__pb2.Empty.FromString,
+          response_serializer=config__pb2.Ack.SerializeToString,
+      ),
+      'Progress': grpc.unary_unary_rpc_method_handler(
+          servicer.Progress,
+          request_deserializer=config__pb2.JobID.FromString,
+          response_serializer=config__pb2.JobProgress.SerializeToString,
+      ),
+      'Export': grpc.unary_stream_rpc_method_handler(
+          servicer.Export,
+          request_deserializer=config__pb2.JobID.FromString,
+          response_serializer=config__pb2.TokenStates.SerializeToString,
+      ),
+      'Import': grpc.stream_unary_rpc_method_handler(
+          servicer.Import,
+          request_deserializer=config__pb2.ImportRequest.FromString,
+          response_serializer=config__pb2.Ack.SerializeToString,
+      ),
   }
   generic_handler = grpc.method_handlers_generic_handler(
       'proto.Tokens', rpc_method_handlers)
   server.add_generic_rpc_handlers((generic_handler,))
+
+
+class RaftStub(object):
+  """calls replicas of a highly available server make to each other
+  """
+
+  def __init__(self, channel):
+    """Constructor.
+
+    Args:
+      channel: A grpc.Channel.
+    """
+    self.RequestVote = channel.unary_unary(
+        '/proto.Raft/RequestVote',
+        request_serializer=config__pb2.VoteRequest.SerializeToString,
+        response_deserializer=config__pb2.VoteReply.FromString,
+        )
+    self.AppendEntries = channel.unary_unary(
+        '/proto.Raft/AppendEntries',
+        request_serializer=config__pb2.AppendRequest.SerializeToString,
+        response_deserializer=config__pb2.AppendReply.FromString,
+        )
+    self.InstallSnapshot = channel.unary_unary(
+        '/proto.Raft/InstallSnapshot',
+        request_serializer=config__pb2.SnapshotRequest.SerializeToString,
+        response_deserializer=config__pb2.SnapshotReply.FromString,
+        )
+
+
+class RaftServicer(object):
+  """calls replicas of a highly available server make to each other
+  """
+
+  def RequestVote(self, request, context):
+    """candidate requests votes to become leader
+    """
+    context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+    context.set_details('Method not implemented!')
+    raise NotImplementedError('Method not implemented!')
+
+  def AppendEntries(self, request, context):
+    """leader replicates its log and asserts leadership
+    """
+    context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+    context.set_details('Method not implemented!')
+    raise NotImplementedError('Method not implemented!')
+
+  def InstallSnapshot(self, request, context):
+    """leader replaces the state of a replica that is too far behind
+    """
+    context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+    context.set_details('Method not implemented!')
+    raise NotImplementedError('Method not implemented!')
+
+
+def add_RaftServicer_to_server(servicer, server):
+  rpc_method_handlers = {
+      'RequestVote': grpc.unary_unary_rpc_method_handler(
+          servicer.RequestVote,
+          request_deserializer=config__pb2.VoteRequest.FromString,
+          response_serializer=config__pb2.VoteReply.SerializeToString,
+      ),
+      'AppendEntries': grpc.unary_unary_rpc_method_handler(
+          servicer.AppendEntries,
+          request_deserializer=config__pb2.AppendRequest.FromString,
+          response_serializer=config__pb2.AppendReply.SerializeToString,
+      ),
+      'InstallSnapshot': grpc.unary_unary_rpc_method_handler(
+          servicer.InstallSnapshot,
+          request_deserializer=config__pb2.SnapshotRequest.FromString,
+          response_serializer=config__pb2.SnapshotReply.SerializeToString,
+      ),
+  }
+  generic_handler = grpc.method_handlers_generic_handler(
+      'proto.Raft', rpc_method_handlers)
+  server.add_generic_rpc_handlers((generic_handler,))
@@ -0,0 +1,163 @@
+package proto
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// DialConfig holds the connection settings shared by all clients
+type DialConfig struct {
+	// Keepalive is how often an idle connection is pinged, 0 disables pings
+	Keepalive time.Duration
+	// KeepaliveTimeout is how long to wait for a ping before the connection
+	// is considered broken and re-established
+	KeepaliveTimeout time.Duration
+	// Backoff applies to calls that are retried
+	Backoff Backoff
+	// CAFile verifies the server certificate, setting it or CertFile
+	// enables TLS. The system roots are used if it is empty.
+	CAFile string
+	// CertFile and KeyFile hold the client certificate for servers that
+	// require mutual TLS
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the host name the server certificate is
+	// verified against
+	ServerName string
+	// Token is sent as bearer token with every call to servers that
+	// authorize clients
+	Token string
+	// Partitioned dials the hosts listed to Dial as servers that each own
+	// a partition of the tokens instead of as replicas of one server
+	Partitioned bool
+}
+
+// DefaultDialConfig pings every 30 seconds, servers must permit that
+func DefaultDialConfig() *DialConfig {
+	return &DialConfig{
+		Keepalive:        time.Second * 30,
+		KeepaliveTimeout: time.Second * 10,
+		Backoff:          DefaultBackoff(),
+	}
+}
+
+// DialFlags registers flags for the connection settings on fs and returns
+// the config they populate
+func DialFlags(fs *flag.FlagSet) *DialConfig {
+	c := DefaultDialConfig()
+	fs.DurationVar(&c.Keepalive, "keepalive", c.Keepalive, "ping idle connections this often (0 disables)")
+	fs.DurationVar(&c.KeepaliveTimeout, "keepalive-timeout", c.KeepaliveTimeout,
+		"reconnect when a ping is not answered within this long")
+	fs.IntVar(&c.Backoff.Retries, "retries", c.Backoff.Retries, "retry transient errors this often")
+	fs.DurationVar(&c.Backoff.Initial, "retry-delay", c.Backoff.Initial, "delay before the first retry")
+	fs.DurationVar(&c.Backoff.Max, "max-retry-delay", c.Backoff.Max, "upper bound of the exponential retry delay")
+	fs.StringVar(&c.CAFile, "tls-ca", "", "CA certificate to verify the server with, enables TLS")
+	fs.StringVar(&c.CertFile, "tls-cert", "", "client certificate for mutual TLS, enables TLS")
+	fs.StringVar(&c.KeyFile, "tls-key", "", "client key for mutual TLS")
+	fs.StringVar(&c.ServerName, "tls-server-name", "", "name to verify the server certificate against instead of the host")
+	fs.StringVar(&c.Token, "auth-token", os.Getenv("TOKEN_AUTH_TOKEN"),
+		"bearer token to authenticate with, defaults to $TOKEN_AUTH_TOKEN")
+	fs.BoolVar(&c.Partitioned, "partitioned", false,
+		"hosts listed with commas each own a partition of the tokens, in the order the servers list them")
+	return c
+}
+
+// bearer attaches a static token to every call
+type bearer string
+
+func (b bearer) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(b)}, nil
+}
+
+// RequireTransportSecurity is false so that tokens also work on plain text
+// connections inside trusted networks
+func (b bearer) RequireTransportSecurity() bool {
+	return false
+}
+
+// Credentials returns the transport credentials for the config, nil means
+// the connection is not encrypted
+func (c *DialConfig) Credentials() (credentials.TransportCredentials, error) {
+	if c.CAFile == "" && c.CertFile == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{ServerName: c.ServerName}
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in " + c.CAFile)
+		}
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// DialOptions returns the grpc options for the config
+func (c *DialConfig) DialOptions() ([]grpc.DialOption, error) {
+	creds, err := c.Credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	dialOpts := []grpc.DialOption{grpc.WithInsecure()}
+	if creds != nil {
+		dialOpts[0] = grpc.WithTransportCredentials(creds)
+	}
+	if c.Token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(bearer(c.Token)))
+	}
+	if c.Backoff.Max > 0 {
+		dialOpts = append(dialOpts, grpc.WithBackoffMaxDelay(c.Backoff.Max))
+	}
+	if c.Keepalive > 0 {
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                c.Keepalive,
+			Timeout:             c.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		}))
+	}
+	return dialOpts, nil
+}
+
+// Dial connects to host. The connection is re-established in the background
+// when it breaks, calls made in the meantime fail with codes.Unavailable.
+// host may list several replicas of a server separated by commas, calls then
+// fail over between them, or the servers of all partitions if Partitioned
+// is set.
+func (c *DialConfig) Dial(host string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	dialOpts, err := c.DialOptions()
+	if err != nil {
+		return nil, err
+	}
+	dialOpts = append(dialOpts, opts...)
+
+	if c.Partitioned {
+		return dialPartitioned(strings.Split(host, ","), dialOpts)
+	}
+	if strings.Contains(host, ",") {
+		return dialFailover(strings.Split(host, ","), dialOpts)
+	}
+	return grpc.Dial(host, dialOpts...)
+}
@@ -0,0 +1,40 @@
+package proto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// tokenStateLine is the JSON form of a TokenState, one per line in export
+// files
+type tokenStateLine struct {
+	Token  string     `json:"token"`
+	State  string     `json:"state"`
+	Worker string     `json:"worker,omitempty"`
+	Key    string     `json:"key,omitempty"`
+	Time   *time.Time `json:"time,omitempty"`
+}
+
+// MarshalTokenState renders t as a single line of JSON with its time in
+// RFC 3339 format
+func MarshalTokenState(t *TokenState) ([]byte, error) {
+	line := tokenStateLine{Token: t.Token, State: t.State, Worker: t.Worker, Key: t.Key}
+	if t.Time != 0 {
+		at := time.Unix(0, t.Time).UTC()
+		line.Time = &at
+	}
+	return json.Marshal(line)
+}
+
+// UnmarshalTokenState parses a line produced by MarshalTokenState
+func UnmarshalTokenState(b []byte) (*TokenState, error) {
+	line := new(tokenStateLine)
+	if err := json.Unmarshal(b, line); err != nil {
+		return nil, err
+	}
+	t := &TokenState{Token: line.Token, State: line.State, Worker: line.Worker, Key: line.Key}
+	if line.Time != nil {
+		t.Time = line.Time.UnixNano()
+	}
+	return t, nil
+}
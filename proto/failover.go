@@ -0,0 +1,168 @@
+package proto
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+// NotLeader is the error replicas that are not the leader answer calls
+// with, leader names the one that is if it is known
+func NotLeader(leader string) error {
+	if leader == "" {
+		return status.Error(codes.Unavailable, "no leader elected")
+	}
+	st, err := status.New(codes.Unavailable, "not the leader, leader is "+leader).
+		WithDetails(&LeaderHint{Leader: leader})
+	if err != nil {
+		return status.Error(codes.Unavailable, "not the leader, leader is "+leader)
+	}
+	return st.Err()
+}
+
+// Leader returns the leader named by a NotLeader error, if any
+func Leader(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if hint, ok := detail.(*LeaderHint); ok {
+			return hint.Leader
+		}
+	}
+	return ""
+}
+
+// failover sends calls to one of several replicas of a server. Calls stick
+// to a replica until it answers with codes.Unavailable and then move on to
+// the leader named in the error, see NotLeader, or else to the next one.
+type failover struct {
+	hosts []string
+	conns []*grpc.ClientConn
+
+	lock    sync.Mutex
+	current int
+}
+
+// dialFailover connects to all hosts and returns the connection to the first
+// one, which hands calls to the others as needed. Closing it closes them all.
+func dialFailover(hosts []string, opts []grpc.DialOption) (*grpc.ClientConn, error) {
+	f := &failover{hosts: make([]string, len(hosts))}
+	for i, host := range hosts {
+		f.hosts[i] = strings.TrimSpace(host)
+	}
+
+	for i, host := range f.hosts {
+		dialOpts := opts
+		if i == 0 {
+			dialOpts = append(dialOpts[:len(dialOpts):len(dialOpts)],
+				grpc.WithUnaryInterceptor(f.unary),
+				grpc.WithStreamInterceptor(f.stream))
+		}
+		conn, err := grpc.Dial(host, dialOpts...)
+		if err != nil {
+			for _, conn := range f.conns {
+				conn.Close()
+			}
+			return nil, err
+		}
+		f.conns = append(f.conns, conn)
+	}
+
+	go closeWith(f.conns[0], f.conns[1:])
+	return f.conns[0], nil
+}
+
+// closeWith closes others once conn is closed
+func closeWith(conn *grpc.ClientConn, others []*grpc.ClientConn) {
+	for state := conn.GetState(); state != connectivity.Shutdown; state = conn.GetState() {
+		conn.WaitForStateChange(context.Background(), state)
+	}
+	for _, conn := range others {
+		conn.Close()
+	}
+}
+
+func (f *failover) pick() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.current
+}
+
+// moveOn switches away from replica i after it failed with err unless
+// another call already did
+func (f *failover) moveOn(i int, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.current != i {
+		return
+	}
+
+	if leader := Leader(err); leader != "" {
+		for j, host := range f.hosts {
+			if host == leader && j != i {
+				f.current = j
+				return
+			}
+		}
+	}
+	f.current = (i + 1) % len(f.hosts)
+}
+
+func (f *failover) unary(ctx context.Context, method string, req, reply interface{},
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	var err error
+	for range f.conns {
+		i := f.pick()
+		if i == 0 {
+			err = invoker(ctx, method, req, reply, cc, opts...)
+		} else {
+			err = f.conns[i].Invoke(ctx, method, req, reply, opts...)
+		}
+		if status.Code(err) != codes.Unavailable {
+			return err
+		}
+		f.moveOn(i, err)
+	}
+	return err
+}
+
+func (f *failover) stream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+	method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	var err error
+	for range f.conns {
+		i := f.pick()
+		var stream grpc.ClientStream
+		if i == 0 {
+			stream, err = streamer(ctx, desc, cc, method, opts...)
+		} else {
+			stream, err = f.conns[i].NewStream(ctx, desc, method, opts...)
+		}
+		if err == nil {
+			return &failoverStream{ClientStream: stream, f: f, i: i}, nil
+		}
+		if status.Code(err) != codes.Unavailable {
+			return nil, err
+		}
+		f.moveOn(i, err)
+	}
+	return nil, err
+}
+
+// failoverStream moves later calls on when replica i ends the stream with
+// codes.Unavailable. The stream itself is not replayed, callers retry it.
+type failoverStream struct {
+	grpc.ClientStream
+	f *failover
+	i int
+}
+
+func (s *failoverStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if status.Code(err) == codes.Unavailable {
+		s.f.moveOn(s.i, err)
+	}
+	return err
+}
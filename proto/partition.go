@@ -0,0 +1,369 @@
+package proto
+
+import (
+	"context"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	protobuf "github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PartitionKey prefixes a lease key with the index of the partition that
+// granted it
+func PartitionKey(partition int, key string) string {
+	return strconv.Itoa(partition) + "." + key
+}
+
+// KeyPartition returns the index of the partition that granted key
+func KeyPartition(key string) (int, bool) {
+	i := strings.IndexByte(key, '.')
+	if i < 0 {
+		return 0, false
+	}
+	partition, err := strconv.Atoi(key[:i])
+	if err != nil || partition < 0 {
+		return 0, false
+	}
+	return partition, true
+}
+
+// router spreads calls across servers that each own a partition of the
+// tokens. Get asks the partitions in turn until one has work, Done and
+// HeartBeat go to the partition named in the lease key, and everything
+// else goes to all partitions with the replies added up. Streams from the
+// server are read from each partition in turn, streams to the server go to
+// all of them.
+type router struct {
+	conns []*grpc.ClientConn
+
+	lock sync.Mutex
+	next int
+}
+
+// dialPartitioned connects to the server of each partition in the order
+// they were listed to the servers and returns the connection to the first
+// one, which routes calls to the others. Closing it closes them all.
+func dialPartitioned(hosts []string, opts []grpc.DialOption) (*grpc.ClientConn, error) {
+	r := new(router)
+	for i, host := range hosts {
+		dialOpts := opts
+		if i == 0 {
+			dialOpts = append(dialOpts[:len(dialOpts):len(dialOpts)],
+				grpc.WithUnaryInterceptor(r.unary),
+				grpc.WithStreamInterceptor(r.stream))
+		}
+		conn, err := grpc.Dial(strings.TrimSpace(host), dialOpts...)
+		if err != nil {
+			for _, conn := range r.conns {
+				conn.Close()
+			}
+			return nil, err
+		}
+		r.conns = append(r.conns, conn)
+	}
+
+	go closeWith(r.conns[0], r.conns[1:])
+	return r.conns[0], nil
+}
+
+// invoker calls partition i, the first one through the interceptor chain
+// that is already running
+type invoker func(i int, req, reply interface{}) error
+
+func (r *router) unary(ctx context.Context, method string, req, reply interface{},
+	cc *grpc.ClientConn, next grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	call := func(i int, req, reply interface{}) error {
+		if i == 0 {
+			return next(ctx, method, req, reply, cc, opts...)
+		}
+		return r.conns[i].Invoke(ctx, method, req, reply, opts...)
+	}
+
+	switch path.Base(method) {
+	case "Get":
+		return r.get(call, req, reply.(*Data))
+	case "Done", "HeartBeat":
+		i, err := r.partition(req.(*JobID).Key)
+		if err != nil {
+			return err
+		}
+		return call(i, req, reply)
+	case "HeartBeats":
+		return r.heartBeats(call, req.(*Leases), reply.(*Renewal))
+	case "Progress":
+		return r.progress(call, req, reply.(*JobProgress))
+	case "Show":
+		return r.show(call, req, reply.(*Data))
+	case "Reset", "Rescan", "Shuffle", "Drain", "Resume":
+		return r.broadcast(call, req, reply.(*Ack))
+	}
+	return next(ctx, method, req, reply, cc, opts...)
+}
+
+// partition returns the partition that granted key
+func (r *router) partition(key string) (int, error) {
+	i, ok := KeyPartition(key)
+	if !ok || i >= len(r.conns) {
+		return 0, status.Error(codes.InvalidArgument, "lease key names no known partition: "+key)
+	}
+	return i, nil
+}
+
+// get asks the partitions in turn, starting after the one asked last, and
+// returns the first batch of tokens. It fails only if no partition had work
+// and some could not be asked, unless some are draining.
+func (r *router) get(call invoker, req interface{}, reply *Data) error {
+	r.lock.Lock()
+	start := r.next
+	r.next = (r.next + 1) % len(r.conns)
+	r.lock.Unlock()
+
+	var firstErr error
+	for n := range r.conns {
+		i := (start + n) % len(r.conns)
+		out := new(Data)
+		if err := call(i, req, out); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if len(out.Tokens) > 0 {
+			protobuf.Merge(reply, out)
+			return nil
+		}
+		reply.Draining = reply.Draining || out.Draining
+	}
+	if reply.Draining {
+		return nil
+	}
+	return firstErr
+}
+
+// heartBeats renews the keys of each partition with that partition. The job
+// is complete once it is complete on all partitions asked.
+func (r *router) heartBeats(call invoker, req *Leases, reply *Renewal) error {
+	keys := make(map[int][]string)
+	for _, key := range req.Keys {
+		i, err := r.partition(key)
+		if err != nil {
+			return err
+		}
+		keys[i] = append(keys[i], key)
+	}
+	if len(req.Keys) == 0 {
+		for i := range r.conns {
+			keys[i] = nil
+		}
+	}
+
+	alive := make(map[string]bool)
+	reply.Completed = true
+	for i, keys := range keys {
+		out := new(Renewal)
+		if err := call(i, &Leases{ID: req.ID, Worker: req.Worker, Keys: keys}, out); err != nil {
+			return err
+		}
+		for _, l := range out.Leases {
+			alive[l.Key] = l.Status
+		}
+		reply.Completed = reply.Completed && out.Completed
+		reply.Draining = reply.Draining || out.Draining
+	}
+
+	for _, key := range req.Keys {
+		reply.Leases = append(reply.Leases, &LeaseStatus{Key: key, Status: alive[key]})
+	}
+	return nil
+}
+
+// progress adds up the progress of a job on all partitions that know it
+func (r *router) progress(call invoker, req interface{}, reply *JobProgress) error {
+	found := false
+	reply.Completed = true
+	for i := range r.conns {
+		out := new(JobProgress)
+		err := call(i, req, out)
+		if status.Code(err) == codes.NotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		found = true
+		reply.ID = out.ID
+		reply.Tokens += out.Tokens
+		reply.Dispatched += out.Dispatched
+		reply.TokensCompleted += out.TokensCompleted
+		reply.LeasesGranted += out.LeasesGranted
+		reply.LeasesReassigned += out.LeasesReassigned
+		reply.LeasesCompleted += out.LeasesCompleted
+		reply.LeasesOutstanding += out.LeasesOutstanding
+		reply.Completed = reply.Completed && out.Completed
+	}
+	if !found {
+		return status.Error(codes.NotFound, "job id not present")
+	}
+	return nil
+}
+
+// show lists the tokens of all partitions
+func (r *router) show(call invoker, req interface{}, reply *Data) error {
+	for i := range r.conns {
+		out := new(Data)
+		if err := call(i, req, out); err != nil {
+			return err
+		}
+		reply.Tokens = append(reply.Tokens, out.Tokens...)
+	}
+	return nil
+}
+
+// broadcast makes an admin call on all partitions, counts add up and the
+// status holds if it holds on all of them
+func (r *router) broadcast(call invoker, req interface{}, reply *Ack) error {
+	reply.Status = true
+	for i := range r.conns {
+		out := new(Ack)
+		if err := call(i, req, out); err != nil {
+			return err
+		}
+		reply.N += out.N
+		reply.Status = reply.Status && out.Status
+		reply.Draining = reply.Draining || out.Draining
+	}
+	return nil
+}
+
+// stream reads the streams of all partitions one after the other
+func (r *router) stream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+	method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	open := func(i int) (grpc.ClientStream, error) {
+		if i == 0 {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		return r.conns[i].NewStream(ctx, desc, method, opts...)
+	}
+	if desc.ClientStreams && desc.ServerStreams {
+		return open(0)
+	}
+	if desc.ClientStreams {
+		streams := make([]grpc.ClientStream, len(r.conns))
+		for i := range streams {
+			stream, err := open(i)
+			if err != nil {
+				return nil, err
+			}
+			streams[i] = stream
+		}
+		return &broadcastStream{ClientStream: streams[0], streams: streams}, nil
+	}
+
+	stream, err := open(0)
+	if err != nil {
+		return nil, err
+	}
+	return &chainedStream{ClientStream: stream, open: open, n: len(r.conns)}, nil
+}
+
+// broadcastStream sends each request to all partitions and adds up their
+// acks, the only replies client streams of the Tokens service have
+type broadcastStream struct {
+	grpc.ClientStream
+	streams []grpc.ClientStream
+}
+
+func (s *broadcastStream) SendMsg(m interface{}) error {
+	for _, stream := range s.streams {
+		if err := stream.SendMsg(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *broadcastStream) CloseSend() error {
+	for _, stream := range s.streams {
+		if err := stream.CloseSend(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *broadcastStream) RecvMsg(m interface{}) error {
+	reply := m.(*Ack)
+	reply.Status = true
+	for _, stream := range s.streams {
+		out := new(Ack)
+		if err := stream.RecvMsg(out); err != nil {
+			return err
+		}
+		reply.N += out.N
+		reply.Status = reply.Status && out.Status
+		reply.Draining = reply.Draining || out.Draining
+	}
+	return nil
+}
+
+// chainedStream sends its single request to each partition in turn and
+// receives their replies until the last one ends. Partitions that do not know
+// the job are skipped, the stream fails with codes.NotFound only if none does.
+type chainedStream struct {
+	grpc.ClientStream
+	open func(i int) (grpc.ClientStream, error)
+	n    int
+
+	current  int
+	req      interface{}
+	found    bool
+	notFound error
+}
+
+func (s *chainedStream) SendMsg(m interface{}) error {
+	s.req = m
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *chainedStream) RecvMsg(m interface{}) error {
+	for {
+		err := s.ClientStream.RecvMsg(m)
+		switch {
+		case err == nil:
+			s.found = true
+			return nil
+		case err == io.EOF:
+			s.found = true
+		case status.Code(err) == codes.NotFound:
+			s.notFound = err
+		default:
+			return err
+		}
+		if s.current+1 == s.n {
+			if !s.found {
+				return s.notFound
+			}
+			return io.EOF
+		}
+
+		s.current++
+		stream, err := s.open(s.current)
+		if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(s.req); err != nil {
+			return err
+		}
+		if err := stream.CloseSend(); err != nil {
+			return err
+		}
+		s.ClientStream = stream
+	}
+}
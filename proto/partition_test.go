@@ -0,0 +1,186 @@
+package proto
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	protobuf "github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestKeyPartition(t *testing.T) {
+	tests := []struct {
+		key       string
+		partition int
+		ok        bool
+	}{
+		{PartitionKey(0, "aBcD"), 0, true},
+		{PartitionKey(12, "aBcD"), 12, true},
+		{PartitionKey(3, "a.b"), 3, true},
+		{"aBcD", 0, false},
+		{"x.aBcD", 0, false},
+		{"-1.aBcD", 0, false},
+		{".aBcD", 0, false},
+	}
+	for _, test := range tests {
+		partition, ok := KeyPartition(test.key)
+		if ok != test.ok || partition != test.partition {
+			t.Errorf("%q: expected %d %v, got %d %v", test.key, test.partition, test.ok, partition, ok)
+		}
+	}
+}
+
+var (
+	errNotFound    = status.Error(codes.NotFound, "job id not present")
+	errUnavailable = status.Error(codes.Unavailable, "connection refused")
+)
+
+func TestProgress(t *testing.T) {
+	tests := []struct {
+		name string
+		// replies holds a *JobProgress or an error per partition
+		replies []interface{}
+		want    *JobProgress
+		code    codes.Code
+	}{
+		{
+			name: "all partitions know the job",
+			replies: []interface{}{
+				&JobProgress{ID: "job", Tokens: 10, TokensCompleted: 10, Completed: true},
+				&JobProgress{ID: "job", Tokens: 20, TokensCompleted: 5},
+			},
+			want: &JobProgress{ID: "job", Tokens: 30, TokensCompleted: 15},
+		},
+		{
+			name: "partitions that do not know the job are skipped",
+			replies: []interface{}{
+				errNotFound,
+				&JobProgress{ID: "job", Tokens: 20, TokensCompleted: 20, Completed: true},
+				errNotFound,
+			},
+			want: &JobProgress{ID: "job", Tokens: 20, TokensCompleted: 20, Completed: true},
+		},
+		{
+			name:    "no partition knows the job",
+			replies: []interface{}{errNotFound, errNotFound},
+			code:    codes.NotFound,
+		},
+		{
+			name: "failures are not skipped",
+			replies: []interface{}{
+				&JobProgress{ID: "job", Tokens: 10},
+				errUnavailable,
+			},
+			code: codes.Unavailable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &router{conns: make([]*grpc.ClientConn, len(test.replies))}
+			call := func(i int, req, reply interface{}) error {
+				if err, ok := test.replies[i].(error); ok {
+					return err
+				}
+				protobuf.Merge(reply.(*JobProgress), test.replies[i].(*JobProgress))
+				return nil
+			}
+
+			got := new(JobProgress)
+			err := r.progress(call, &JobID{ID: "job"}, got)
+			if status.Code(err) != test.code {
+				t.Fatalf("expected %s, got %v", test.code, err)
+			}
+			if err == nil && !protobuf.Equal(got, test.want) {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+// recordStream replies with records and then ends with err
+type recordStream struct {
+	grpc.ClientStream
+	records []string
+	err     error
+}
+
+func (s *recordStream) SendMsg(m interface{}) error { return nil }
+func (s *recordStream) CloseSend() error            { return nil }
+
+func (s *recordStream) RecvMsg(m interface{}) error {
+	if len(s.records) == 0 {
+		return s.err
+	}
+	m.(*Record).Token = s.records[0]
+	s.records = s.records[1:]
+	return nil
+}
+
+func TestChainedStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		streams []*recordStream
+		want    []string
+		err     error
+	}{
+		{
+			name:    "all partitions know the job",
+			streams: []*recordStream{{records: []string{"a"}, err: io.EOF}, {records: []string{"b", "c"}, err: io.EOF}},
+			want:    []string{"a", "b", "c"},
+			err:     io.EOF,
+		},
+		{
+			name:    "first partition does not know the job",
+			streams: []*recordStream{{err: errNotFound}, {records: []string{"b"}, err: io.EOF}},
+			want:    []string{"b"},
+			err:     io.EOF,
+		},
+		{
+			name:    "last partition does not know the job",
+			streams: []*recordStream{{records: []string{"a"}, err: io.EOF}, {err: errNotFound}},
+			want:    []string{"a"},
+			err:     io.EOF,
+		},
+		{
+			name:    "no partition knows the job",
+			streams: []*recordStream{{err: errNotFound}, {err: errNotFound}},
+			err:     errNotFound,
+		},
+		{
+			name:    "failures end the stream",
+			streams: []*recordStream{{records: []string{"a"}, err: errUnavailable}, {records: []string{"b"}, err: io.EOF}},
+			want:    []string{"a"},
+			err:     errUnavailable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			open := func(i int) (grpc.ClientStream, error) { return test.streams[i], nil }
+			s := &chainedStream{ClientStream: test.streams[0], open: open, n: len(test.streams)}
+			if err := s.SendMsg(&JobID{ID: "job"}); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			var err error
+			for {
+				record := new(Record)
+				if err = s.RecvMsg(record); err != nil {
+					break
+				}
+				got = append(got, record.Token)
+			}
+			if err != test.err {
+				t.Errorf("expected stream to end with %v, got %v", test.err, err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package proto
+
+import (
+	"encoding/json"
+)
+
+// recordLine is the JSON form of a Record, one per line in result files
+type recordLine struct {
+	Token string          `json:"token"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// MarshalRecord renders r as a single line of JSON. Data is embedded as is
+// when it holds valid JSON and as a JSON string otherwise.
+func MarshalRecord(r *Record) ([]byte, error) {
+	line := recordLine{Token: r.Token, Error: r.Error}
+	if len(r.Data) > 0 {
+		if json.Valid(r.Data) {
+			line.Data = r.Data
+		} else {
+			b, err := json.Marshal(string(r.Data))
+			if err != nil {
+				return nil, err
+			}
+			line.Data = b
+		}
+	}
+	return json.Marshal(line)
+}
+
+// UnmarshalRecord parses a line produced by MarshalRecord
+func UnmarshalRecord(b []byte) (*Record, error) {
+	line := new(recordLine)
+	if err := json.Unmarshal(b, line); err != nil {
+		return nil, err
+	}
+	return &Record{Token: line.Token, Data: line.Data, Error: line.Error}, nil
+}
@@ -0,0 +1,105 @@
+package proto
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Backoff retries calls with exponentially growing, jittered delays
+type Backoff struct {
+	// Retries is how often a call is retried after its first attempt
+	Retries int
+	// Initial is the delay before the first retry
+	Initial time.Duration
+	// Max caps the delay between retries
+	Max time.Duration
+	// Multiplier grows the delay after every retry
+	Multiplier float64
+	// Jitter randomizes delays by up to this fraction so that workers that
+	// failed together do not retry together
+	Jitter float64
+	// OnRetry is called with the error that caused a retry before waiting
+	// for it, the retry is logged to the standard logger if it is nil
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// DefaultBackoff rides out a server restart of about a minute
+func DefaultBackoff() Backoff {
+	return Backoff{
+		Retries:    8,
+		Initial:    time.Second,
+		Max:        time.Second * 15,
+		Multiplier: 1.6,
+		Jitter:     0.2,
+	}
+}
+
+// Delay returns how long to wait before retry attempt, counting from 1
+func (b Backoff) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		return 0
+	}
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(b.Initial) * math.Pow(multiplier, float64(attempt-1))
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	delay *= 1 + b.Jitter*(2*rand.Float64()-1)
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay)
+}
+
+// Retry calls f until it succeeds, fails with an error that is not
+// retryable, the retries are used up or ctx is done. Only use it for calls
+// that are safe to repeat.
+func (b Backoff) Retry(ctx context.Context, f func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; attempt <= b.Retries; attempt++ {
+		if attempt > 0 {
+			delay := b.Delay(attempt)
+			if b.OnRetry != nil {
+				b.OnRetry(attempt, delay, err)
+			} else {
+				logrus.WithField("attempt", attempt).
+					WithField("delay", delay).
+					WithField("error", err).
+					Warn("retrying")
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		if err = f(ctx); err == nil || !Retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// Retryable reports whether err is transient, such as the server being
+// unreachable while it restarts. Errors returned by the server itself are
+// fatal since repeating the call would only fail the same way. That includes
+// ResourceExhausted, which grpc returns for messages over the size limit.
+func Retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
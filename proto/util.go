@@ -2,17 +2,64 @@ package proto
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"os"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// ServiceName is the full name of the Tokens service, servers report their
+// health under it
+const ServiceName = "proto.Tokens"
+
+// WorkerID returns an identifier for the calling process that is stable
+// for its lifetime and distinct across processes on different hosts
+func WorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// ListTokens collects the names of tokens that pass the filter of req, up to
+// limit of them if limit is positive
+func ListTokens(ctx context.Context, client TokensClient, req *ListRequest, limit int) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.List(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for limit <= 0 || len(names) < limit {
+		page, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, page.Tokens...)
+	}
+	if limit > 0 && len(names) > limit {
+		names = names[:limit]
+	}
+	return names, nil
+}
+
+// HeartBeat keeps a lease alive. Transient errors are tolerated up to
+// Backoff.Retries times in a row before Check reports them.
 type HeartBeat struct {
-	Beat   chan error
-	Done   chan bool
-	Client TokensClient
-	JobID  string
-	Key    string
+	Beat    chan error
+	Done    chan bool
+	Client  TokensClient
+	JobID   string
+	Key     string
+	Backoff Backoff
 }
 
 func NewHeartBeat(client TokensClient, jobID, key string) *HeartBeat {
@@ -22,16 +69,24 @@ func NewHeartBeat(client TokensClient, jobID, key string) *HeartBeat {
 	h.Client = client
 	h.JobID = jobID
 	h.Key = key
+	h.Backoff = DefaultBackoff()
 	return h
 }
 
 func (h *HeartBeat) Start() {
 	go func(heartBeat chan error) {
+		failures := 0
 		for {
 			logrus.Info("client sending HeartBeat() for job id: ", h.JobID, ", key: ", h.Key)
 			if ack, err := h.Client.HeartBeat(context.Background(), &JobID{ID: h.JobID, Key: h.Key}); err != nil {
-				heartBeat <- err
+				failures++
+				if Retryable(err) && failures <= h.Backoff.Retries {
+					logrus.Warn("client HeartBeat() failed for job id: ", h.JobID, ", retrying: ", err)
+				} else {
+					heartBeat <- err
+				}
 			} else {
+				failures = 0
 				if ack.Status {
 					logrus.Info("client returning for job id: ", h.JobID)
 					heartBeat <- nil
@@ -0,0 +1,759 @@
+// Package raft replicates a log of commands across a fixed set of servers
+// with the Raft consensus algorithm and applies committed commands to a
+// state machine on each of them. Servers talk to each other through the
+// Raft service of package proto, usually on the grpc server that also serves
+// clients.
+package raft
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// MaxMessageSize bounds messages between servers, snapshots carry the whole
+// state machine
+const MaxMessageSize = 1 << 30
+
+// maxEntries is the most entries sent with a single AppendEntries call
+const maxEntries = 256
+
+var (
+	// ErrNotLeader is returned for commands proposed to a follower
+	ErrNotLeader = errors.New("not the leader")
+	// ErrStopped is returned for commands pending when the node stops
+	ErrStopped = errors.New("raft node stopped")
+)
+
+// FSM is the state machine that committed commands are applied to
+type FSM interface {
+	// Apply applies a command, the result is returned to the proposer
+	Apply(data []byte) interface{}
+	// Snapshot returns the state after all commands applied so far
+	Snapshot() ([]byte, error)
+	// Restore replaces the state with a snapshot
+	Restore(data []byte) error
+}
+
+// Config configures a Node
+type Config struct {
+	// ID is the address other servers reach this one at
+	ID string
+	// Peers are the IDs of all servers, including this one
+	Peers []string
+	// Dir is the folder the log and snapshots are kept in
+	Dir string
+	// HeartbeatInterval is how often the leader contacts followers
+	HeartbeatInterval time.Duration
+	// ElectionTimeout is how long followers wait for the leader before
+	// they stand for election, randomized up to twice as long
+	ElectionTimeout time.Duration
+	// SnapshotThreshold is how many entries are applied between snapshots
+	SnapshotThreshold uint64
+	// DialOptions are used to connect to peers
+	DialOptions []grpc.DialOption
+	// Elected, if set, is called when the node has won an election and
+	// applied the entry it commits as leader, so that the state machine
+	// holds every entry committed by earlier leaders. It is called before
+	// the node reports Ready, with the node locked, and must not call back
+	// into it.
+	Elected func()
+	// Replayed, if set, is called once the node has first applied an entry
+	// of its current term, which means it has replayed its log up to where
+	// the cluster is. It is called with the node locked and must not call
+	// back into it.
+	Replayed func()
+	// Log defaults to the standard logrus logger
+	Log logrus.FieldLogger
+}
+
+type role int
+
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+func (r role) String() string {
+	switch r {
+	case candidate:
+		return "candidate"
+	case leader:
+		return "leader"
+	}
+	return "follower"
+}
+
+// outcome is what a proposer gets back once its command is applied
+type outcome struct {
+	result interface{}
+	err    error
+}
+
+// proposal waits for a command proposed in term
+type proposal struct {
+	term uint64
+	done chan outcome
+}
+
+// Node is one server of a replicated state machine
+type Node struct {
+	config Config
+	fsm    FSM
+	store  *storage
+	log    logrus.FieldLogger
+	others []string
+	peers  map[string]proto.RaftClient
+	conns  []*grpc.ClientConn
+
+	lock        sync.Mutex
+	role        role
+	term        uint64
+	votedFor    string
+	leader      string
+	passive     bool
+	deadline    time.Time
+	entries     []*proto.Entry
+	snapIndex   uint64
+	snapTerm    uint64
+	snapshot    []byte
+	commitIndex uint64
+	lastApplied uint64
+	nextIndex   map[string]uint64
+	matchIndex  map[string]uint64
+	inflight    map[string]bool
+	pending     map[uint64]*proposal
+	noop        uint64 // index of the entry committed when elected
+	replayed    bool
+
+	// applyLock keeps snapshots from being installed while entries are applied
+	applyLock sync.Mutex
+	applyc    chan struct{}
+	kick      chan struct{}
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// New restores a node from the snapshot and log in config.Dir, if there are
+// any, and connects to its peers. It takes part in elections once started.
+func New(config Config, fsm FSM) (*Node, error) {
+	if config.HeartbeatInterval <= 0 {
+		config.HeartbeatInterval = time.Millisecond * 100
+	}
+	if config.ElectionTimeout <= 0 {
+		config.ElectionTimeout = time.Second
+	}
+	if config.SnapshotThreshold == 0 {
+		config.SnapshotThreshold = 8192
+	}
+	if config.Log == nil {
+		config.Log = logrus.StandardLogger()
+	}
+	if config.ElectionTimeout <= config.HeartbeatInterval {
+		return nil, errors.New("election timeout has to be longer than the heartbeat interval")
+	}
+
+	n := &Node{
+		config:     config,
+		fsm:        fsm,
+		store:      &storage{dir: config.Dir},
+		log:        config.Log.WithField("raft", config.ID),
+		peers:      make(map[string]proto.RaftClient),
+		nextIndex:  make(map[string]uint64),
+		matchIndex: make(map[string]uint64),
+		inflight:   make(map[string]bool),
+		pending:    make(map[uint64]*proposal),
+		applyc:     make(chan struct{}, 1),
+		kick:       make(chan struct{}, 1),
+	}
+
+	member := false
+	for _, id := range config.Peers {
+		if id == config.ID {
+			member = true
+			continue
+		}
+		n.others = append(n.others, id)
+	}
+	if !member {
+		return nil, errors.New("raft id " + config.ID + " is not one of the peers")
+	}
+
+	if err := os.MkdirAll(config.Dir, 0700); err != nil {
+		return nil, err
+	}
+	state, err := n.store.loadState()
+	if err != nil {
+		return nil, err
+	}
+	n.term, n.votedFor = state.Term, state.VotedFor
+
+	snap, err := n.store.loadSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if snap != nil {
+		if err := fsm.Restore(snap.Data); err != nil {
+			return nil, err
+		}
+		n.snapIndex, n.snapTerm, n.snapshot = snap.Index, snap.Term, snap.Data
+		n.commitIndex, n.lastApplied = snap.Index, snap.Index
+	}
+	if n.entries, err = n.store.loadLog(n.snapIndex); err != nil {
+		return nil, err
+	}
+
+	for _, id := range n.others {
+		conn, err := grpc.Dial(id, config.DialOptions...)
+		if err != nil {
+			n.close()
+			return nil, err
+		}
+		n.conns = append(n.conns, conn)
+		n.peers[id] = proto.NewRaftClient(conn)
+	}
+
+	n.log.WithField("term", n.term).
+		WithField("snapshot", n.snapIndex).
+		WithField("entries", len(n.entries)).
+		Info("restored raft log")
+	return n, nil
+}
+
+// Start takes part in elections and applies committed entries until Stop
+func (n *Node) Start() {
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+
+	n.lock.Lock()
+	n.resetDeadline()
+	n.lock.Unlock()
+
+	n.wg.Add(2)
+	go n.run()
+	go n.apply()
+}
+
+// Stop leaves the cluster, commands that are still pending fail
+func (n *Node) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+		n.wg.Wait()
+	}
+
+	n.lock.Lock()
+	n.fail(ErrStopped)
+	n.lock.Unlock()
+	n.close()
+}
+
+func (n *Node) close() {
+	for _, conn := range n.conns {
+		conn.Close()
+	}
+}
+
+// Leader returns the ID of the current leader, empty if it is not known
+func (n *Node) Leader() string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.leader
+}
+
+// IsLeader tells whether this node is the leader
+func (n *Node) IsLeader() bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.role == leader
+}
+
+// Ready tells whether this node is the leader and has applied the entry it
+// committed when elected, so that its state machine can be read
+func (n *Node) Ready() bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.role == leader && n.current()
+}
+
+// StepDown gives up leadership, if the node has it, and stops the node from
+// standing for election again so that another server takes over before
+// this one is stopped
+func (n *Node) StepDown() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.passive = true
+	if n.role == leader {
+		n.log.WithField("term", n.term).Info("stepping down")
+		n.follow(n.term, "")
+	}
+}
+
+// Propose replicates data and returns the result of applying it once it is
+// committed. It fails with ErrNotLeader on followers.
+func (n *Node) Propose(ctx context.Context, data []byte) (interface{}, error) {
+	n.lock.Lock()
+	if n.role != leader {
+		n.lock.Unlock()
+		return nil, ErrNotLeader
+	}
+
+	e := &proto.Entry{Index: n.lastIndex() + 1, Term: n.term, Data: data}
+	if err := n.store.appendLog([]*proto.Entry{e}); err != nil {
+		n.lock.Unlock()
+		return nil, err
+	}
+	n.entries = append(n.entries, e)
+	p := &proposal{term: n.term, done: make(chan outcome, 1)}
+	n.pending[e.Index] = p
+	n.advanceCommit()
+	n.lock.Unlock()
+
+	select {
+	case n.kick <- struct{}{}:
+	default:
+	}
+
+	select {
+	case o := <-p.done:
+		return o.result, o.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-n.ctx.Done():
+		return nil, ErrStopped
+	}
+}
+
+// run sends heartbeats as leader and stands for election as follower
+func (n *Node) run() {
+	defer n.wg.Done()
+	ticker := time.NewTicker(n.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-n.kick:
+		case <-ticker.C:
+		}
+
+		n.lock.Lock()
+		switch {
+		case n.role == leader:
+			n.broadcast()
+		case !n.passive && time.Now().After(n.deadline):
+			n.campaign()
+		}
+		n.lock.Unlock()
+	}
+}
+
+// apply applies committed entries to the state machine in order and takes
+// a snapshot every SnapshotThreshold entries
+func (n *Node) apply() {
+	defer n.wg.Done()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-n.applyc:
+		}
+
+		n.applyLock.Lock()
+		n.lock.Lock()
+		var batch []*proto.Entry
+		for i := n.lastApplied + 1; i <= n.commitIndex; i++ {
+			batch = append(batch, n.entry(i))
+		}
+		n.lock.Unlock()
+
+		for _, e := range batch {
+			// leaders commit an empty entry when they are elected
+			var result interface{}
+			if len(e.Data) > 0 {
+				result = n.fsm.Apply(e.Data)
+			}
+
+			n.lock.Lock()
+			n.lastApplied = e.Index
+			n.caughtUp()
+			if n.role == leader && e.Index == n.noop && e.Term == n.term && n.config.Elected != nil {
+				n.config.Elected()
+			}
+			if p, present := n.pending[e.Index]; present {
+				delete(n.pending, e.Index)
+				if p.term == e.Term {
+					p.done <- outcome{result: result}
+				} else {
+					p.done <- outcome{err: ErrNotLeader}
+				}
+			}
+			n.lock.Unlock()
+		}
+
+		if err := n.compact(); err != nil {
+			n.log.WithField("error", err).Error("could not take snapshot")
+		}
+		n.applyLock.Unlock()
+	}
+}
+
+// compact replaces applied entries with a snapshot once there are enough of
+// them, the caller must hold the apply lock
+func (n *Node) compact() error {
+	n.lock.Lock()
+	index := n.lastApplied
+	term := n.termAt(index)
+	due := index-n.snapIndex >= n.config.SnapshotThreshold
+	n.lock.Unlock()
+	if !due {
+		return nil
+	}
+
+	data, err := n.fsm.Snapshot()
+	if err != nil {
+		return err
+	}
+	if err := n.store.saveSnapshot(&snapshot{Index: index, Term: term, Data: data}); err != nil {
+		return err
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.entries = append([]*proto.Entry(nil), n.entries[index-n.snapIndex:]...)
+	n.snapIndex, n.snapTerm, n.snapshot = index, term, data
+	n.log.WithField("index", index).Info("took snapshot")
+	return n.store.rewriteLog(n.entries)
+}
+
+// campaign stands for election in the next term, the caller must hold the lock
+func (n *Node) campaign() {
+	n.role = candidate
+	n.term++
+	n.votedFor = n.config.ID
+	n.leader = ""
+	n.resetDeadline()
+	if err := n.saveState(); err != nil {
+		n.log.WithField("error", err).Error("could not save raft state")
+		return
+	}
+	n.log.WithField("term", n.term).Info("standing for election")
+
+	votes := 1
+	if votes > len(n.config.Peers)/2 {
+		n.lead()
+		return
+	}
+
+	req := &proto.VoteRequest{
+		Term:         n.term,
+		Candidate:    n.config.ID,
+		LastLogIndex: n.lastIndex(),
+		LastLogTerm:  n.lastTerm(),
+	}
+	for _, id := range n.others {
+		n.wg.Add(1)
+		go func(client proto.RaftClient) {
+			defer n.wg.Done()
+			ctx, cancel := context.WithTimeout(n.ctx, n.config.ElectionTimeout)
+			defer cancel()
+			reply, err := client.RequestVote(ctx, req)
+			if err != nil {
+				return
+			}
+
+			n.lock.Lock()
+			defer n.lock.Unlock()
+			if reply.Term > n.term {
+				n.follow(reply.Term, "")
+				return
+			}
+			if n.role != candidate || n.term != req.Term || !reply.Granted {
+				return
+			}
+			votes++
+			if votes > len(n.config.Peers)/2 {
+				n.lead()
+			}
+		}(n.peers[id])
+	}
+}
+
+// lead takes over as leader, the caller must hold the lock
+func (n *Node) lead() {
+	n.role = leader
+	n.leader = n.config.ID
+	for _, id := range n.others {
+		n.nextIndex[id] = n.lastIndex() + 1
+		n.matchIndex[id] = 0
+	}
+	n.log.WithField("term", n.term).Info("elected leader")
+
+	// entries of earlier terms only count as committed once an entry of the
+	// current term is
+	e := &proto.Entry{Index: n.lastIndex() + 1, Term: n.term}
+	if err := n.store.appendLog([]*proto.Entry{e}); err != nil {
+		n.log.WithField("error", err).Error("could not append to raft log")
+		n.follow(n.term, "")
+		return
+	}
+	n.entries = append(n.entries, e)
+	n.noop = e.Index
+	n.advanceCommit()
+	n.broadcast()
+}
+
+// follow becomes a follower of the leader with id in term, the caller must
+// hold the lock. The election deadline is left alone so that candidates with
+// stale logs cannot keep others from standing for election.
+func (n *Node) follow(term uint64, id string) {
+	if term > n.term {
+		n.term = term
+		n.votedFor = ""
+		if err := n.saveState(); err != nil {
+			n.log.WithField("error", err).Error("could not save raft state")
+		}
+	}
+	if n.role == leader {
+		n.fail(ErrNotLeader)
+	}
+	if n.role != follower || n.leader != id {
+		n.log.WithField("term", n.term).WithField("leader", id).Info("following")
+	}
+	n.role = follower
+	n.leader = id
+}
+
+// fail ends all pending proposals with err, the caller must hold the lock
+func (n *Node) fail(err error) {
+	for index, p := range n.pending {
+		p.done <- outcome{err: err}
+		delete(n.pending, index)
+	}
+}
+
+// broadcast replicates to every peer that has no call in flight, the caller
+// must hold the lock
+func (n *Node) broadcast() {
+	for _, id := range n.others {
+		if n.inflight[id] {
+			continue
+		}
+		n.inflight[id] = true
+		n.wg.Add(1)
+		go n.replicate(id, n.term)
+	}
+}
+
+// replicate sends entries or a snapshot to peer id until it has caught up
+// with the leader of term
+func (n *Node) replicate(id string, term uint64) {
+	defer n.wg.Done()
+	client := n.peers[id]
+
+	for {
+		n.lock.Lock()
+		if n.role != leader || n.term != term {
+			n.inflight[id] = false
+			n.lock.Unlock()
+			return
+		}
+
+		var err error
+		if next := n.nextIndex[id]; next <= n.snapIndex {
+			err = n.sendSnapshot(id, client)
+		} else {
+			err = n.sendEntries(id, client, next)
+		}
+		if err != nil {
+			n.log.WithField("peer", id).WithField("error", err).Debug("could not replicate")
+		}
+
+		// the lock is held again here
+		if err != nil || n.role != leader || n.term != term || n.nextIndex[id] > n.lastIndex() {
+			n.inflight[id] = false
+			n.lock.Unlock()
+			return
+		}
+		n.lock.Unlock()
+	}
+}
+
+// sendEntries sends entries starting at next, it is called and returns with
+// the lock held but releases it during the call
+func (n *Node) sendEntries(id string, client proto.RaftClient, next uint64) error {
+	last := n.lastIndex()
+	if last >= next+maxEntries {
+		last = next + maxEntries - 1
+	}
+	req := &proto.AppendRequest{
+		Term:         n.term,
+		Leader:       n.config.ID,
+		PrevLogIndex: next - 1,
+		PrevLogTerm:  n.termAt(next - 1),
+		LeaderCommit: n.commitIndex,
+	}
+	for i := next; i <= last; i++ {
+		req.Entries = append(req.Entries, n.entry(i))
+	}
+	n.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(n.ctx, n.config.ElectionTimeout)
+	reply, err := client.AppendEntries(ctx, req)
+	cancel()
+	n.lock.Lock()
+	if err != nil {
+		return err
+	}
+
+	if reply.Term > n.term {
+		n.follow(reply.Term, "")
+		return nil
+	}
+	if n.role != leader || n.term != req.Term {
+		return nil
+	}
+	if reply.Success {
+		if match := req.PrevLogIndex + uint64(len(req.Entries)); match > n.matchIndex[id] {
+			n.matchIndex[id] = match
+		}
+		n.nextIndex[id] = n.matchIndex[id] + 1
+		n.advanceCommit()
+		return nil
+	}
+
+	// back off to just past the last entry the follower may have in common
+	next = n.nextIndex[id] - 1
+	if reply.LastIndex+1 < next {
+		next = reply.LastIndex + 1
+	}
+	if next < 1 {
+		next = 1
+	}
+	n.nextIndex[id] = next
+	return nil
+}
+
+// sendSnapshot sends the latest snapshot, it is called and returns with the
+// lock held but releases it during the call
+func (n *Node) sendSnapshot(id string, client proto.RaftClient) error {
+	req := &proto.SnapshotRequest{
+		Term:      n.term,
+		Leader:    n.config.ID,
+		LastIndex: n.snapIndex,
+		LastTerm:  n.snapTerm,
+		Data:      n.snapshot,
+	}
+	n.lock.Unlock()
+	n.log.WithField("peer", id).WithField("index", req.LastIndex).Info("sending snapshot")
+
+	ctx, cancel := context.WithTimeout(n.ctx, n.config.ElectionTimeout*30)
+	reply, err := client.InstallSnapshot(ctx, req, grpc.MaxCallSendMsgSize(MaxMessageSize))
+	cancel()
+	n.lock.Lock()
+	if err != nil {
+		return err
+	}
+
+	if reply.Term > n.term {
+		n.follow(reply.Term, "")
+		return nil
+	}
+	if n.role != leader || n.term != req.Term {
+		return nil
+	}
+	if req.LastIndex > n.matchIndex[id] {
+		n.matchIndex[id] = req.LastIndex
+	}
+	n.nextIndex[id] = n.matchIndex[id] + 1
+	return nil
+}
+
+// advanceCommit commits the latest entry of the current term that a majority
+// holds, the caller must hold the lock
+func (n *Node) advanceCommit() {
+	for index := n.lastIndex(); index > n.commitIndex && index > n.snapIndex; index-- {
+		if n.termAt(index) != n.term {
+			return
+		}
+
+		votes := 1
+		for _, id := range n.others {
+			if n.matchIndex[id] >= index {
+				votes++
+			}
+		}
+		if votes > len(n.config.Peers)/2 {
+			n.commitIndex = index
+			n.signalApply()
+			return
+		}
+	}
+}
+
+// current tells whether an entry of the current term has been applied, the
+// caller must hold the lock
+func (n *Node) current() bool {
+	return n.term > 0 && n.termAt(n.lastApplied) == n.term
+}
+
+// caughtUp calls Replayed the first time the node is current, the caller
+// must hold the lock
+func (n *Node) caughtUp() {
+	if n.replayed || !n.current() {
+		return
+	}
+	n.replayed = true
+	n.log.WithField("term", n.term).WithField("index", n.lastApplied).Info("replayed raft log")
+	if n.config.Replayed != nil {
+		n.config.Replayed()
+	}
+}
+
+func (n *Node) signalApply() {
+	select {
+	case n.applyc <- struct{}{}:
+	default:
+	}
+}
+
+// resetDeadline picks a random election timeout, the caller must hold the lock
+func (n *Node) resetDeadline() {
+	timeout := n.config.ElectionTimeout + time.Duration(rand.Int63n(int64(n.config.ElectionTimeout)))
+	n.deadline = time.Now().Add(timeout)
+}
+
+func (n *Node) saveState() error {
+	return n.store.saveState(hardState{Term: n.term, VotedFor: n.votedFor})
+}
+
+func (n *Node) lastIndex() uint64 {
+	return n.snapIndex + uint64(len(n.entries))
+}
+
+func (n *Node) lastTerm() uint64 {
+	return n.termAt(n.lastIndex())
+}
+
+// termAt returns the term of the entry at index, which has to be the last
+// one covered by the snapshot or a later one
+func (n *Node) termAt(index uint64) uint64 {
+	if index == n.snapIndex {
+		return n.snapTerm
+	}
+	return n.entry(index).Term
+}
+
+func (n *Node) entry(index uint64) *proto.Entry {
+	return n.entries[index-n.snapIndex-1]
+}
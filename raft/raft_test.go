@@ -0,0 +1,355 @@
+package raft
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// listFSM appends every command to a list
+type listFSM struct {
+	lock     sync.Mutex
+	commands []string
+}
+
+func (f *listFSM) Apply(data []byte) interface{} {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.commands = append(f.commands, string(data))
+	return len(f.commands)
+}
+
+func (f *listFSM) Snapshot() ([]byte, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return json.Marshal(f.commands)
+}
+
+func (f *listFSM) Restore(data []byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.commands = nil
+	return json.Unmarshal(data, &f.commands)
+}
+
+func (f *listFSM) list() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+// member is a node of a test cluster along with its server
+type member struct {
+	node   *Node
+	fsm    *listFSM
+	server *grpc.Server
+	dir    string
+
+	// lock guards how many commands the state machine held when the node
+	// was elected and when it replayed its log, -1 until then
+	lock     sync.Mutex
+	elected  int
+	replayed int
+}
+
+// seen returns what the callbacks of the node recorded
+func (m *member) seen() (elected, replayed int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.elected, m.replayed
+}
+
+// cluster runs nodes on local ports, a stopped member is nil
+type cluster struct {
+	t         *testing.T
+	addrs     []string
+	members   []*member
+	threshold uint64
+}
+
+func newCluster(t *testing.T, n int, threshold uint64) *cluster {
+	c := &cluster{t: t, threshold: threshold, members: make([]*member, n)}
+	for i := 0; i < n; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.addrs = append(c.addrs, lis.Addr().String())
+		lis.Close()
+	}
+	for i := range c.members {
+		c.start(i, t.TempDir())
+	}
+	t.Cleanup(func() {
+		for i := range c.members {
+			c.stop(i)
+		}
+	})
+	return c
+}
+
+// start runs member i on its address with the log kept in dir
+func (c *cluster) start(i int, dir string) {
+	log := logrus.New()
+	log.Out = ioutil.Discard
+
+	fsm := &listFSM{}
+	m := &member{fsm: fsm, dir: dir, elected: -1, replayed: -1}
+	record := func(n *int) func() {
+		return func() {
+			m.lock.Lock()
+			*n = len(fsm.list())
+			m.lock.Unlock()
+		}
+	}
+	node, err := New(Config{
+		ID:                c.addrs[i],
+		Peers:             c.addrs,
+		Dir:               dir,
+		HeartbeatInterval: 20 * time.Millisecond,
+		ElectionTimeout:   200 * time.Millisecond,
+		SnapshotThreshold: c.threshold,
+		DialOptions:       []grpc.DialOption{grpc.WithInsecure()},
+		Elected:           record(&m.elected),
+		Replayed:          record(&m.replayed),
+		Log:               log,
+	}, fsm)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	// the port of a stopped member may take a moment to be free again
+	var lis net.Listener
+	for attempt := 0; ; attempt++ {
+		if lis, err = net.Listen("tcp", c.addrs[i]); err == nil {
+			break
+		}
+		if attempt == 50 {
+			c.t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	s := grpc.NewServer()
+	proto.RegisterRaftServer(s, node)
+	go s.Serve(lis)
+	m.node, m.server = node, s
+	node.Start()
+	c.members[i] = m
+}
+
+func (c *cluster) stop(i int) {
+	if m := c.members[i]; m != nil {
+		m.server.Stop()
+		m.node.Stop()
+		c.members[i] = nil
+	}
+}
+
+// leader waits for a running member to lead and returns its index
+func (c *cluster) leader() int {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		for i, m := range c.members {
+			if m != nil && m.node.IsLeader() {
+				return i
+			}
+		}
+	}
+	c.t.Fatal("no leader elected")
+	return -1
+}
+
+// propose proposes commands on the leader
+func (c *cluster) propose(commands ...string) {
+	for _, command := range commands {
+		for attempt := 0; ; attempt++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			_, err := c.members[c.leader()].node.Propose(ctx, []byte(command))
+			cancel()
+			if err == nil {
+				break
+			}
+			// leadership may move between finding the leader and proposing
+			if attempt == 10 {
+				c.t.Fatal(err)
+			}
+		}
+	}
+}
+
+// converge waits for all running members to have applied want
+func (c *cluster) converge(want []string) {
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		done := true
+		for _, m := range c.members {
+			if m != nil && fmt.Sprint(m.fsm.list()) != fmt.Sprint(want) {
+				done = false
+			}
+		}
+		if done {
+			return
+		}
+		if time.Now().After(deadline) {
+			for i, m := range c.members {
+				if m != nil {
+					c.t.Errorf("member %d applied %v", i, m.fsm.list())
+				}
+			}
+			c.t.Fatalf("expected %v", want)
+		}
+	}
+}
+
+func commands(from, to int) []string {
+	var out []string
+	for i := from; i < to; i++ {
+		out = append(out, fmt.Sprintf("command %d", i))
+	}
+	return out
+}
+
+func TestElection(t *testing.T) {
+	c := newCluster(t, 3, 0)
+	leader := c.addrs[c.leader()]
+
+	// followers learn of the leader through its heartbeats
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		agreed := 0
+		for _, m := range c.members {
+			if m.node.Leader() == leader {
+				agreed++
+			}
+		}
+		if agreed == len(c.members) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d members follow %s", agreed, leader)
+		}
+	}
+
+	leaders := 0
+	for _, m := range c.members {
+		if m.node.IsLeader() {
+			leaders++
+		}
+	}
+	if leaders != 1 {
+		t.Errorf("expected a single leader, got %d", leaders)
+	}
+}
+
+func TestFollowerRefusesProposals(t *testing.T) {
+	c := newCluster(t, 3, 0)
+	leader := c.leader()
+	follower := (leader + 1) % len(c.members)
+	if _, err := c.members[follower].node.Propose(context.Background(), []byte("x")); err != ErrNotLeader {
+		t.Errorf("expected %v, got %v", ErrNotLeader, err)
+	}
+}
+
+func TestReplication(t *testing.T) {
+	c := newCluster(t, 3, 0)
+	want := commands(0, 20)
+	c.propose(want...)
+	c.converge(want)
+}
+
+func TestLeaderCrash(t *testing.T) {
+	c := newCluster(t, 3, 0)
+	c.propose(commands(0, 5)...)
+	c.converge(commands(0, 5))
+
+	old := c.leader()
+	c.stop(old)
+	if c.leader() == old {
+		t.Fatal("stopped member still leads")
+	}
+	c.propose(commands(5, 10)...)
+	c.converge(commands(0, 10))
+
+	// the old leader catches up when it comes back
+	c.start(old, t.TempDir())
+	c.converge(commands(0, 10))
+}
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold uint64
+	}{
+		{"from log", 1000},
+		{"from snapshot and log", 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newCluster(t, 3, test.threshold)
+			c.propose(commands(0, 10)...)
+			c.converge(commands(0, 10))
+
+			dirs := make([]string, len(c.members))
+			for i, m := range c.members {
+				dirs[i] = m.dir
+				c.stop(i)
+			}
+			for i, dir := range dirs {
+				c.start(i, dir)
+			}
+
+			// the restored state machines hold what was applied before,
+			// new commands go on top
+			c.propose(commands(10, 12)...)
+			c.converge(commands(0, 12))
+		})
+	}
+}
+
+func TestCallbacksAfterReplay(t *testing.T) {
+	c := newCluster(t, 3, 1000)
+	c.propose(commands(0, 10)...)
+	c.converge(commands(0, 10))
+
+	dirs := make([]string, len(c.members))
+	for i, m := range c.members {
+		dirs[i] = m.dir
+		c.stop(i)
+	}
+	for i, dir := range dirs {
+		c.start(i, dir)
+	}
+
+	// the log is only applied once the new leader commits an entry, which
+	// the callbacks and Ready wait for
+	leader := c.leader()
+	for deadline := time.Now().Add(5 * time.Second); !c.members[leader].node.Ready(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("leader never became ready")
+		}
+	}
+	c.converge(commands(0, 10))
+	for i, m := range c.members {
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			elected, replayed := m.seen()
+			if replayed >= 0 {
+				if replayed != 10 {
+					t.Errorf("member %d replayed its log with %d of 10 commands applied", i, replayed)
+				}
+				if m.node.IsLeader() && elected != 10 {
+					t.Errorf("leader was elected with %d of 10 commands applied", elected)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("member %d never replayed its log", i)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package raft
+
+import (
+	"context"
+
+	"github.com/sdeoras/token/proto"
+)
+
+// RequestVote grants the vote of this node for the term of req to a
+// candidate whose log is at least as up to date as its own
+func (n *Node) RequestVote(ctx context.Context, req *proto.VoteRequest) (*proto.VoteReply, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if req.Term < n.term {
+		return &proto.VoteReply{Term: n.term}, nil
+	}
+	if req.Term > n.term {
+		n.follow(req.Term, "")
+	}
+
+	upToDate := req.LastLogTerm > n.lastTerm() ||
+		(req.LastLogTerm == n.lastTerm() && req.LastLogIndex >= n.lastIndex())
+	if (n.votedFor == "" || n.votedFor == req.Candidate) && upToDate {
+		n.votedFor = req.Candidate
+		if err := n.saveState(); err != nil {
+			return nil, err
+		}
+		n.resetDeadline()
+		n.log.WithField("term", n.term).WithField("candidate", req.Candidate).Info("granted vote")
+		return &proto.VoteReply{Term: n.term, Granted: true}, nil
+	}
+	return &proto.VoteReply{Term: n.term}, nil
+}
+
+// AppendEntries adds the entries of the leader to the log if it matches the
+// log of the leader up to them, it is also the leader's heartbeat
+func (n *Node) AppendEntries(ctx context.Context, req *proto.AppendRequest) (*proto.AppendReply, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if req.Term < n.term {
+		return &proto.AppendReply{Term: n.term, LastIndex: n.lastIndex()}, nil
+	}
+	n.follow(req.Term, req.Leader)
+	n.resetDeadline()
+
+	if req.PrevLogIndex > n.lastIndex() {
+		return &proto.AppendReply{Term: n.term, LastIndex: n.lastIndex()}, nil
+	}
+	if req.PrevLogIndex > n.snapIndex && n.termAt(req.PrevLogIndex) != req.PrevLogTerm {
+		// skip back over the whole conflicting term at once
+		conflict := n.termAt(req.PrevLogIndex)
+		index := req.PrevLogIndex
+		for index > n.snapIndex+1 && n.termAt(index-1) == conflict {
+			index--
+		}
+		return &proto.AppendReply{Term: n.term, LastIndex: index - 1}, nil
+	}
+
+	for i, e := range req.Entries {
+		if e.Index <= n.snapIndex {
+			continue
+		}
+		if e.Index <= n.lastIndex() {
+			if n.termAt(e.Index) == e.Term {
+				continue
+			}
+			n.entries = n.entries[:e.Index-n.snapIndex-1]
+			if err := n.store.rewriteLog(n.entries); err != nil {
+				return nil, err
+			}
+		}
+		if err := n.store.appendLog(req.Entries[i:]); err != nil {
+			return nil, err
+		}
+		n.entries = append(n.entries, req.Entries[i:]...)
+		break
+	}
+
+	// entries beyond those of the leader may still be replaced
+	last := req.PrevLogIndex + uint64(len(req.Entries))
+	if req.LeaderCommit > n.commitIndex && last > n.commitIndex {
+		n.commitIndex = req.LeaderCommit
+		if last < n.commitIndex {
+			n.commitIndex = last
+		}
+		n.signalApply()
+	}
+	return &proto.AppendReply{Term: n.term, Success: true, LastIndex: n.lastIndex()}, nil
+}
+
+// InstallSnapshot replaces the state machine and log with the snapshot of
+// the leader when this node is too far behind to catch up from the log
+func (n *Node) InstallSnapshot(ctx context.Context, req *proto.SnapshotRequest) (*proto.SnapshotReply, error) {
+	n.applyLock.Lock()
+	defer n.applyLock.Unlock()
+
+	n.lock.Lock()
+	if req.Term < n.term {
+		defer n.lock.Unlock()
+		return &proto.SnapshotReply{Term: n.term}, nil
+	}
+	n.follow(req.Term, req.Leader)
+	n.resetDeadline()
+	if req.LastIndex <= n.lastApplied {
+		defer n.lock.Unlock()
+		return &proto.SnapshotReply{Term: n.term}, nil
+	}
+	n.lock.Unlock()
+
+	n.log.WithField("index", req.LastIndex).Info("installing snapshot")
+	if err := n.fsm.Restore(req.Data); err != nil {
+		return nil, err
+	}
+	snap := &snapshot{Index: req.LastIndex, Term: req.LastTerm, Data: req.Data}
+	if err := n.store.saveSnapshot(snap); err != nil {
+		return nil, err
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	// entries following the snapshot are kept if the log agrees with it
+	if req.LastIndex < n.lastIndex() && req.LastIndex > n.snapIndex && n.termAt(req.LastIndex) == req.LastTerm {
+		n.entries = append([]*proto.Entry(nil), n.entries[req.LastIndex-n.snapIndex:]...)
+	} else {
+		n.entries = nil
+	}
+	n.snapIndex, n.snapTerm, n.snapshot = snap.Index, snap.Term, snap.Data
+	if n.commitIndex < snap.Index {
+		n.commitIndex = snap.Index
+	}
+	n.lastApplied = snap.Index
+	n.caughtUp()
+	n.resetDeadline()
+	return &proto.SnapshotReply{Term: n.term}, n.store.rewriteLog(n.entries)
+}
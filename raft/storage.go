@@ -0,0 +1,180 @@
+package raft
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sdeoras/token/proto"
+)
+
+// hardState is what a node has to remember across restarts to never vote
+// twice in a term
+type hardState struct {
+	Term     uint64
+	VotedFor string
+}
+
+// snapshot is the state machine after all entries up to Index were applied
+type snapshot struct {
+	Index uint64
+	Term  uint64
+	Data  []byte
+}
+
+// storage keeps the hard state, log and latest snapshot of a node in a folder
+type storage struct {
+	dir string
+}
+
+func (st *storage) statePath() string    { return filepath.Join(st.dir, "state.json") }
+func (st *storage) snapshotPath() string { return filepath.Join(st.dir, "snapshot.json") }
+func (st *storage) logPath() string      { return filepath.Join(st.dir, "log.jsonl") }
+
+func (st *storage) loadState() (hardState, error) {
+	var state hardState
+	b, err := ioutil.ReadFile(st.statePath())
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(b, &state)
+	return state, err
+}
+
+func (st *storage) saveState(state hardState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeFile(st.statePath(), b)
+}
+
+// loadSnapshot returns nil and no error when no snapshot was taken yet
+func (st *storage) loadSnapshot() (*snapshot, error) {
+	b, err := ioutil.ReadFile(st.snapshotPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snap := new(snapshot)
+	if err := json.Unmarshal(b, snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (st *storage) saveSnapshot(snap *snapshot) error {
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return writeFile(st.snapshotPath(), b)
+}
+
+// loadLog returns the entries following index after, earlier ones are
+// covered by the snapshot
+func (st *storage) loadLog(after uint64) ([]*proto.Entry, error) {
+	f, err := os.Open(st.logPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*proto.Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), MaxMessageSize)
+	for scanner.Scan() {
+		e := new(proto.Entry)
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return nil, err
+		}
+		if e.Index > after {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// appendLog adds entries to the end of the log and syncs it to disk
+func (st *storage) appendLog(entries []*proto.Entry) error {
+	f, err := os.OpenFile(st.logPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := writeEntries(f, entries); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rewriteLog replaces the log with entries after it was truncated or compacted
+func (st *storage) rewriteLog(entries []*proto.Entry) error {
+	tmp, err := ioutil.TempFile(st.dir, filepath.Base(st.logPath())+".tmp")
+	if err != nil {
+		return err
+	}
+	if err := writeEntries(tmp, entries); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	return commit(tmp, st.logPath())
+}
+
+func writeEntries(f *os.File, entries []*proto.Entry) error {
+	w := bufio.NewWriter(f)
+	for _, e := range entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		w.Write(b)
+		w.WriteByte('\n')
+	}
+	return w.Flush()
+}
+
+// writeFile replaces the file at path with b so that a crash never leaves
+// it partially written
+func writeFile(path string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	return commit(tmp, path)
+}
+
+// commit syncs a temporary file and moves it over the file at path
+func commit(tmp *os.File, path string) error {
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
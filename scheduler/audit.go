@@ -0,0 +1,277 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// event types of the audit log
+const (
+	eventJobCreated      = "job_created"
+	eventJobImported     = "job_imported"
+	eventLeaseGranted    = "lease_granted"
+	eventHeartbeatMissed = "heartbeat_missed"
+	eventLeaseReassigned = "lease_reassigned"
+	eventDone            = "done"
+	eventFailed          = "failed"
+	eventReset           = "reset"
+	eventRescan          = "rescan"
+	eventShuffle         = "shuffle"
+)
+
+// Event is a state transition of the server. Tokens is the range of token
+// indexes a lease covers, Previous the worker that held a reassigned lease
+// and Count the number of tokens or jobs an event concerns.
+type Event struct {
+	Time     time.Time   `json:"time"`
+	Type     string      `json:"event"`
+	JobID    string      `json:"job_id,omitempty"`
+	Key      string      `json:"key,omitempty"`
+	Worker   string      `json:"worker,omitempty"`
+	Previous string      `json:"previous_worker,omitempty"`
+	Tokens   *TokenRange `json:"tokens,omitempty"`
+	Count    int         `json:"count,omitempty"`
+}
+
+// TokenRange is the half open range of token indexes from Start to End
+type TokenRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// AuditLog records state transitions in the order they happen. The server
+// closes it when it stops.
+type AuditLog interface {
+	Record(e *Event) error
+	Close() error
+}
+
+// auditFile appends events as lines of JSON to a file and rotates it once
+// it grows past maxSize, keeping up to backups older files as path.1,
+// path.2 and so on
+type auditFile struct {
+	path    string
+	maxSize int64
+	backups int
+
+	lock sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// AuditFile returns an AuditLog appending to the file at path. A file of
+// maxSize bytes or more is moved to path.1 before the next event, shifting
+// older ones up to path.<backups>, the oldest is dropped. A maxSize of 0
+// never rotates.
+func AuditFile(path string, maxSize int64, backups int) (AuditLog, error) {
+	a := &auditFile{path: path, maxSize: maxSize, backups: backups}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *auditFile) open() error {
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	a.f, a.size = f, info.Size()
+	return nil
+}
+
+func (a *auditFile) Record(e *Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.f == nil {
+		return errors.New("audit log is closed")
+	}
+	if a.maxSize > 0 && a.size >= a.maxSize {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+
+	// a line goes out in a single write so that readers never see half
+	n, err := a.f.Write(line)
+	a.size += int64(n)
+	return err
+}
+
+func (a *auditFile) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.f == nil {
+		return nil
+	}
+	err := a.f.Close()
+	a.f = nil
+	return err
+}
+
+// rotate moves the current file to the first backup and starts a new one
+func (a *auditFile) rotate() error {
+	if err := a.f.Close(); err != nil {
+		return err
+	}
+	if a.backups > 0 {
+		for i := a.backups - 1; i > 0; i-- {
+			err := os.Rename(a.backup(i), a.backup(i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(a.path, a.backup(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(a.path); err != nil {
+		return err
+	}
+	return a.open()
+}
+
+func (a *auditFile) backup(i int) string {
+	return a.path + "." + strconv.Itoa(i)
+}
+
+// auditQueue holds events until they are written to the audit log, so that
+// they are recorded in the order they happen without any lock of the server
+// held while writing
+type auditQueue struct {
+	lock   sync.Mutex
+	events []Event
+	closed bool
+	wake   chan struct{}
+	done   chan struct{}
+}
+
+func newAuditQueue() *auditQueue {
+	return &auditQueue{wake: make(chan struct{}, 1), done: make(chan struct{})}
+}
+
+// push queues e and reports whether the queue was still open
+func (q *auditQueue) push(e Event) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.closed {
+		return false
+	}
+	q.events = append(q.events, e)
+	q.signal()
+	return true
+}
+
+// signal wakes the writer, the caller must hold the lock
+func (q *auditQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// close stops taking events and waits for those queued to be written
+func (q *auditQueue) close() {
+	q.lock.Lock()
+	q.closed = true
+	q.signal()
+	q.lock.Unlock()
+	<-q.done
+}
+
+// writeEvents records queued events in the audit log until the queue is
+// closed
+func (s *Server) writeEvents(q *auditQueue) {
+	defer close(q.done)
+	for {
+		q.lock.Lock()
+		events, closed := q.events, q.closed
+		q.events = nil
+		q.lock.Unlock()
+
+		for i := range events {
+			if err := s.audit.Record(&events[i]); err != nil {
+				s.log.WithField("event", events[i].Type).
+					WithField("error", err).
+					Error("could not record event")
+			}
+		}
+		if closed {
+			return
+		}
+		<-q.wake
+	}
+}
+
+// event queues e for the audit log, if there is one, at the time of the
+// server clock unless it has a time of its own
+func (s *Server) event(e Event) {
+	if s.events == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = s.clock.Now()
+	}
+	if !s.events.push(e) {
+		s.log.WithField("event", e.Type).Error("could not record event, the audit log is closed")
+	}
+}
+
+// closeAudit writes the events still queued and closes the audit log
+func (s *Server) closeAudit() error {
+	if s.events != nil {
+		s.events.close()
+	}
+	if s.audit == nil {
+		return nil
+	}
+	return s.audit.Close()
+}
+
+// missed records that l missed its heartbeat, at the time it expired. The
+// caller must hold the lock of the job.
+func (s *Server) missed(id string, data *job, l *lease) {
+	l.missed = true
+	data.leasesExpired++
+	s.event(Event{Type: eventHeartbeatMissed, Time: l.heartbeat.Add(s.leaseTimeout),
+		JobID: id, Key: l.key, Worker: l.worker, Tokens: l.tokenRange()})
+}
+
+// expireLeases records the leases that missed their heartbeat by now across
+// all jobs, so that they are logged when they expire rather than when a
+// Get comes across them
+func (s *Server) expireLeases(now time.Time) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	cutoff := now.Add(-s.leaseTimeout)
+	for id, data := range s.jobs {
+		data.lock.Lock()
+		data.expiry.expired(cutoff, func(l *lease) bool {
+			if !l.missed {
+				s.missed(id, data, l)
+			}
+			return false
+		})
+		data.lock.Unlock()
+	}
+}
+
+// tokenRange returns the token range of l
+func (l *lease) tokenRange() *TokenRange {
+	return &TokenRange{Start: l.start, End: l.start + l.count}
+}
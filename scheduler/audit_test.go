@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+)
+
+// memAudit keeps recorded events in memory
+type memAudit struct {
+	lock   sync.Mutex
+	events []Event
+	closed bool
+}
+
+func (m *memAudit) Record(e *Event) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.events = append(m.events, *e)
+	return nil
+}
+
+func (m *memAudit) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.closed = true
+	return nil
+}
+
+func TestAuditFileRotation(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxSize int64
+		backups int
+		// files is how many files hold events after ten of them
+		files int
+	}{
+		{"never rotates", 0, 2, 1},
+		{"keeps backups", 1, 2, 3},
+		{"drops without backups", 1, 0, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			a, err := AuditFile(path, test.maxSize, test.backups)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 10; i++ {
+				if err := a.Record(&Event{Type: eventDone, Count: i}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := a.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.Record(&Event{Type: eventDone}); err == nil {
+				t.Error("expected a closed audit log to refuse events")
+			}
+
+			files := []string{path}
+			for i := 1; i <= test.backups+1; i++ {
+				files = append(files, (&auditFile{path: path}).backup(i))
+			}
+			n := 0
+			for _, file := range files {
+				if _, err := os.Stat(file); err == nil {
+					n++
+				}
+			}
+			if n != test.files {
+				t.Errorf("expected %d files, got %d", test.files, n)
+			}
+
+			// the current file ends with the last event
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			var last Event
+			for scanner := bufio.NewScanner(f); scanner.Scan(); {
+				if err := json.Unmarshal(scanner.Bytes(), &last); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if last.Count != 9 {
+				t.Errorf("expected the last event to be written last, got %v", last)
+			}
+		})
+	}
+}
+
+func TestHeartbeatMissedAtExpiry(t *testing.T) {
+	audit := &memAudit{}
+	s, clock := newTestServer(t, 10, WithAuditLog(audit))
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 5, Worker: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	granted := clock.Now()
+
+	// the sweep finds the lease long after it expired and records it once
+	clock.advance(5 * time.Minute)
+	s.sweep()
+	s.sweep()
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	audit.lock.Lock()
+	defer audit.lock.Unlock()
+	if !audit.closed {
+		t.Error("expected Stop to close the audit log")
+	}
+	var types []string
+	var missed []Event
+	for _, e := range audit.events {
+		types = append(types, e.Type)
+		if e.Type == eventHeartbeatMissed {
+			missed = append(missed, e)
+		}
+	}
+	if len(missed) != 1 {
+		t.Fatalf("expected a single missed heartbeat, got %v", types)
+	}
+	if want := granted.Add(time.Minute); !missed[0].Time.Equal(want) {
+		t.Errorf("expected the heartbeat to be missed at %v, got %v", want, missed[0].Time)
+	}
+	if missed[0].Worker != "a" {
+		t.Errorf("expected the lease of a to expire, got %v", missed[0])
+	}
+}
@@ -0,0 +1,207 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Role grants access to a set of RPCs
+type Role string
+
+const (
+	// RoleWorker may lease tokens, commit them and read job state
+	RoleWorker Role = "worker"
+	// RoleAdmin may additionally Reset, Rescan, Shuffle, Drain, Resume and
+	// Import
+	RoleAdmin Role = "admin"
+	// RolePeer is another replica of a highly available server, it may
+	// only make Raft calls
+	RolePeer Role = "peer"
+)
+
+// adminMethods wipe or reorder the bookkeeping of every job, stop it from
+// being handed out or write it wholesale
+var adminMethods = map[string]bool{
+	"Reset":   true,
+	"Rescan":  true,
+	"Shuffle": true,
+	"Drain":   true,
+	"Resume":  true,
+	"Import":  true,
+}
+
+// Identity is a client known to the server. It authenticates with a static
+// bearer Token or with a client certificate whose common name is CommonName.
+// Jobs, if not empty, lists the only job IDs the identity may work on, calls
+// that are not about a job are then refused.
+// Replicas are identified as peers by the token they dial each other with or
+// by the common name of their server certificate.
+type Identity struct {
+	Name       string
+	Role       Role
+	Token      string
+	CommonName string
+	Jobs       []string
+}
+
+// Policy authorizes RPCs by the role of the calling identity
+type Policy struct {
+	Identities []Identity
+}
+
+// LoadPolicy reads a policy from a JSON file
+func LoadPolicy(file string) (*Policy, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	p := new(Policy)
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, err
+	}
+	for _, id := range p.Identities {
+		if id.Role != RoleWorker && id.Role != RoleAdmin && id.Role != RolePeer {
+			return nil, errors.New("unknown role " + string(id.Role) + " for identity: " + id.Name)
+		}
+		if id.Token == "" && id.CommonName == "" {
+			return nil, errors.New("token or common name required for identity: " + id.Name)
+		}
+	}
+	return p, nil
+}
+
+// UnaryInterceptor enforces the policy on unary RPCs
+func (p *Policy) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if err := p.authorize(ctx, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor enforces the policy on streaming RPCs. The job of the
+// request is only known once it is received, so it is checked then.
+func (p *Policy) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		if err := p.authorize(ss.Context(), info.FullMethod, nil); err != nil {
+			return err
+		}
+		return handler(srv, &authorizedStream{ServerStream: ss, policy: p, method: info.FullMethod})
+	}
+}
+
+type authorizedStream struct {
+	grpc.ServerStream
+	policy *Policy
+	method string
+}
+
+func (s *authorizedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.policy.authorize(s.Context(), s.method, m)
+}
+
+// jobRequest is implemented by requests that refer to a job
+type jobRequest interface {
+	GetID() string
+}
+
+// authorize checks that the caller may call method with req
+func (p *Policy) authorize(ctx context.Context, method string, req interface{}) error {
+	var tokens []string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		tokens = md.Get("authorization")
+	}
+	var state *tls.ConnectionState
+	if pr, ok := peer.FromContext(ctx); ok {
+		if info, ok := pr.AuthInfo.(credentials.TLSInfo); ok {
+			state = &info.State
+		}
+	}
+	return p.check(p.identify(tokens, state), method, req)
+}
+
+// authorizeHTTP checks that the sender of r may call method with req
+func (p *Policy) authorizeHTTP(r *http.Request, method string, req interface{}) error {
+	return p.check(p.identify(r.Header["Authorization"], r.TLS), method, req)
+}
+
+// check decides whether id may call method with req. req is nil while it is
+// not known yet, such as when a stream opens, and is checked once it is.
+// Identities limited to jobs may only make calls about one of them.
+func (p *Policy) check(id *Identity, method string, req interface{}) error {
+	if id == nil {
+		return status.Error(codes.Unauthenticated, "unknown client")
+	}
+
+	if raft := strings.HasPrefix(method, "/proto.Raft/"); raft != (id.Role == RolePeer) {
+		return status.Errorf(codes.PermissionDenied, "%s may not call %s", id.Name, path.Base(method))
+	}
+	if adminMethods[path.Base(method)] && id.Role != RoleAdmin {
+		return status.Errorf(codes.PermissionDenied, "%s requires the admin role", path.Base(method))
+	}
+
+	if len(id.Jobs) == 0 || req == nil || strings.HasPrefix(method, "/grpc.health.v1.Health/") {
+		return nil
+	}
+	r, ok := req.(jobRequest)
+	if !ok {
+		return status.Errorf(codes.PermissionDenied, "%s may only call %s for its jobs", id.Name, path.Base(method))
+	}
+	for _, job := range id.Jobs {
+		if job == r.GetID() {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "%s may not access job %s", id.Name, r.GetID())
+}
+
+// identify returns the identity of the caller by bearer token or by the
+// common name of a verified client certificate
+func (p *Policy) identify(authorization []string, state *tls.ConnectionState) *Identity {
+	for _, value := range authorization {
+		token := strings.TrimPrefix(value, "Bearer ")
+		for i := range p.Identities {
+			want := p.Identities[i].Token
+			if want != "" && subtle.ConstantTimeCompare([]byte(want), []byte(token)) == 1 {
+				return &p.Identities[i]
+			}
+		}
+	}
+
+	if state == nil {
+		return nil
+	}
+	for _, chain := range state.VerifiedChains {
+		if len(chain) == 0 {
+			continue
+		}
+		cn := chain[0].Subject.CommonName
+		for i := range p.Identities {
+			if p.Identities[i].CommonName != "" && p.Identities[i].CommonName == cn {
+				return &p.Identities[i]
+			}
+		}
+	}
+	return nil
+}
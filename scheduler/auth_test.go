@@ -0,0 +1,87 @@
+package scheduler
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/sdeoras/token/proto"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheck(t *testing.T) {
+	worker := &Identity{Name: "worker", Role: RoleWorker, Token: "w"}
+	scoped := &Identity{Name: "scoped", Role: RoleWorker, Token: "s", Jobs: []string{"a"}}
+	admin := &Identity{Name: "admin", Role: RoleAdmin, Token: "x"}
+	scopedAdmin := &Identity{Name: "scoped admin", Role: RoleAdmin, Token: "y", Jobs: []string{"a"}}
+	peer := &Identity{Name: "peer", Role: RolePeer, Token: "p"}
+	p := &Policy{}
+
+	tests := []struct {
+		name   string
+		id     *Identity
+		method string
+		req    interface{}
+		code   codes.Code
+	}{
+		{"unknown client", nil, "/proto.Tokens/Get", &proto.JobID{ID: "a"}, codes.Unauthenticated},
+		{"worker leases", worker, "/proto.Tokens/Get", &proto.JobID{ID: "a"}, codes.OK},
+		{"worker commits", worker, "/proto.Tokens/Done", &proto.JobID{ID: "a"}, codes.OK},
+		{"worker exports", worker, "/proto.Tokens/Export", &proto.JobID{ID: "a"}, codes.OK},
+		{"worker resets", worker, "/proto.Tokens/Reset", &proto.Empty{}, codes.PermissionDenied},
+		{"worker drains", worker, "/proto.Tokens/Drain", &proto.Empty{}, codes.PermissionDenied},
+		{"worker imports", worker, "/proto.Tokens/Import", &proto.ImportRequest{ID: "a"}, codes.PermissionDenied},
+		{"worker votes", worker, "/proto.Raft/RequestVote", &proto.VoteRequest{}, codes.PermissionDenied},
+		{"admin resets", admin, "/proto.Tokens/Reset", &proto.Empty{}, codes.OK},
+		{"admin imports", admin, "/proto.Tokens/Import", &proto.ImportRequest{ID: "a"}, codes.OK},
+		{"admin votes", admin, "/proto.Raft/RequestVote", &proto.VoteRequest{}, codes.PermissionDenied},
+		{"peer votes", peer, "/proto.Raft/RequestVote", &proto.VoteRequest{}, codes.OK},
+		{"peer appends", peer, "/proto.Raft/AppendEntries", &proto.AppendRequest{}, codes.OK},
+		{"peer leases", peer, "/proto.Tokens/Get", &proto.JobID{ID: "a"}, codes.PermissionDenied},
+		{"scoped worker on its job", scoped, "/proto.Tokens/Get", &proto.JobID{ID: "a"}, codes.OK},
+		{"scoped worker on another job", scoped, "/proto.Tokens/Get", &proto.JobID{ID: "b"}, codes.PermissionDenied},
+		{"scoped worker shows tokens", scoped, "/proto.Tokens/Show", &proto.Empty{}, codes.PermissionDenied},
+		{"scoped admin resets", scopedAdmin, "/proto.Tokens/Reset", &proto.Empty{}, codes.PermissionDenied},
+		{"scoped admin drains", scopedAdmin, "/proto.Tokens/Drain", &proto.Empty{}, codes.PermissionDenied},
+		{"scoped admin imports its job", scopedAdmin, "/proto.Tokens/Import", &proto.ImportRequest{ID: "a"}, codes.OK},
+		{"scoped worker opens a stream", scoped, "/proto.Tokens/Export", nil, codes.OK},
+		{"scoped worker checks health", scoped, "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{}, codes.OK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := p.check(test.id, test.method, test.req)
+			if code := status.Code(err); code != test.code {
+				t.Errorf("expected %v, got %v", test.code, err)
+			}
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		ok     bool
+	}{
+		{"worker", `{"Identities": [{"Name": "a", "Role": "worker", "Token": "t"}]}`, true},
+		{"admin by common name", `{"Identities": [{"Name": "a", "Role": "admin", "CommonName": "ops"}]}`, true},
+		{"peer", `{"Identities": [{"Name": "a", "Role": "peer", "Token": "t"}]}`, true},
+		{"unknown role", `{"Identities": [{"Name": "a", "Role": "root", "Token": "t"}]}`, false},
+		{"no credentials", `{"Identities": [{"Name": "a", "Role": "worker"}]}`, false},
+		{"not json", `identities`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "policy.json")
+			if err := ioutil.WriteFile(file, []byte(test.policy), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadPolicy(file); (err == nil) != test.ok {
+				t.Errorf("expected ok %v, got %v", test.ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"time"
+)
+
+// rateSmoothing is the weight given to the latest throughput sample
+const rateSmoothing = 0.5
+
+// throughput is a smoothed estimate of tokens per second for a worker on a job
+type throughput struct {
+	rate float64
+}
+
+// observe folds the completion of count tokens in elapsed time into the estimate
+func (t *throughput) observe(count int, elapsed time.Duration) {
+	if count <= 0 || elapsed <= 0 {
+		return
+	}
+
+	sample := float64(count) / elapsed.Seconds()
+	if t.rate == 0 {
+		t.rate = sample
+		return
+	}
+	t.rate = rateSmoothing*sample + (1-rateSmoothing)*t.rate
+}
+
+// adaptiveBatchSize returns the number of tokens to grant to worker so that
+// its lease lasts about the target lease. requested is treated as an upper
+// bound and is returned as is when the policy is disabled, the caller does
+// not name its worker or worker has not yet completed a lease for this job.
+func (s *Server) adaptiveBatchSize(data *job, worker string, requested int) int {
+	if s.targetLease <= 0 || requested <= 0 || worker == "" {
+		return requested
+	}
+
+	t, present := data.workers[worker]
+	if !present || t.rate == 0 {
+		return requested
+	}
+
+	batchSize := int(t.rate * s.targetLease.Seconds())
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if batchSize > requested {
+		batchSize = requested
+	}
+	return batchSize
+}
+
+// recordDone measures the time it took to complete l against its grant time.
+// Leases of callers that do not name their worker are not measured since
+// they cannot be told apart.
+func (s *Server) recordDone(data *job, l *lease) {
+	if l.worker == "" {
+		return
+	}
+	t, present := data.workers[l.worker]
+	if !present {
+		t = new(throughput)
+		data.workers[l.worker] = t
+	}
+	t.observe(l.count, s.clock.Now().Sub(l.granted))
+}
+
+// budgetBatchSize trims count tokens starting at index start so that their
+// total size fits within budget. A token that is larger than budget on its
+// own is still granted, but alone, so that it cannot block a job forever.
+func (s *Server) budgetBatchSize(start, count int, budget int64) int {
+	if budget <= 0 {
+		return count
+	}
+
+	var total int64
+	n := 0
+	for n < count {
+		size := s.tokens.size(start + n)
+		if n > 0 && total+size > budget {
+			break
+		}
+		total += size
+		n++
+		if total >= budget {
+			break
+		}
+	}
+	return n
+}
+
+// rangeBytes returns the total size of count tokens starting at index start
+func (s *Server) rangeBytes(start, count int) int64 {
+	var total int64
+	for i := start; i < start+count; i++ {
+		total += s.tokens.size(i)
+	}
+	return total
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+// dashboardRefresh is how often the page reloads itself, in seconds
+const dashboardRefresh = 5
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"percent": func(n, total int) float64 {
+		if total <= 0 {
+			return 0
+		}
+		return 100 * float64(n) / float64(total)
+	},
+	"round": func(d time.Duration) time.Duration {
+		return d.Round(time.Second)
+	},
+	"sparkline": newSparkline,
+}).Parse(dashboardHTML))
+
+// sparkline is a small throughput chart
+type sparkline struct {
+	Width, Height int
+	Points        string
+	Last, Peak    float64
+}
+
+func newSparkline(rates []float64) *sparkline {
+	if len(rates) == 0 {
+		return nil
+	}
+
+	sl := &sparkline{Width: 2 * historyLength, Height: 32, Last: rates[len(rates)-1]}
+	for _, rate := range rates {
+		if rate > sl.Peak {
+			sl.Peak = rate
+		}
+	}
+
+	points := make([]string, len(rates))
+	for i, rate := range rates {
+		y := float64(sl.Height - 1)
+		if sl.Peak > 0 {
+			y -= rate / sl.Peak * float64(sl.Height-2)
+		}
+		points[i] = fmt.Sprintf("%d,%.1f", 2*i, y)
+	}
+	sl.Points = strings.Join(points, " ")
+	return sl
+}
+
+// Dashboard serves a read-only page with the dataset, job progress, active
+// leases and throughput. Callers are authorized by policy like the gateway.
+func (s *Server) Dashboard(policy *Policy) http.Handler {
+	g := &gateway{s: s, policy: policy}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := g.authorize(r, "Status", nil); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		statuses := s.Statuses()
+		jobs := make([]*JobStatus, 0, len(statuses))
+		for _, st := range statuses {
+			if g.authorize(r, "Status", &proto.JobID{ID: st.ID}) == nil {
+				jobs = append(jobs, st)
+			}
+		}
+
+		s.lock.RLock()
+		draining := s.draining
+		s.lock.RUnlock()
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := dashboardTemplate.Execute(w, map[string]interface{}{
+			"Now":      s.clock.Now(),
+			"Refresh":  dashboardRefresh,
+			"Draining": draining,
+			"Dataset":  s.Dataset(),
+			"Jobs":     jobs,
+		})
+		if err != nil {
+			s.log.WithField("error", err).Error("could not render dashboard")
+		}
+	})
+}
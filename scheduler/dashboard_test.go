@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+)
+
+func TestHistory(t *testing.T) {
+	start := time.Unix(0, 0)
+	tests := []struct {
+		name string
+		// adds are the offsets from start at which one token completes
+		adds []time.Duration
+		now  time.Duration
+		want int
+		last float64
+	}{
+		{"one bucket", []time.Duration{0, time.Second}, 5 * time.Second, 1, 0.2},
+		{"idle since", []time.Duration{0}, time.Minute, 7, 0},
+		{"window full", []time.Duration{0, historyBucket * historyLength}, historyBucket * historyLength, historyLength, 0.1},
+		{"long idle spell", []time.Duration{0, 1000 * time.Hour}, 1000 * time.Hour, historyLength, 0.1},
+		{"out of order", []time.Duration{time.Minute, 0}, time.Minute, 1, 0.1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var h history
+			for _, d := range test.adds {
+				h.add(start.Add(d), 1)
+			}
+			if len(h.counts) > historyLength {
+				t.Errorf("expected at most %d buckets, got %d", historyLength, len(h.counts))
+			}
+			rates := h.rates(start.Add(test.now))
+			if len(rates) != test.want {
+				t.Fatalf("expected %d rates, got %d", test.want, len(rates))
+			}
+			if last := rates[len(rates)-1]; last != test.last {
+				t.Errorf("expected the last rate to be %v, got %v", test.last, last)
+			}
+		})
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	policy := &Policy{Identities: []Identity{
+		{Name: "admin", Role: RoleAdmin, Token: "x"},
+		{Name: "scoped", Role: RoleWorker, Token: "s", Jobs: []string{"job-a"}},
+	}}
+	s, _ := newTestServer(t, 10)
+	for _, id := range []string{"job-a", "job-b"} {
+		if _, err := s.Get(context.Background(), &proto.JobID{ID: id, BatchSize: 2, Worker: "w-" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := s.Dashboard(policy)
+
+	tests := []struct {
+		name    string
+		token   string
+		code    int
+		want    []string
+		notWant []string
+	}{
+		{"no token", "", http.StatusUnauthorized, nil, nil},
+		{"admin", "x", http.StatusOK, []string{"job-a", "job-b", "w-job-a", "w-job-b"}, nil},
+		{"scoped", "s", http.StatusOK, []string{"job-a", "w-job-a"}, []string{"job-b"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := serve(h, "GET", "/", test.token, "")
+			if w.Code != test.code {
+				t.Fatalf("expected %d, got %d: %s", test.code, w.Code, w.Body)
+			}
+			for _, s := range test.want {
+				if !strings.Contains(w.Body.String(), s) {
+					t.Errorf("expected the page to show %s", s)
+				}
+			}
+			for _, s := range test.notWant {
+				if strings.Contains(w.Body.String(), s) {
+					t.Errorf("expected the page not to show %s", s)
+				}
+			}
+		})
+	}
+
+	s.lock.Lock()
+	s.draining = true
+	s.lock.Unlock()
+	if w := serve(h, "GET", "/", "x", ""); !strings.Contains(w.Body.String(), "server is draining") {
+		t.Error("expected the page to show that the server is draining")
+	}
+}
@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// drainPollInterval is how often Shutdown checks for outstanding leases
+const drainPollInterval = 100 * time.Millisecond
+
+// Drain stops Get from granting new leases across all jobs while HeartBeat
+// and Done keep working, so that the server can be restarted between batches.
+// Get answers with Draining set and no tokens, which workers take as the end
+// of the job.
+// The ack counts the leases still outstanding and its status is true once
+// none remain. Expired leases are not waited for, they are reassigned after
+// the server is resumed or restarted.
+func (s *Server) Drain(ctx context.Context, empty *proto.Empty) (*proto.Ack, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+
+	if !s.draining {
+		s.log.WithField("signal", "drain").Info("draining")
+		s.draining = true
+	}
+	n := s.outstanding()
+	return &proto.Ack{N: int32(n), Status: n == 0, Draining: true}, nil
+}
+
+// Resume takes the server out of drain
+func (s *Server) Resume(ctx context.Context, empty *proto.Empty) (*proto.Ack, error) {
+	if s.stopped() {
+		return nil, status.Error(codes.FailedPrecondition, "server is shutting down")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	return s.resume(), nil
+}
+
+// resume leaves drain, the caller must hold the lock
+func (s *Server) resume() *proto.Ack {
+	if s.draining {
+		s.log.WithField("signal", "resume").Info("resuming")
+		s.draining = false
+	}
+	return &proto.Ack{N: int32(s.outstanding()), Status: true}
+}
+
+// outstanding counts leases that have not expired across all jobs, the
+// caller must hold the lock
+func (s *Server) outstanding() int {
+	cutoff := s.clock.Now().Add(-s.leaseTimeout)
+	n := 0
+	for _, data := range s.jobs {
+		n += len(data.leases) - data.countExpired(cutoff)
+	}
+	return n
+}
+
+// Shutdown prepares the server to stop: Get grants no new leases, health
+// checks report NOT_SERVING, heartbeats tell workers that the server is
+// draining and result streams are ended. HeartBeat and Done keep working and
+// Shutdown waits until no leases are outstanding, so that leases in flight
+// can be committed before the grpc server stops. It returns the error of ctx
+// if leases are still outstanding when ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.lock.Lock()
+	s.draining = true
+	s.lock.Unlock()
+	s.stop()
+	return s.drained(ctx)
+}
+
+// drained polls until no leases are outstanding or ctx is done
+func (s *Server) drained(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		s.lock.Lock()
+		n := s.outstanding()
+		s.lock.Unlock()
+		if n == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			s.log.WithField("outstanding", n).Warn("leases still outstanding at shutdown")
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop reports NOT_SERVING and ends result streams
+func (s *Server) stop() {
+	s.shutdown.Do(func() {
+		s.log.Info("shutting down")
+		s.lock.Lock()
+		s.setServing(false)
+		close(s.stopping)
+		s.lock.Unlock()
+	})
+}
+
+// stopped tells whether Shutdown was called
+func (s *Server) stopped() bool {
+	select {
+	case <-s.stopping:
+		return true
+	default:
+		return false
+	}
+}
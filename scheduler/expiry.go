@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"container/heap"
+	"time"
+)
+
+// sweepsPerTimeout is how many times per lease timeout expired leases are
+// looked for, so that they are recorded soon after they expire
+const sweepsPerTimeout = 4
+
+// sweepInterval returns how often expired leases are looked for
+func (s *Server) sweepInterval() time.Duration {
+	if d := s.leaseTimeout / sweepsPerTimeout; d > 0 {
+		return d
+	}
+	return s.leaseTimeout
+}
+
+// leaseHeap orders the leases of a job by their last heartbeat so that
+// expired ones are found without looking at those that are alive
+type leaseHeap []*lease
+
+func (h leaseHeap) Len() int           { return len(h) }
+func (h leaseHeap) Less(i, j int) bool { return h[i].heartbeat.Before(h[j].heartbeat) }
+
+func (h leaseHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *leaseHeap) Push(x interface{}) {
+	l := x.(*lease)
+	l.index = len(*h)
+	*h = append(*h, l)
+}
+
+func (h *leaseHeap) Pop() interface{} {
+	old := *h
+	l := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	l.index = -1
+	return l
+}
+
+// expired calls visit with leases whose last heartbeat is before cutoff,
+// oldest ones first along each branch, until visit returns true. It only
+// looks at expired leases and their immediate children.
+func (h leaseHeap) expired(cutoff time.Time, visit func(*lease) bool) bool {
+	var walk func(i int) bool
+	walk = func(i int) bool {
+		if i >= len(h) || !h[i].heartbeat.Before(cutoff) {
+			return false
+		}
+		return visit(h[i]) || walk(2*i+1) || walk(2*i+2)
+	}
+	return walk(0)
+}
+
+// grant adds l to the leases of the job under key
+func (data *job) grant(key string, l *lease) {
+	l.key = key
+	data.leases[key] = l
+	heap.Push(&data.expiry, l)
+}
+
+// renew records a heartbeat for l
+func (data *job) renew(l *lease, now time.Time) {
+	l.heartbeat = now
+	l.missed = false
+	heap.Fix(&data.expiry, l.index)
+}
+
+// release drops the lease under key
+func (data *job) release(key string) {
+	if l, present := data.leases[key]; present {
+		heap.Remove(&data.expiry, l.index)
+		delete(data.leases, key)
+	}
+}
+
+// countExpired counts the leases that have not sent a heartbeat since cutoff
+func (data *job) countExpired(cutoff time.Time) int {
+	n := 0
+	data.expiry.expired(cutoff, func(*lease) bool {
+		n++
+		return false
+	})
+	return n
+}
@@ -0,0 +1,263 @@
+package scheduler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sort"
+	"time"
+
+	protobuf "github.com/golang/protobuf/proto"
+	"github.com/sdeoras/token/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Export streams the state of each token of a job along with the lease that
+// holds it or the worker that committed it. Like List it looks at a stride of
+// tokens at a time.
+func (s *Server) Export(req *proto.JobID, stream proto.Tokens_ExportServer) error {
+	return s.export(req, stream.Send)
+}
+
+// export sends the pages of an export to send
+func (s *Server) export(req *proto.JobID, send func(*proto.TokenStates) error) error {
+	s.log.WithField("signal", "export").
+		WithField("jobID", req.ID).
+		Info("exporting job")
+
+	generation := s.tokenGeneration()
+	for next := 0; next >= 0; {
+		states, end, err := s.exportStride(req.ID, generation, next)
+		if err != nil {
+			return err
+		}
+		next = end
+
+		for len(states) > 0 {
+			n := len(states)
+			if n > defaultPageSize {
+				n = defaultPageSize
+			}
+			if err := send(&proto.TokenStates{Tokens: states[:n]}); err != nil {
+				return err
+			}
+			states = states[n:]
+		}
+	}
+	return nil
+}
+
+// exportStride returns the states of the tokens of a stride from index start
+// on and where the next stride starts, or -1 after the last, see listStride
+func (s *Server) exportStride(id string, generation uint64, start int) ([]*proto.TokenState, int, error) {
+	data, unlock := s.lockJob(id, false)
+	defer unlock()
+	if err := s.ready(); err != nil {
+		return nil, 0, err
+	}
+	if s.generation != generation {
+		return nil, 0, status.Error(codes.Aborted, "tokens were rescanned or shuffled while exporting")
+	}
+	if data == nil {
+		return nil, 0, status.Error(codes.NotFound, "job id not present")
+	}
+
+	end := start + listStride
+	if end >= s.tokens.len() {
+		end = s.tokens.len()
+	}
+	next := end
+	if end == s.tokens.len() {
+		next = -1
+	}
+
+	// only the leases and commits of the stride are looked at, commits are
+	// kept sorted and walked along with the tokens
+	var leases []claim
+	for key, l := range data.leases {
+		if l.start < end && l.start+l.count > start {
+			leases = append(leases, claim{key: key, start: l.start, count: l.count, worker: l.worker, time: l.granted})
+		}
+	}
+	sortClaims(leases)
+	commits := data.commits[sort.Search(len(data.commits), func(i int) bool { return data.commits[i].end > start }):]
+
+	states := make([]*proto.TokenState, 0, end-start)
+	s.tokens.each(start, end, func(i int, name []byte) {
+		state := &proto.TokenState{Token: string(name), State: statePending}
+		for len(commits) > 0 && commits[0].end <= i {
+			commits = commits[1:]
+		}
+		switch {
+		case data.failed.contains(i):
+			state.State = stateFailed
+		case data.finished.contains(i):
+			state.State = stateCompleted
+		default:
+			if c := findClaim(leases, i); c != nil {
+				state.State = stateLeased
+				state.Worker = c.worker
+				state.Key = c.key
+				state.Time = unixNano(c.time)
+			}
+		}
+		if (state.State == stateFailed || state.State == stateCompleted) &&
+			len(commits) > 0 && commits[0].start <= i {
+			state.Worker = commits[0].worker
+			state.Time = unixNano(commits[0].time)
+		}
+		states = append(states, state)
+	})
+	return states, next, nil
+}
+
+// Import creates a job from exported token states, see importJob
+func (s *Server) Import(stream proto.Tokens_ImportServer) error {
+	req, err := receiveImport(stream)
+	if err != nil {
+		return err
+	}
+	ack, err := s.importJob(req)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(ack)
+}
+
+// receiveImport collects the pages of an import into a single request
+func receiveImport(stream proto.Tokens_ImportServer) (*proto.ImportRequest, error) {
+	var req *proto.ImportRequest
+	for {
+		page, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if req == nil {
+			req = page
+			continue
+		}
+		req.Tokens = append(req.Tokens, page.Tokens...)
+	}
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "nothing to import")
+	}
+	return req, nil
+}
+
+// importJob creates the job of req with the tokens req reports completed,
+// and failed unless they are to be retried, already committed by their
+// original holders. Get only leases the remaining tokens. Names that are not
+// in the token list are ignored, so that each partition picks its own. A job
+// that is present is only acknowledged if the same import created it.
+func (s *Server) importJob(req *proto.ImportRequest) (*proto.Ack, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	if req.ID == "" {
+		return nil, status.Error(codes.InvalidArgument, "importing requires a job id")
+	}
+	fingerprint, err := importFingerprint(req)
+	if err != nil {
+		return nil, err
+	}
+	if data, present := s.jobs[req.ID]; present {
+		// a retry of an import whose reply was lost finds the job it
+		// created, anything else must not overwrite the job
+		if data.imported != fingerprint {
+			return nil, status.Error(codes.AlreadyExists, "job id already present: "+req.ID)
+		}
+		return &proto.Ack{N: int32(s.tokens.len() - data.tokensCompleted), Status: true}, nil
+	}
+
+	skip := make(map[string]*proto.TokenState)
+	for _, t := range req.Tokens {
+		switch t.State {
+		case stateCompleted:
+			skip[t.Token] = t
+		case stateFailed:
+			if !req.RetryFailed {
+				skip[t.Token] = t
+			}
+		case statePending, stateLeased:
+		default:
+			return nil, status.Error(codes.InvalidArgument, "unknown token state: "+t.State)
+		}
+	}
+
+	data := s.initJob(req.ID)
+	data.imported = fingerprint
+	s.tokens.each(0, s.tokens.len(), func(i int, name []byte) {
+		t, present := skip[string(name)]
+		if !present {
+			return
+		}
+		data.finished.add(i, i+1)
+		if t.State == stateFailed {
+			data.failed.add(i, i+1)
+		}
+		data.commits.add(i, i+1, t.Worker, unixTime(t.Time))
+	})
+
+	data.tokensCompleted = data.finished.count()
+	remaining := s.tokens.len() - data.tokensCompleted
+	if remaining == 0 {
+		data.completed = true
+		data.endTime = s.clock.Now()
+	}
+	s.event(Event{Type: eventJobImported, JobID: req.ID, Count: data.tokensCompleted})
+	s.log.WithField("signal", "import").
+		WithField("jobID", req.ID).
+		WithField("tokens", len(req.Tokens)).
+		WithField("skipped", data.tokensCompleted).
+		WithField("remaining", remaining).
+		WithField("retryFailed", req.RetryFailed).
+		Info("imported job")
+	return &proto.Ack{N: int32(remaining), Status: true}, nil
+}
+
+// importFingerprint sums up the content of an import, which does not depend
+// on how it was split into pages
+func importFingerprint(req *proto.ImportRequest) (string, error) {
+	b, err := protobuf.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// sortClaims orders claims by their first token
+func sortClaims(claims []claim) {
+	sort.Slice(claims, func(i, j int) bool { return claims[i].start < claims[j].start })
+}
+
+// findClaim returns the claim of sorted claims that covers token i, if any
+func findClaim(claims []claim, i int) *claim {
+	j := sort.Search(len(claims), func(j int) bool { return claims[j].start+claims[j].count > i })
+	if j < len(claims) && claims[j].start <= i {
+		return &claims[j]
+	}
+	return nil
+}
+
+// unixNano and unixTime convert times to and from exports, where the zero
+// time is 0
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func unixTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		retryFailed bool
+		// remaining is how many tokens the imported job dispatches
+		remaining int32
+	}{
+		{"failed stay done", false, 10},
+		{"failed are retried", true, 11},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, clock := newTestServer(t, 40)
+			ctx := context.Background()
+
+			// a commits twice, the first time with a failed token, b commits
+			// and c holds its lease
+			committed := make(map[string]time.Time)
+			for _, worker := range []string{"a", "a", "b", "c"} {
+				data, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 10, Worker: worker})
+				if err != nil {
+					t.Fatal(err)
+				}
+				clock.advance(time.Second)
+				if worker == "c" {
+					continue
+				}
+				out := records(data)
+				if len(committed) == 0 {
+					out[3].Error = "bad file"
+				}
+				if _, err := s.Done(ctx, &proto.JobID{ID: "job", Key: data.Key, Worker: worker, Records: out}); err != nil {
+					t.Fatal(err)
+				}
+				for _, token := range data.Tokens {
+					committed[token] = clock.Now()
+				}
+			}
+			exported := exportAll(t, s, "job")
+
+			// each token keeps the time of the commit that completed it
+			for _, state := range exported {
+				if at, present := committed[state.Token]; present && state.Time != at.UnixNano() {
+					t.Errorf("%s: expected to be committed at %v, got %v", state.Token, at, time.Unix(0, state.Time))
+				}
+			}
+
+			imported, _ := newTestServer(t, 40)
+			req := &proto.ImportRequest{ID: "copy", RetryFailed: test.retryFailed, Tokens: exported}
+			ack, err := imported.importJob(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ack.Status || ack.N != test.remaining {
+				t.Errorf("expected %d tokens left, got %v", test.remaining, ack)
+			}
+
+			// completed tokens keep who committed them and when, the others
+			// are dispatched again
+			for i, state := range exportAll(t, imported, "copy") {
+				want := *exported[i]
+				if want.State == stateLeased || (want.State == stateFailed && test.retryFailed) {
+					want = proto.TokenState{Token: want.Token, State: statePending}
+				}
+				if state.String() != want.String() {
+					t.Errorf("token %d: expected %v, got %v", i, &want, state)
+				}
+			}
+
+			// a retry of the import is acknowledged, other content is not
+			// imported over the job
+			if ack, err := imported.importJob(req); err != nil || ack.N != test.remaining {
+				t.Errorf("expected a retry to be acknowledged, got %v, %v", ack, err)
+			}
+			other := &proto.ImportRequest{ID: "copy", RetryFailed: !test.retryFailed, Tokens: exported}
+			if _, err := imported.importJob(other); status.Code(err) != codes.AlreadyExists {
+				t.Errorf("expected a different import to be refused, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	protobuf "github.com/golang/protobuf/proto"
+	"github.com/sdeoras/token/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gateway maps HTTP/JSON requests onto the Tokens service
+type gateway struct {
+	s       *Server
+	service proto.TokensServer
+	policy  *Policy
+	// current fails if the state of s may lag behind, as on replicas that
+	// are not the leader
+	current func() error
+}
+
+// Gateway serves the Tokens service over HTTP/JSON. Callers are authorized
+// by policy the same way as gRPC callers, a nil policy allows anyone. Errors
+// are objects with the message under "error", replicas that are not the
+// leader name the one that is under "leader".
+//
+//	GET  /v1/jobs                  status of all jobs
+//	GET  /v1/jobs/{id}             status of a job
+//	POST /v1/jobs/{id}/lease       lease tokens, body like proto.JobID
+//	POST /v1/jobs/{id}/heartbeat   renew a lease, body {"key", "worker"}
+//	POST /v1/jobs/{id}/done        commit a lease, body {"key", "worker", "records"}
+//	GET  /v1/jobs/{id}/results     results of a job as JSON lines
+//	GET  /v1/dataset               source, number and size of tokens
+//	GET  /v1/tokens                list tokens, ?job=&state=&prefix=&glob=&count=true filter
+//	POST /v1/reset                 drop all job bookkeeping
+//	POST /v1/rescan                rescan the source for tokens
+//	POST /v1/shuffle               shuffle the token list
+//	POST /v1/drain                 stop granting leases, count outstanding ones
+//	POST /v1/resume                grant leases again
+func (s *Server) Gateway(policy *Policy) http.Handler {
+	return s.gateway(s, policy, func() error { return nil })
+}
+
+// gateway serves calls through service, which is s or a replica of s, and
+// reads state from s while current allows it
+func (s *Server) gateway(service proto.TokensServer, policy *Policy, current func() error) http.Handler {
+	g := &gateway{s: s, service: service, policy: policy, current: current}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/jobs", g.jobs)
+	mux.HandleFunc("GET /v1/jobs/{id}", g.job)
+	mux.HandleFunc("POST /v1/jobs/{id}/lease", g.lease)
+	mux.HandleFunc("POST /v1/jobs/{id}/heartbeat", g.heartbeat)
+	mux.HandleFunc("POST /v1/jobs/{id}/done", g.done)
+	mux.HandleFunc("GET /v1/jobs/{id}/results", g.resultLines)
+	mux.HandleFunc("GET /v1/dataset", g.dataset)
+	mux.HandleFunc("GET /v1/tokens", g.tokens)
+	mux.HandleFunc("POST /v1/reset", g.admin("Reset", service.Reset))
+	mux.HandleFunc("POST /v1/rescan", g.admin("Rescan", service.Rescan))
+	mux.HandleFunc("POST /v1/shuffle", g.admin("Shuffle", service.Shuffle))
+	mux.HandleFunc("POST /v1/drain", g.admin("Drain", service.Drain))
+	mux.HandleFunc("POST /v1/resume", g.admin("Resume", service.Resume))
+	return mux
+}
+
+// authorize checks r against the policy, if there is one
+func (g *gateway) authorize(r *http.Request, method string, req interface{}) error {
+	if g.policy == nil {
+		return nil
+	}
+	return g.policy.authorizeHTTP(r, method, req)
+}
+
+func (g *gateway) jobs(w http.ResponseWriter, r *http.Request) {
+	if err := g.authorize(r, "Status", nil); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := g.current(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// identities restricted to some jobs only see those
+	statuses := g.s.Statuses()
+	out := make([]*JobStatus, 0, len(statuses))
+	for _, st := range statuses {
+		if g.authorize(r, "Status", &proto.JobID{ID: st.ID}) == nil {
+			out = append(out, st)
+		}
+	}
+	writeJSON(w, out)
+}
+
+func (g *gateway) job(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := g.authorize(r, "Status", &proto.JobID{ID: id}); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := g.current(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	st, present := g.s.Status(id)
+	if !present {
+		writeError(w, status.Error(codes.NotFound, "job id not present"))
+		return
+	}
+	writeJSON(w, st)
+}
+
+func (g *gateway) lease(w http.ResponseWriter, r *http.Request) {
+	req := new(proto.JobID)
+	if err := readProto(r, req); err != nil {
+		writeError(w, err)
+		return
+	}
+	req.ID = r.PathValue("id")
+	if err := g.authorize(r, "Get", req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	data, err := g.service.Get(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeProto(w, data)
+}
+
+func (g *gateway) heartbeat(w http.ResponseWriter, r *http.Request) {
+	req := new(proto.JobID)
+	if err := readProto(r, req); err != nil {
+		writeError(w, err)
+		return
+	}
+	req.ID = r.PathValue("id")
+	if err := g.authorize(r, "HeartBeat", req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ack, err := g.service.HeartBeat(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeProto(w, ack)
+}
+
+// doneRequest carries records in the format of proto.MarshalRecord so that
+// JSON results need not be base64 encoded
+type doneRequest struct {
+	Key     string            `json:"key"`
+	Worker  string            `json:"worker"`
+	Records []json.RawMessage `json:"records"`
+}
+
+func (g *gateway) done(w http.ResponseWriter, r *http.Request) {
+	body := new(doneRequest)
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+
+	req := &proto.JobID{ID: r.PathValue("id"), Key: body.Key, Worker: body.Worker}
+	for _, line := range body.Records {
+		record, err := proto.UnmarshalRecord(line)
+		if err != nil {
+			writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+		req.Records = append(req.Records, record)
+	}
+	if err := g.authorize(r, "Done", req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ack, err := g.service.Done(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeProto(w, ack)
+}
+
+func (g *gateway) resultLines(w http.ResponseWriter, r *http.Request) {
+	req := &proto.JobID{ID: r.PathValue("id")}
+	if err := g.authorize(r, "Results", req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if g.s.results == nil {
+		writeError(w, errors.New("result collection is not configured"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	err := g.s.results.Stream(req.ID, func(record *proto.Record) error {
+		line, err := proto.MarshalRecord(record)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// the status line has gone out already, all that is left is the log
+		g.s.log.WithField("jobID", req.ID).
+			WithField("error", err).
+			Error("could not stream results")
+	}
+}
+
+func (g *gateway) dataset(w http.ResponseWriter, r *http.Request) {
+	if err := g.authorize(r, "Show", &proto.Empty{}); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := g.current(); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, g.s.Dataset())
+}
+
+// tokens lists tokens like List, filtered by the query parameters job,
+// state, prefix and glob. Names are written as they are listed so that long
+// lists need not be held in memory, count=true only counts them.
+func (g *gateway) tokens(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := &proto.ListRequest{
+		ID:        q.Get("job"),
+		State:     q.Get("state"),
+		Prefix:    q.Get("prefix"),
+		Glob:      q.Get("glob"),
+		CountOnly: q.Get("count") == "true",
+	}
+	var scope interface{}
+	if req.ID != "" {
+		scope = req
+	}
+	if err := g.authorize(r, "List", scope); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := g.current(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var count int64
+	started := false
+	err := g.s.list(req, func(page *proto.TokenPage) error {
+		count += page.Count
+		if req.CountOnly {
+			return nil
+		}
+		for _, name := range page.Tokens {
+			b, err := json.Marshal(name)
+			if err != nil {
+				return err
+			}
+			prefix := ","
+			if !started {
+				w.Header().Set("Content-Type", "application/json")
+				prefix = `{"tokens":[`
+				started = true
+			}
+			if _, err := io.WriteString(w, prefix+string(b)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	switch {
+	case started && err != nil:
+		// the status line has gone out already, all that is left is the log
+		g.s.log.WithField("error", err).Error("could not list tokens")
+	case started:
+		io.WriteString(w, "]}\n")
+	case err != nil:
+		writeError(w, err)
+	case req.CountOnly:
+		writeJSON(w, map[string]int64{"count": count})
+	default:
+		writeJSON(w, map[string][]string{"tokens": {}})
+	}
+}
+
+// admin serves an admin RPC that takes no arguments
+func (g *gateway) admin(method string,
+	f func(context.Context, *proto.Empty) (*proto.Ack, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &proto.Empty{}
+		if err := g.authorize(r, method, req); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		ack, err := f(r.Context(), req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeProto(w, ack)
+	}
+}
+
+// readProto decodes the JSON body of r into m, an empty body leaves m as is
+func readProto(r *http.Request, m protobuf.Message) error {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return nil
+	}
+
+	u := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	if err := u.Unmarshal(strings.NewReader(string(b)), m); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+func writeProto(w http.ResponseWriter, m protobuf.Message) {
+	w.Header().Set("Content-Type", "application/json")
+	marshaler := jsonpb.Marshaler{EmitDefaults: true, OrigName: true}
+	if err := marshaler.Marshal(w, m); err != nil {
+		writeError(w, err)
+		return
+	}
+	io.WriteString(w, "\n")
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		writeError(w, err)
+	}
+}
+
+// writeError replies with the HTTP status closest to the gRPC status of err
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		code = http.StatusBadRequest
+	case codes.Unauthenticated:
+		code = http.StatusUnauthorized
+	case codes.PermissionDenied:
+		code = http.StatusForbidden
+	case codes.NotFound:
+		code = http.StatusNotFound
+	case codes.FailedPrecondition:
+		code = http.StatusConflict
+	case codes.Unavailable:
+		code = http.StatusServiceUnavailable
+	}
+
+	body := map[string]string{"error": status.Convert(err).Message()}
+	if leader := proto.Leader(err); leader != "" {
+		body["leader"] = leader
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
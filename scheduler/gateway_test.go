@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sdeoras/token/proto"
+)
+
+// serve sends a request with body, if any, to h and returns the recorded
+// reply
+func serve(h http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// decode parses the JSON body of w into v
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+}
+
+func TestGatewayLease(t *testing.T) {
+	results := &memResults{}
+	s, _ := newTestServer(t, 10, WithResultStore(results))
+	h := s.Gateway(nil)
+
+	var data struct {
+		Key    string   `json:"key"`
+		Tokens []string `json:"tokens"`
+	}
+	decode(t, serve(h, "POST", "/v1/jobs/job/lease", "", `{"batch_size": 4, "worker": "a"}`), &data)
+	if data.Key == "" || len(data.Tokens) != 4 {
+		t.Fatalf("expected a lease of 4 tokens, got %+v", data)
+	}
+
+	var st JobStatus
+	decode(t, serve(h, "GET", "/v1/jobs/job", "", ""), &st)
+	if st.ID != "job" || st.Tokens != 10 || st.Dispatched != 4 || len(st.Leases) != 1 || st.Leases[0].Worker != "a" {
+		t.Errorf("expected job to show the lease of a, got %+v", st)
+	}
+
+	var ack struct {
+		Status bool `json:"status"`
+		Lost   bool `json:"lost"`
+	}
+	decode(t, serve(h, "POST", "/v1/jobs/job/heartbeat", "", `{"key": "`+data.Key+`", "worker": "a"}`), &ack)
+	if ack.Lost {
+		t.Error("expected the heartbeat to renew the lease")
+	}
+
+	records := make([]string, len(data.Tokens))
+	for i, token := range data.Tokens {
+		records[i] = `{"token": "` + token + `", "data": {"label": "cat"}}`
+	}
+	body := `{"key": "` + data.Key + `", "worker": "a", "records": [` + strings.Join(records, ",") + `]}`
+	decode(t, serve(h, "POST", "/v1/jobs/job/done", "", body), &ack)
+	if !ack.Status {
+		t.Error("expected the lease to be committed")
+	}
+
+	var statuses []JobStatus
+	decode(t, serve(h, "GET", "/v1/jobs", "", ""), &statuses)
+	if len(statuses) != 1 || statuses[0].TokensCompleted != 4 {
+		t.Errorf("expected a job with 4 tokens completed, got %+v", statuses)
+	}
+
+	w := serve(h, "GET", "/v1/jobs/job/results", "", "")
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); w.Code != http.StatusOK || len(lines) != 4 {
+		t.Errorf("expected 4 result lines, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestGatewayErrors(t *testing.T) {
+	policy := &Policy{Identities: []Identity{
+		{Name: "worker", Role: RoleWorker, Token: "w"},
+		{Name: "scoped", Role: RoleWorker, Token: "s", Jobs: []string{"job"}},
+		{Name: "admin", Role: RoleAdmin, Token: "x"},
+	}}
+	s, _ := newTestServer(t, 10)
+	if _, err := s.Get(context.Background(), &proto.JobID{ID: "job", BatchSize: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		// leader is named by replicas that are not the leader
+		leader string
+		code   int
+	}{
+		{"status", "GET", "/v1/jobs/job", "w", "", "", http.StatusOK},
+		{"unknown job", "GET", "/v1/jobs/other", "w", "", "", http.StatusNotFound},
+		{"malformed lease", "POST", "/v1/jobs/job/lease", "w", `{"batch_size": "four"}`, "", http.StatusBadRequest},
+		{"malformed commit", "POST", "/v1/jobs/job/done", "w", `{"records": [1]}`, "", http.StatusBadRequest},
+		{"no token", "GET", "/v1/jobs/job", "", "", "", http.StatusUnauthorized},
+		{"unknown token", "GET", "/v1/jobs/job", "y", "", "", http.StatusUnauthorized},
+		{"worker resets", "POST", "/v1/reset", "w", "", "", http.StatusForbidden},
+		{"scoped worker on another job", "GET", "/v1/jobs/other", "s", "", "", http.StatusForbidden},
+		{"follower", "GET", "/v1/jobs/job", "w", "", "10.0.0.2:7001", http.StatusServiceUnavailable},
+		{"admin resets", "POST", "/v1/reset", "x", "", "", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			current := func() error { return nil }
+			if test.leader != "" {
+				current = func() error { return proto.NotLeader(test.leader) }
+			}
+			w := serve(s.gateway(s, policy, current), test.method, test.path, test.token, test.body)
+			if w.Code != test.code {
+				t.Fatalf("expected %d, got %d: %s", test.code, w.Code, w.Body)
+			}
+			if w.Code == http.StatusOK {
+				return
+			}
+
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("%v: %s", err, w.Body)
+			}
+			if body["error"] == "" || body["leader"] != test.leader {
+				t.Errorf("expected an error naming leader %q, got %v", test.leader, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"github.com/sdeoras/token/proto"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Health implements the standard grpc.health.v1 service. It reports
+// NOT_SERVING while tokens are scanned or state is replayed and SERVING
+// afterwards, for the server as a whole and for proto.ServiceName.
+func (s *Server) Health() healthpb.HealthServer {
+	return s.health
+}
+
+// setServing updates the reported health
+func (s *Server) setServing(serving bool) {
+	st := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		st = healthpb.HealthCheckResponse_SERVING
+	}
+	s.health.SetServingStatus("", st)
+	s.health.SetServingStatus(proto.ServiceName, st)
+}
+
+// ready fails calls that arrive before Start has finished so that workers
+// retry instead of seeing an empty token list, the caller must hold the lock
+func (s *Server) ready() error {
+	if !s.serving {
+		return status.Error(codes.Unavailable, "server is starting")
+	}
+	return nil
+}
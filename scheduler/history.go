@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"time"
+)
+
+const (
+	// historyBucket is the width of a throughput sample
+	historyBucket = time.Second * 10
+	// historyLength is the number of samples kept, an hour worth
+	historyLength = 360
+)
+
+// history counts completed tokens in fixed width time buckets
+type history struct {
+	start  time.Time
+	counts []int
+}
+
+// add records n tokens completed at now
+func (h *history) add(now time.Time, n int) {
+	bucket := now.Truncate(historyBucket)
+	if len(h.counts) == 0 {
+		h.start = bucket
+		h.counts = []int{n}
+		return
+	}
+
+	ind := int(bucket.Sub(h.start) / historyBucket)
+	if ind < 0 {
+		return
+	}
+
+	// slide the window before filling the gap so a long idle spell does
+	// not allocate more than historyLength buckets
+	if drop := ind - historyLength + 1; drop > 0 {
+		if drop < len(h.counts) {
+			h.counts = append([]int(nil), h.counts[drop:]...)
+		} else {
+			h.counts = h.counts[:0]
+		}
+		h.start = h.start.Add(historyBucket * time.Duration(drop))
+		ind -= drop
+	}
+	for len(h.counts) <= ind {
+		h.counts = append(h.counts, 0)
+	}
+	h.counts[ind] += n
+}
+
+// rates returns tokens per second for each bucket up to now, oldest first
+func (h *history) rates(now time.Time) []float64 {
+	if len(h.counts) == 0 {
+		return nil
+	}
+
+	n := int(now.Truncate(historyBucket).Sub(h.start)/historyBucket) + 1
+	if n < len(h.counts) {
+		n = len(h.counts)
+	}
+	skip := 0
+	if n > historyLength {
+		skip = n - historyLength
+	}
+
+	out := make([]float64, 0, n-skip)
+	for i := skip; i < n; i++ {
+		count := 0
+		if i < len(h.counts) {
+			count = h.counts[i]
+		}
+		out = append(out, float64(count)/historyBucket.Seconds())
+	}
+	return out
+}
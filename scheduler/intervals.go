@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Interval is the half open range of token indexes from Start to End
+type Interval struct {
+	Start int
+	End   int
+}
+
+// intervals is a set of token indexes kept as sorted ranges that neither
+// overlap nor touch, so that a job that commits leases in order needs a
+// single range however many tokens it has
+type intervals []Interval
+
+// add puts the indexes start to end into the set
+func (s *intervals) add(start, end int) {
+	if start >= end {
+		return
+	}
+	set := *s
+
+	// first range that ends at or after start and so may merge with it
+	i := sort.Search(len(set), func(i int) bool { return set[i].End >= start })
+	j := i
+	for j < len(set) && set[j].Start <= end {
+		if set[j].Start < start {
+			start = set[j].Start
+		}
+		if set[j].End > end {
+			end = set[j].End
+		}
+		j++
+	}
+
+	merged := Interval{Start: start, End: end}
+	if i == j {
+		set = append(set, Interval{})
+		copy(set[i+1:], set[i:])
+		set[i] = merged
+	} else {
+		set[i] = merged
+		set = append(set[:i+1], set[j:]...)
+	}
+	*s = set
+}
+
+// contains tells whether index i is in the set
+func (s intervals) contains(i int) bool {
+	j := sort.Search(len(s), func(j int) bool { return s[j].End > i })
+	return j < len(s) && s[j].Start <= i
+}
+
+// count returns the number of indexes in the set
+func (s intervals) count() int {
+	n := 0
+	for _, r := range s {
+		n += r.End - r.Start
+	}
+	return n
+}
+
+// gap returns the run of indexes from i on that are not in the set. It
+// starts at i or at the end of the range that holds i and ends at the start
+// of the next range, or at math.MaxInt if there is none.
+func (s intervals) gap(i int) (int, int) {
+	j := sort.Search(len(s), func(j int) bool { return s[j].End > i })
+	if j < len(s) && s[j].Start <= i {
+		i = s[j].End
+		j++
+	}
+	if j < len(s) {
+		return i, s[j].Start
+	}
+	return i, math.MaxInt
+}
+
+// commit is the run of token indexes from start to end that worker
+// committed, the last of them at time
+type commit struct {
+	start  int
+	end    int
+	worker string
+	time   time.Time
+}
+
+// commits attributes committed token indexes to the worker that committed
+// them as sorted ranges that do not overlap. Neighbouring commits of the
+// same worker at the same time share a range, so that a lease needs a
+// single range however many tokens it had and every index keeps the time it
+// was committed at.
+type commits []commit
+
+// add attributes the indexes start to end to worker at t, replacing what
+// they were attributed to before
+func (s *commits) add(start, end int, worker string, t time.Time) {
+	if start >= end {
+		return
+	}
+	set := *s
+
+	// ranges i to j overlap the new one and are cut back to what is left
+	// of them on either side, the ranges next to them may merge with it
+	i := sort.Search(len(set), func(i int) bool { return set[i].end > start })
+	j := sort.Search(len(set), func(j int) bool { return set[j].start >= end })
+	lo, hi := i, j
+	parts := make([]commit, 0, 5)
+	if lo > 0 {
+		lo--
+		parts = append(parts, set[lo])
+	}
+	if i < j && set[i].start < start {
+		left := set[i]
+		left.end = start
+		parts = append(parts, left)
+	}
+	parts = append(parts, commit{start: start, end: end, worker: worker, time: t})
+	if i < j && set[j-1].end > end {
+		right := set[j-1]
+		right.start = end
+		parts = append(parts, right)
+	}
+	if hi < len(set) {
+		parts = append(parts, set[hi])
+		hi++
+	}
+
+	// merge neighbours of the same worker and time
+	merged := parts[:1]
+	for _, c := range parts[1:] {
+		last := &merged[len(merged)-1]
+		if last.end == c.start && last.worker == c.worker && last.time.Equal(c.time) {
+			last.end = c.end
+			continue
+		}
+		merged = append(merged, c)
+	}
+	*s = append(set[:lo], append(merged, set[hi:]...)...)
+}
@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestIntervals(t *testing.T) {
+	tests := []struct {
+		name  string
+		adds  [][2]int
+		want  intervals
+		count int
+	}{
+		{"empty range", [][2]int{{3, 3}}, nil, 0},
+		{"single", [][2]int{{0, 4}}, intervals{{0, 4}}, 4},
+		{"in order", [][2]int{{0, 2}, {2, 4}, {4, 6}}, intervals{{0, 6}}, 6},
+		{"out of order", [][2]int{{4, 6}, {0, 2}, {2, 4}}, intervals{{0, 6}}, 6},
+		{"apart", [][2]int{{0, 2}, {5, 7}}, intervals{{0, 2}, {5, 7}}, 4},
+		{"overlapping", [][2]int{{0, 4}, {2, 6}}, intervals{{0, 6}}, 6},
+		{"contained", [][2]int{{0, 10}, {3, 5}}, intervals{{0, 10}}, 10},
+		{"bridging", [][2]int{{0, 2}, {4, 6}, {8, 10}, {1, 9}}, intervals{{0, 10}}, 10},
+		{"before all", [][2]int{{5, 7}, {0, 2}}, intervals{{0, 2}, {5, 7}}, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var set intervals
+			for _, add := range test.adds {
+				set.add(add[0], add[1])
+			}
+			if fmt.Sprint(set) != fmt.Sprint(test.want) {
+				t.Errorf("expected %v, got %v", test.want, set)
+			}
+			if n := set.count(); n != test.count {
+				t.Errorf("expected count %d, got %d", test.count, n)
+			}
+		})
+	}
+}
+
+func TestIntervalsContainsAndGap(t *testing.T) {
+	set := intervals{{2, 4}, {6, 8}}
+	tests := []struct {
+		i        int
+		contains bool
+		gap      [2]int
+	}{
+		{0, false, [2]int{0, 2}},
+		{2, true, [2]int{4, 6}},
+		{3, true, [2]int{4, 6}},
+		{4, false, [2]int{4, 6}},
+		{7, true, [2]int{8, math.MaxInt}},
+		{9, false, [2]int{9, math.MaxInt}},
+	}
+	for _, test := range tests {
+		if contains := set.contains(test.i); contains != test.contains {
+			t.Errorf("contains(%d): expected %v, got %v", test.i, test.contains, contains)
+		}
+		if start, end := set.gap(test.i); start != test.gap[0] || end != test.gap[1] {
+			t.Errorf("gap(%d): expected %v, got [%d %d]", test.i, test.gap, start, end)
+		}
+	}
+}
+
+func TestCommits(t *testing.T) {
+	at := func(s int) time.Time { return time.Unix(int64(s), 0) }
+	type add struct {
+		start, end int
+		worker     string
+		time       int
+	}
+	tests := []struct {
+		name string
+		adds []add
+		// want lists start, end, worker and time of each range
+		want string
+	}{
+		{"single", []add{{0, 2, "a", 1}}, "0-2 a 1"},
+		{"same worker in order", []add{{0, 2, "a", 1}, {2, 4, "a", 2}, {4, 6, "a", 3}}, "0-2 a 1, 2-4 a 2, 4-6 a 3"},
+		{"same worker at once", []add{{0, 2, "a", 1}, {2, 4, "a", 1}, {4, 6, "a", 1}}, "0-6 a 1"},
+		{"same worker apart", []add{{0, 2, "a", 1}, {4, 6, "a", 2}}, "0-2 a 1, 4-6 a 2"},
+		{"workers taking turns", []add{{0, 2, "a", 1}, {2, 4, "b", 2}, {4, 6, "a", 3}}, "0-2 a 1, 2-4 b 2, 4-6 a 3"},
+		{"gap filled by same worker", []add{{0, 2, "a", 1}, {4, 6, "a", 2}, {2, 4, "a", 3}}, "0-2 a 1, 2-4 a 3, 4-6 a 2"},
+		{"gap filled at once", []add{{0, 2, "a", 1}, {4, 6, "a", 1}, {2, 4, "a", 1}}, "0-6 a 1"},
+		{"recommitted in the middle", []add{{0, 6, "a", 1}, {2, 4, "b", 2}}, "0-2 a 1, 2-4 b 2, 4-6 a 1"},
+		{"recommitted across", []add{{0, 4, "a", 1}, {4, 8, "b", 2}, {2, 6, "c", 3}}, "0-2 a 1, 2-6 c 3, 6-8 b 2"},
+		{"recommitted by the same worker", []add{{0, 4, "a", 1}, {4, 8, "b", 2}, {2, 6, "a", 3}}, "0-2 a 1, 2-6 a 3, 6-8 b 2"},
+		{"recommitted by the same worker at once", []add{{0, 4, "a", 1}, {4, 8, "b", 2}, {2, 6, "a", 1}}, "0-6 a 1, 6-8 b 2"},
+		{"replaced entirely", []add{{2, 4, "a", 1}, {4, 6, "b", 2}, {0, 8, "c", 3}}, "0-8 c 3"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var set commits
+			for _, a := range test.adds {
+				set.add(a.start, a.end, a.worker, at(a.time))
+			}
+			got := ""
+			for i, c := range set {
+				if i > 0 {
+					got += ", "
+				}
+				got += fmt.Sprintf("%d-%d %s %d", c.start, c.end, c.worker, c.time.Unix())
+			}
+			if got != test.want {
+				t.Errorf("expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestRecentKeys(t *testing.T) {
+	at := func(s int) time.Time { return time.Unix(int64(s), 0) }
+	var r recentKeys
+	r.add("a", "w1", at(1))
+	r.add("b", "w2", at(2))
+	r.add("c", "w3", at(3))
+	r.expire(at(3))
+
+	for key, want := range map[string]string{"a": "", "b": "", "c": "w3"} {
+		got, present := r.value(key)
+		if present != (want != "") || got != want {
+			t.Errorf("value(%s): expected %q, got %q", key, want, got)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package scheduler
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sdeoras/token/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultPageSize and maxPageSize bound the tokens of a listed page so
+	// that pages of long names still fit into a grpc message
+	defaultPageSize = 1000
+	maxPageSize     = 10000
+
+	// listStride is how many tokens are looked at per lock hold, so that
+	// listing a large token list does not hold up Get
+	listStride = 1 << 16
+)
+
+// token states a listing may filter by
+const (
+	statePending   = "pending"
+	stateLeased    = "leased"
+	stateCompleted = "completed"
+	stateFailed    = "failed"
+)
+
+// tokenFilter decides which tokens a listing includes, it refers to the
+// bookkeeping of the job and is only used under the lock of the job
+type tokenFilter struct {
+	state    string
+	prefix   string
+	glob     string
+	leased   intervals
+	finished intervals
+	failed   intervals
+}
+
+// stateOf tells the state of token i in the job of the filter
+func (f *tokenFilter) stateOf(i int) string {
+	switch {
+	case f.failed.contains(i):
+		return stateFailed
+	case f.finished.contains(i):
+		return stateCompleted
+	case f.leased.contains(i):
+		return stateLeased
+	}
+	return statePending
+}
+
+func (f *tokenFilter) names() bool {
+	return f.prefix != "" || f.glob != ""
+}
+
+func (f *tokenFilter) matchState(i int) bool {
+	return f.state == "" || f.stateOf(i) == f.state
+}
+
+func (f *tokenFilter) matchName(name string) bool {
+	if !strings.HasPrefix(name, f.prefix) {
+		return false
+	}
+	if f.glob == "" {
+		return true
+	}
+	ok, _ := path.Match(f.glob, name)
+	return ok
+}
+
+// List streams the tokens that pass the filter of req in pages. Tokens are
+// looked at a stride at a time, so a listing that runs while leases are
+// granted or committed sees each token in the state it had at that moment.
+func (s *Server) List(req *proto.ListRequest, stream proto.Tokens_ListServer) error {
+	return s.list(req, stream.Send)
+}
+
+// list sends the pages of a listing to send
+func (s *Server) list(req *proto.ListRequest, send func(*proto.TokenPage) error) error {
+	switch req.State {
+	case "", statePending, stateLeased, stateCompleted, stateFailed:
+	default:
+		return status.Error(codes.InvalidArgument, "unknown token state: "+req.State)
+	}
+	if req.State != "" && req.ID == "" {
+		return status.Error(codes.InvalidArgument, "listing by state requires a job id")
+	}
+	if _, err := path.Match(req.Glob, ""); err != nil {
+		return status.Error(codes.InvalidArgument, "bad glob: "+req.Glob)
+	}
+	pageSize := int(req.PageSize)
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	s.log.WithField("signal", "list").
+		WithField("jobID", req.ID).
+		WithField("state", req.State).
+		WithField("prefix", req.Prefix).
+		WithField("glob", req.Glob).
+		WithField("countOnly", req.CountOnly).
+		Info("listing tokens")
+
+	generation := s.tokenGeneration()
+	var count int64
+	page := new(proto.TokenPage)
+	for next := 0; next >= 0; {
+		names, n, end, err := s.listStride(req, generation, next)
+		if err != nil {
+			return err
+		}
+		next = end
+		count += int64(n)
+
+		for _, name := range names {
+			page.Tokens = append(page.Tokens, name)
+			if len(page.Tokens) < pageSize {
+				continue
+			}
+			page.Count = int64(len(page.Tokens))
+			if err := send(page); err != nil {
+				return err
+			}
+			page = new(proto.TokenPage)
+		}
+	}
+
+	if req.CountOnly {
+		return send(&proto.TokenPage{Count: count})
+	}
+	if len(page.Tokens) > 0 {
+		page.Count = int64(len(page.Tokens))
+		return send(page)
+	}
+	return nil
+}
+
+// listStride looks at the tokens of a stride from index start on and returns
+// the names of those that match, unless only counting, how many matched and
+// where the next stride starts, or -1 after the last. generation is that of
+// the token list the listing started on, a rescan or shuffle in between
+// aborts it.
+func (s *Server) listStride(req *proto.ListRequest, generation uint64,
+	start int) ([]string, int, int, error) {
+	var data *job
+	var unlock func()
+	if req.ID != "" {
+		data, unlock = s.lockJob(req.ID, false)
+	} else {
+		s.lock.RLock()
+		unlock = s.lock.RUnlock
+	}
+	defer unlock()
+	if err := s.ready(); err != nil {
+		return nil, 0, 0, err
+	}
+
+	if s.generation != generation {
+		return nil, 0, 0, status.Error(codes.Aborted, "tokens were rescanned or shuffled while listing")
+	}
+
+	f := s.filter(req, data)
+	end := start + listStride
+	if end >= s.tokens.len() {
+		end = s.tokens.len()
+	}
+	next := end
+	if end == s.tokens.len() {
+		next = -1
+	}
+
+	// names are only decoded if they are filtered or sent
+	n := 0
+	if req.CountOnly && !f.names() {
+		for i := start; i < end; i++ {
+			if f.matchState(i) {
+				n++
+			}
+		}
+		return nil, n, next, nil
+	}
+
+	var names []string
+	s.tokens.each(start, end, func(i int, name []byte) {
+		if !f.matchState(i) || !f.matchName(string(name)) {
+			return
+		}
+		n++
+		if !req.CountOnly {
+			names = append(names, string(name))
+		}
+	})
+	return names, n, next, nil
+}
+
+// filter returns the filter of req for the job data, which is nil if the
+// job has not started, the caller must hold the lock of the job
+func (s *Server) filter(req *proto.ListRequest, data *job) *tokenFilter {
+	f := &tokenFilter{state: req.State, prefix: req.Prefix, glob: req.Glob}
+	if data == nil || f.state == "" {
+		return f
+	}
+
+	leased := make(intervals, 0, len(data.leases))
+	for _, l := range data.leases {
+		leased = append(leased, Interval{Start: l.start, End: l.start + l.count})
+	}
+	sort.Slice(leased, func(i, j int) bool { return leased[i].Start < leased[j].Start })
+	f.leased = leased
+	f.finished = data.finished
+	f.failed = data.failed
+	return f
+}
@@ -0,0 +1,129 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sdeoras/token/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestList(t *testing.T) {
+	s, _ := newTestServer(t, 30)
+	ctx := context.Background()
+
+	// a commits ten tokens with one failed, b holds the next five
+	data, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 10, Worker: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := records(data)
+	out[3].Error = "bad file"
+	if _, err := s.Done(ctx, &proto.JobID{ID: "job", Key: data.Key, Worker: "a", Records: out}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 5, Worker: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  *proto.ListRequest
+		// pages lists the count of each page sent
+		pages []int64
+		first string
+		code  codes.Code
+	}{
+		{"all in pages", &proto.ListRequest{PageSize: 7}, []int64{7, 7, 7, 7, 2}, "img000000.jpg", codes.OK},
+		{"completed", &proto.ListRequest{ID: "job", State: stateCompleted}, []int64{9}, "img000000.jpg", codes.OK},
+		{"failed", &proto.ListRequest{ID: "job", State: stateFailed}, []int64{1}, "img000003.jpg", codes.OK},
+		{"leased", &proto.ListRequest{ID: "job", State: stateLeased}, []int64{5}, "img000010.jpg", codes.OK},
+		{"pending", &proto.ListRequest{ID: "job", State: statePending}, []int64{15}, "img000015.jpg", codes.OK},
+		{"by prefix", &proto.ListRequest{Prefix: "img00001"}, []int64{10}, "img000010.jpg", codes.OK},
+		{"by glob", &proto.ListRequest{Glob: "img00002[5-9].jpg"}, []int64{5}, "img000025.jpg", codes.OK},
+		{"count only", &proto.ListRequest{ID: "job", State: statePending, CountOnly: true}, []int64{15}, "", codes.OK},
+		{"unknown state", &proto.ListRequest{ID: "job", State: "lost"}, nil, "", codes.InvalidArgument},
+		{"state without job", &proto.ListRequest{State: statePending}, nil, "", codes.InvalidArgument},
+		{"bad glob", &proto.ListRequest{Glob: "["}, nil, "", codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var pages []int64
+			var first string
+			err := s.list(test.req, func(page *proto.TokenPage) error {
+				if len(pages) == 0 && len(page.Tokens) > 0 {
+					first = page.Tokens[0]
+				}
+				if !test.req.CountOnly && int64(len(page.Tokens)) != page.Count {
+					t.Errorf("page of %d tokens counts %d", len(page.Tokens), page.Count)
+				}
+				pages = append(pages, page.Count)
+				return nil
+			})
+			if code := status.Code(err); code != test.code {
+				t.Fatalf("expected %v, got %v", test.code, err)
+			}
+			if fmt.Sprint(pages) != fmt.Sprint(test.pages) {
+				t.Errorf("expected pages %v, got %v", test.pages, pages)
+			}
+			if first != test.first {
+				t.Errorf("expected the listing to start at %q, got %q", test.first, first)
+			}
+		})
+	}
+}
+
+func TestListingsSpanningTokenChanges(t *testing.T) {
+	s, _ := newTestServer(t, listStride+10)
+	ctx := context.Background()
+	if _, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the token list changes after the first page is sent
+	tests := []struct {
+		name   string
+		change func()
+	}{
+		{"shuffle", func() {
+			if _, err := s.Shuffle(ctx, &proto.Empty{}); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"rescan", func() {
+			if err := s.rescan(); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pages := 0
+			err := s.list(&proto.ListRequest{}, func(*proto.TokenPage) error {
+				if pages++; pages == 1 {
+					test.change()
+				}
+				return nil
+			})
+			if status.Code(err) != codes.Aborted {
+				t.Errorf("expected the listing to be aborted, got %v", err)
+			}
+
+			if _, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 1}); err != nil {
+				t.Fatal(err)
+			}
+			pages = 0
+			err = s.export(&proto.JobID{ID: "job"}, func(*proto.TokenStates) error {
+				if pages++; pages == 1 {
+					test.change()
+				}
+				return nil
+			})
+			if status.Code(err) != codes.Aborted {
+				t.Errorf("expected the export to be aborted, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// timedMutex is a read/write mutex that reports how long callers wait for it
+type timedMutex struct {
+	sync.RWMutex
+	wait prometheus.Histogram
+}
+
+func (m *timedMutex) Lock() {
+	if m.wait == nil {
+		m.RWMutex.Lock()
+		return
+	}
+	t := time.Now()
+	m.RWMutex.Lock()
+	m.wait.Observe(time.Since(t).Seconds())
+}
+
+func (m *timedMutex) RLock() {
+	if m.wait == nil {
+		m.RWMutex.RLock()
+		return
+	}
+	t := time.Now()
+	m.RWMutex.RLock()
+	m.wait.Observe(time.Since(t).Seconds())
+}
+
+// metrics are registered on a registry of their own so that several servers
+// can run in one process
+type metrics struct {
+	registry    *prometheus.Registry
+	rpcDuration *prometheus.HistogramVec
+	lockWait    prometheus.Histogram
+}
+
+var (
+	tokensDesc = prometheus.NewDesc("token_tokens",
+		"Number of tokens the server hands out.", nil, nil)
+	dispatchedDesc = prometheus.NewDesc("token_tokens_dispatched",
+		"Number of tokens of a job that have been leased at least once.", []string{"job"}, nil)
+	tokensCompletedDesc = prometheus.NewDesc("token_tokens_completed_total",
+		"Number of tokens of a job committed with Done.", []string{"job"}, nil)
+	grantedDesc = prometheus.NewDesc("token_leases_granted_total",
+		"Number of fresh leases granted for a job.", []string{"job"}, nil)
+	reassignedDesc = prometheus.NewDesc("token_leases_reassigned_total",
+		"Number of expired leases handed to another worker.", []string{"job"}, nil)
+	completedDesc = prometheus.NewDesc("token_leases_completed_total",
+		"Number of leases committed with Done.", []string{"job"}, nil)
+	outstandingDesc = prometheus.NewDesc("token_leases_outstanding",
+		"Number of leases of a job that have not been committed.", []string{"job"}, nil)
+	expiredDesc = prometheus.NewDesc("token_leases_expired",
+		"Number of outstanding leases that missed their heartbeat and may be reassigned.", []string{"job"}, nil)
+	expiredTotalDesc = prometheus.NewDesc("token_leases_expired_total",
+		"Number of leases of a job that missed their heartbeat.", []string{"job"}, nil)
+)
+
+func newMetrics(s *Server) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "token_rpc_duration_seconds",
+			Help: "Latency of gRPC calls by method.",
+		}, []string{"method", "code"}),
+		lockWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "token_lock_wait_seconds",
+			Help:    "Time spent waiting for the server lock.",
+			Buckets: prometheus.ExponentialBuckets(1e-6, 4, 12),
+		}),
+	}
+	m.registry.MustRegister(
+		m.rpcDuration,
+		m.lockWait,
+		&jobCollector{s: s},
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// jobCollector reads job bookkeeping at scrape time, so jobs that are reset
+// or cleaned up disappear from the metrics along with their state
+type jobCollector struct {
+	s *Server
+}
+
+func (c *jobCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{tokensDesc, dispatchedDesc, tokensCompletedDesc,
+		grantedDesc, reassignedDesc, completedDesc, outstandingDesc, expiredDesc, expiredTotalDesc} {
+		ch <- desc
+	}
+}
+
+func (c *jobCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.s
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	now := s.clock.Now()
+	ch <- prometheus.MustNewConstMetric(tokensDesc, prometheus.GaugeValue, float64(s.tokens.len()))
+	for id, data := range s.jobs {
+		data.lock.Lock()
+		c.collectJob(ch, id, data, now)
+		data.lock.Unlock()
+	}
+}
+
+// collectJob sends the metrics of a job, the caller must hold its lock
+func (c *jobCollector) collectJob(ch chan<- prometheus.Metric, id string, data *job, now time.Time) {
+	s := c.s
+	expired := data.countExpired(now.Add(-s.leaseTimeout))
+
+	ch <- prometheus.MustNewConstMetric(dispatchedDesc, prometheus.GaugeValue, float64(s.dispatched(data)), id)
+	ch <- prometheus.MustNewConstMetric(tokensCompletedDesc, prometheus.CounterValue, float64(data.tokensCompleted), id)
+	ch <- prometheus.MustNewConstMetric(grantedDesc, prometheus.CounterValue, float64(data.leasesGranted), id)
+	ch <- prometheus.MustNewConstMetric(reassignedDesc, prometheus.CounterValue, float64(data.leasesReassigned), id)
+	ch <- prometheus.MustNewConstMetric(completedDesc, prometheus.CounterValue, float64(data.leasesCompleted), id)
+	ch <- prometheus.MustNewConstMetric(outstandingDesc, prometheus.GaugeValue, float64(len(data.leases)), id)
+	ch <- prometheus.MustNewConstMetric(expiredDesc, prometheus.GaugeValue, float64(expired), id)
+	ch <- prometheus.MustNewConstMetric(expiredTotalDesc, prometheus.CounterValue, float64(data.leasesExpired), id)
+}
+
+// MetricsHandler serves the server metrics in Prometheus format
+func (s *Server) MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(s.metrics.registry, promhttp.HandlerOpts{})
+}
+
+// UnaryInterceptor records the latency of unary calls
+func (s *Server) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		t := time.Now()
+		resp, err := handler(ctx, req)
+		s.observe(info.FullMethod, t, err)
+		return resp, err
+	}
+}
+
+// StreamInterceptor records the duration of streaming calls
+func (s *Server) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		t := time.Now()
+		err := handler(srv, ss)
+		s.observe(info.FullMethod, t, err)
+		return err
+	}
+}
+
+func (s *Server) observe(method string, t time.Time, err error) {
+	s.metrics.rpcDuration.WithLabelValues(path.Base(method), statusCode(err)).
+		Observe(time.Since(t).Seconds())
+}
+
+// statusCode names the gRPC status of err, OK if it is nil
+func statusCode(err error) string {
+	return status.Code(err).String()
+}
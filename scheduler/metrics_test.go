@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+)
+
+// gather returns the value of the metric name of job, or -1 if there is none
+func gather(t *testing.T, s *Server, name, job string) float64 {
+	families, err := s.metrics.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.Metric {
+			for _, label := range m.Label {
+				if label.GetName() == "job" && label.GetValue() == job {
+					if m.Counter != nil {
+						return m.Counter.GetValue()
+					}
+					return m.Gauge.GetValue()
+				}
+			}
+		}
+	}
+	return -1
+}
+
+func TestExpiredLeaseMetrics(t *testing.T) {
+	s, clock := newTestServer(t, 10)
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 5, Worker: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(2 * time.Minute)
+	if n := gather(t, s, "token_leases_expired", "job"); n != 1 {
+		t.Errorf("expected 1 expired lease outstanding, got %v", n)
+	}
+
+	// the lease is counted once, also after it is handed to another worker
+	for _, worker := range []string{"b", "c"} {
+		if _, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 5, Worker: worker}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := gather(t, s, "token_leases_expired", "job"); n != 0 {
+		t.Errorf("expected no expired lease outstanding, got %v", n)
+	}
+	if n := gather(t, s, "token_leases_expired_total", "job"); n != 1 {
+		t.Errorf("expected 1 expired lease in total, got %v", n)
+	}
+}
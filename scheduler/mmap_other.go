@@ -0,0 +1,17 @@
+//go:build !unix
+
+package scheduler
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the first size bytes of f where files cannot be mapped
+func mapFile(f *os.File, size int) ([]byte, func() error, error) {
+	b := make([]byte, size)
+	if _, err := f.ReadAt(b, 0); err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	return b, func() error { return nil }, nil
+}
@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Option configures a Server
+type Option func(*Server)
+
+// Clock tells the time, it can be replaced to control time in tests
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithSource sets where the server gets its tokens from
+func WithSource(source Source) Option {
+	return func(s *Server) {
+		s.source = source
+	}
+}
+
+// WithLeaseTimeout sets how long a lease may go without a heartbeat before
+// its tokens are handed to another worker. It defaults to a minute.
+func WithLeaseTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.leaseTimeout = timeout
+	}
+}
+
+// WithClock replaces the system clock
+func WithClock(clock Clock) Option {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// WithLogger replaces the standard logrus logger
+func WithLogger(log logrus.FieldLogger) Option {
+	return func(s *Server) {
+		s.log = log
+	}
+}
+
+// WithStateStore persists job bookkeeping in store so that it survives
+// a restart. By default state is only kept in memory.
+func WithStateStore(store StateStore) Option {
+	return func(s *Server) {
+		s.store = store
+	}
+}
+
+// WithSnapshotInterval sets how often state is saved to the state store
+// in addition to when the server stops. It defaults to a minute.
+func WithSnapshotInterval(interval time.Duration) Option {
+	return func(s *Server) {
+		s.snapshotInterval = interval
+	}
+}
+
+// WithResultStore commits results sent along with Done() to store
+func WithResultStore(store ResultStore) Option {
+	return func(s *Server) {
+		s.results = store
+	}
+}
+
+// WithAuditLog records every lease, commit and admin call in log, which is
+// closed by Stop. Replicas each record the calls they apply, with the time
+// of the leader. Missed heartbeats are recorded when the lease expires, by
+// the leader alone.
+func WithAuditLog(log AuditLog) Option {
+	return func(s *Server) {
+		s.audit = log
+	}
+}
+
+// WithTargetLease adapts granted batch sizes so that leases take about
+// target to complete. Requested batch sizes then become an upper bound.
+func WithTargetLease(target time.Duration) Option {
+	return func(s *Server) {
+		s.targetLease = target
+	}
+}
+
+// WithShards statically splits each job into n shards. Workers that run out
+// of work may take over shards whose owner has not asked for work in
+// stealAfter, zero disables that.
+func WithShards(n int, stealAfter time.Duration) Option {
+	return func(s *Server) {
+		s.shards = n
+		s.stealAfter = stealAfter
+	}
+}
+
+// WithManifest keeps the token list in a file at path that is mapped into
+// memory rather than on the heap. The file is rewritten on every scan.
+func WithManifest(path string) Option {
+	return func(s *Server) {
+		s.manifest = path
+	}
+}
+
+// WithJobTTL sets how long after it started a job's bookkeeping is dropped.
+// It defaults to a day.
+func WithJobTTL(ttl time.Duration) Option {
+	return func(s *Server) {
+		s.jobTTL = ttl
+	}
+}
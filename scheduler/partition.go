@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/sdeoras/token/proto"
+)
+
+// ringReplicas is how often each partition appears on the hash ring, more
+// points spread tokens more evenly
+const ringReplicas = 128
+
+// ring assigns token names to partitions by consistent hashing, so that
+// adding or removing a partition only moves the tokens of its neighbours
+type ring struct {
+	points []uint64
+	owners map[uint64]int
+}
+
+func newRing(partitions []string) *ring {
+	r := &ring{owners: make(map[uint64]int)}
+	for i, name := range partitions {
+		for j := 0; j < ringReplicas; j++ {
+			point := hash(name + "#" + strconv.Itoa(j))
+			if _, present := r.owners[point]; present {
+				continue
+			}
+			r.owners[point] = i
+			r.points = append(r.points, point)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// owner returns the index of the partition that owns name
+func (r *ring) owner(name string) int {
+	h := hash(name)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// hash spreads similar names, such as host names that differ in a digit,
+// evenly across the ring
+func hash(s string) uint64 {
+	sum := sha1.Sum([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// partitionSource lists the tokens of source that a partition owns
+type partitionSource struct {
+	source     Source
+	ring       *ring
+	partitions []string
+	index      int
+}
+
+// WithPartition makes the server one of several that split the tokens of
+// their source by consistent hashing of token names. partitions names all
+// of them in the order clients list them and index is the position of this
+// server. Lease keys carry the index so that clients can route Done and
+// HeartBeat, see proto.PartitionKey.
+func WithPartition(partitions []string, index int) Option {
+	return func(s *Server) {
+		s.partition = &partitionSource{
+			ring:       newRing(partitions),
+			partitions: partitions,
+			index:      index,
+		}
+	}
+}
+
+func (p *partitionSource) Scan() ([]Token, error) {
+	tokens, err := p.source.Scan()
+	if err != nil {
+		return nil, err
+	}
+
+	out := tokens[:0]
+	for _, token := range tokens {
+		if p.ring.owner(token.Name) == p.index {
+			out = append(out, token)
+		}
+	}
+	return out, nil
+}
+
+func (p *partitionSource) String() string {
+	name := "source"
+	if s, ok := p.source.(fmt.Stringer); ok {
+		name = s.String()
+	}
+	return fmt.Sprintf("%s, partition %d of %d", name, p.index+1, len(p.partitions))
+}
+
+// partitionKey prefixes key with the index of the partition, if the server is one
+func (s *Server) partitionKey(key string) string {
+	if s.partition == nil {
+		return key
+	}
+	return proto.PartitionKey(s.partition.index, key)
+}
@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sdeoras/token/proto"
+)
+
+func TestRingStability(t *testing.T) {
+	names := make([]string, 3000)
+	for i := range names {
+		names[i] = fmt.Sprintf("img%06d.jpg", i)
+	}
+
+	tests := []struct {
+		name   string
+		before []string
+		after  []string
+	}{
+		{
+			name:   "same partitions",
+			before: []string{"host-1:7001", "host-2:7001", "host-3:7001"},
+			after:  []string{"host-1:7001", "host-2:7001", "host-3:7001"},
+		},
+		{
+			name:   "partition added",
+			before: []string{"host-1:7001", "host-2:7001", "host-3:7001"},
+			after:  []string{"host-1:7001", "host-2:7001", "host-3:7001", "host-4:7001"},
+		},
+		{
+			name:   "partition removed",
+			before: []string{"host-1:7001", "host-2:7001", "host-3:7001", "host-4:7001"},
+			after:  []string{"host-1:7001", "host-2:7001", "host-3:7001"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before, after := newRing(test.before), newRing(test.after)
+			owned := make([]int, len(test.after))
+			for _, name := range names {
+				was, is := before.owner(name), after.owner(name)
+				owned[is]++
+				// only tokens of the partition that went away or to
+				// the one that came in may move
+				if was != is && was < len(test.after) && is < len(test.before) {
+					t.Fatalf("%s moved from partition %d to %d", name, was, is)
+				}
+			}
+			for i, n := range owned {
+				if n < len(names)/len(test.after)/2 {
+					t.Errorf("partition %d owns only %d of %d tokens", i, n, len(names))
+				}
+			}
+		})
+	}
+}
+
+func TestPartitionedServers(t *testing.T) {
+	partitions := []string{"host-1:7001", "host-2:7001", "host-3:7001"}
+	seen := make(map[string]int)
+	for i := range partitions {
+		s, _ := newTestServer(t, 300, WithPartition(partitions, i))
+		data, err := s.Get(context.Background(), &proto.JobID{ID: "job", BatchSize: 300})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if partition, ok := proto.KeyPartition(data.Key); !ok || partition != i {
+			t.Errorf("expected key %s to name partition %d", data.Key, i)
+		}
+		for _, token := range data.Tokens {
+			if j, present := seen[token]; present {
+				t.Errorf("%s is owned by partitions %d and %d", token, j, i)
+			}
+			seen[token] = i
+		}
+	}
+	if len(seen) != 300 {
+		t.Errorf("expected the partitions to own 300 tokens together, got %d", len(seen))
+	}
+}
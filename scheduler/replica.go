@@ -0,0 +1,579 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	protobuf "github.com/golang/protobuf/proto"
+	"github.com/sdeoras/token/proto"
+	"github.com/sdeoras/token/raft"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Replica serves a Server as one of several replicas that agree on job state
+// through Raft. Calls that change state are proposed by the leader and
+// applied by every replica in the same order, with the time and randomness
+// of the leader. Replicas that are not the leader answer such calls with
+// codes.Unavailable and the address of the leader, see proto.NotLeader.
+// Show, List, Export and Progress are only answered by the leader as well,
+// since other replicas may lag behind it. Results are read from the result
+// store of the replica asked. An import is replicated as a single command
+// with all of its tokens.
+//
+// Replicas scan their own source when they start, so they have to see the
+// same tokens. Tokens of later rescans are scanned by the leader.
+//
+// Heartbeats renew leases on the leader alone instead of going through the
+// log. The leader names the leases it found expired in each Get so that all
+// replicas reassign the same one, and a new leader renews every lease when
+// it is elected.
+//
+// A lease granted by a leader that fails before answering is held by no
+// worker and is reassigned once it expires, as with a lost reply.
+type Replica struct {
+	s      *Server
+	config raft.Config
+	clock  *replayClock
+	// expired is the list of the Get being applied, it is only used by
+	// the goroutine applying commands
+	expired []string
+
+	lock sync.Mutex
+	node *raft.Node
+}
+
+// maxExpired is how many expired leases the leader names to a Get, so that
+// Gets proposed at the same time do not all compete for the same one
+const maxExpired = 8
+
+// command is a call that changes state, as proposed by the leader. Expired
+// lists the leases the leader found expired, in the order Get may reassign
+// them.
+type command struct {
+	Method  string
+	Request []byte
+	Time    time.Time
+	Seed    int64
+	Tokens  []Token  `json:",omitempty"`
+	Expired []string `json:",omitempty"`
+}
+
+// applied is the reply to a command
+type applied struct {
+	reply protobuf.Message
+	err   error
+}
+
+// replicaState is a snapshot of a replica
+type replicaState struct {
+	State    *State
+	Draining bool
+}
+
+// replayClock tells the time of the command being applied, if any
+type replayClock struct {
+	base Clock
+
+	lock sync.Mutex
+	now  time.Time
+}
+
+func (c *replayClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.now.IsZero() {
+		return c.now
+	}
+	return c.base.Now()
+}
+
+func (c *replayClock) set(now time.Time) {
+	c.lock.Lock()
+	c.now = now
+	c.lock.Unlock()
+}
+
+// NewReplica makes s a replica of the servers in config.Peers. Job state is
+// replicated instead of persisted, s must not have a state store.
+func NewReplica(s *Server, config raft.Config) (*Replica, error) {
+	if s.store != nil {
+		return nil, errors.New("replicas cannot have a state store")
+	}
+	if config.Log == nil {
+		config.Log = s.log
+	}
+
+	r := &Replica{s: s, config: config, clock: &replayClock{base: s.clock}}
+	r.config.Elected = r.elected
+	r.config.Replayed = r.replayed
+	s.replaying = true
+	s.clock = r.clock
+	s.clean = r.cleanup
+	s.sweep = r.sweep
+	s.reassignable = r.reassign
+	return r, nil
+}
+
+// Start scans tokens, replays the replicated log on top and joins the other
+// replicas
+func (r *Replica) Start() error {
+	if err := r.s.Start(); err != nil {
+		return err
+	}
+
+	node, err := raft.New(r.config, r)
+	if err != nil {
+		return err
+	}
+	node.Start()
+
+	r.lock.Lock()
+	r.node = node
+	r.lock.Unlock()
+	return nil
+}
+
+// Shutdown hands leadership to another replica, ends result streams and
+// stops granting leases. Leases in flight are committed on the new leader so
+// it does not wait for them. Other calls keep working until Stop.
+func (r *Replica) Shutdown(ctx context.Context) error {
+	r.s.stop()
+	if node, err := r.raft(); err == nil {
+		node.StepDown()
+	}
+	return nil
+}
+
+// Stop leaves the other replicas and ends background bookkeeping
+func (r *Replica) Stop() error {
+	if node, err := r.raft(); err == nil {
+		node.Stop()
+	}
+	return r.s.Stop()
+}
+
+// Health reports NOT_SERVING until the replica has started and replayed the
+// replicated log
+func (r *Replica) Health() healthpb.HealthServer {
+	return r.s.Health()
+}
+
+// Gateway serves the Tokens service over HTTP/JSON like Server.Gateway
+func (r *Replica) Gateway(policy *Policy) http.Handler {
+	return r.s.gateway(r, policy, r.leading)
+}
+
+// raft returns the raft node once the replica has started
+func (r *Replica) raft() (*raft.Node, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.node == nil {
+		return nil, status.Error(codes.Unavailable, "server is starting")
+	}
+	return r.node, nil
+}
+
+// propose replicates a call and returns the reply of applying it
+func (r *Replica) propose(ctx context.Context, method string, req protobuf.Message,
+	tokens []Token) (protobuf.Message, error) {
+	return r.proposeCommand(ctx, &command{Method: method, Tokens: tokens}, req)
+}
+
+// proposeCommand replicates cmd with req as its request
+func (r *Replica) proposeCommand(ctx context.Context, cmd *command, req protobuf.Message) (protobuf.Message, error) {
+	node, err := r.raft()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd.Request, err = protobuf.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Time = r.clock.base.Now()
+	cmd.Seed = rand.Int63()
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := node.Propose(ctx, data)
+	switch {
+	case err == raft.ErrNotLeader:
+		return nil, r.notLeader(node)
+	case err == raft.ErrStopped:
+		return nil, status.Error(codes.Unavailable, "server is stopping")
+	case err != nil:
+		return nil, err
+	}
+	out := result.(*applied)
+	return out.reply, out.err
+}
+
+// notLeader points clients at the leader
+func (r *Replica) notLeader(node *raft.Node) error {
+	return proto.NotLeader(node.Leader())
+}
+
+// leading fails like a proposal unless this replica is the leader and has
+// applied every entry committed by earlier leaders
+func (r *Replica) leading() error {
+	node, err := r.raft()
+	if err != nil {
+		return err
+	}
+	if !node.IsLeader() {
+		return r.notLeader(node)
+	}
+	if !node.Ready() {
+		return status.Error(codes.Unavailable, "leader is replaying the log")
+	}
+	return nil
+}
+
+// replayed reports SERVING once the replica has caught up with the log
+func (r *Replica) replayed() {
+	r.s.lock.Lock()
+	defer r.s.lock.Unlock()
+	r.s.replaying = false
+	if r.s.serving && !r.s.stopped() {
+		r.s.setServing(true)
+	}
+}
+
+// elected renews every lease when this replica takes over as leader, since
+// heartbeats only reached the previous leader. Workers get a full lease
+// timeout to find the new leader before their leases are reassigned. It is
+// called once the entries of earlier leaders have been applied, so that
+// leases they granted are renewed as well.
+func (r *Replica) elected() {
+	r.s.lock.Lock()
+	defer r.s.lock.Unlock()
+
+	now := r.clock.base.Now()
+	for _, data := range r.s.jobs {
+		for _, l := range data.leases {
+			data.renew(l, now)
+		}
+	}
+}
+
+// expiredKeys lists the leases of the job of req that the worker of req may
+// take over because they missed their heartbeats, which only the leader
+// receives
+func (r *Replica) expiredKeys(req *proto.JobID) ([]string, error) {
+	data, unlock := r.s.lockJob(req.ID, false)
+	defer unlock()
+	if data == nil {
+		return nil, nil
+	}
+
+	leases, err := r.s.expiredLeases(data, req, r.clock.base.Now(), maxExpired)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(leases))
+	for i, l := range leases {
+		keys[i] = l.key
+	}
+	return keys, nil
+}
+
+// reassign returns the first lease the leader found expired when it
+// proposed the Get being applied. A lease that was granted again since is
+// skipped, grants are replicated while heartbeats are not.
+func (r *Replica) reassign(data *job, req *proto.JobID, now time.Time) (*lease, error) {
+	cutoff := now.Add(-r.s.leaseTimeout)
+	for _, key := range r.expired {
+		l, present := data.leases[key]
+		if !present || !l.granted.Before(cutoff) {
+			continue
+		}
+		if !r.s.validLease(l) {
+			return nil, errors.New("bookkeeping fault for JobId: " + req.ID)
+		}
+		if r.s.mayReassign(data, req, l.start) {
+			return l, nil
+		}
+	}
+	return nil, nil
+}
+
+// sweep records expired leases, if this one is the leader, since only the
+// leader receives heartbeats
+func (r *Replica) sweep() {
+	if r.leading() == nil {
+		r.s.expireLeases(r.clock.base.Now())
+	}
+}
+
+// cleanup drops old jobs on all replicas, if this one is the leader
+func (r *Replica) cleanup() {
+	if r.leading() != nil {
+		return
+	}
+	if _, err := r.propose(context.Background(), "Cleanup", &proto.Empty{}, nil); err != nil {
+		r.s.log.WithField("error", err).Error("could not clean up")
+	}
+}
+
+// Apply applies a committed command, see raft.FSM
+func (r *Replica) Apply(data []byte) interface{} {
+	cmd := new(command)
+	if err := json.Unmarshal(data, cmd); err != nil {
+		return &applied{err: err}
+	}
+
+	r.clock.set(cmd.Time)
+	defer r.clock.set(time.Time{})
+	r.s.lock.Lock()
+	r.s.rand = rand.New(rand.NewSource(cmd.Seed))
+	r.s.lock.Unlock()
+
+	reply, err := r.dispatch(cmd)
+	return &applied{reply: reply, err: err}
+}
+
+// dispatch calls the server method a command was proposed for
+func (r *Replica) dispatch(cmd *command) (protobuf.Message, error) {
+	ctx := context.Background()
+	empty := new(proto.Empty)
+	switch cmd.Method {
+	case "Get", "Done", "HeartBeat":
+		req := new(proto.JobID)
+		if err := protobuf.Unmarshal(cmd.Request, req); err != nil {
+			return nil, err
+		}
+		switch cmd.Method {
+		case "Get":
+			r.expired = cmd.Expired
+			defer func() { r.expired = nil }()
+			return r.s.Get(ctx, req)
+		case "Done":
+			return r.s.Done(ctx, req)
+		}
+		// heartbeats were replicated by earlier versions, logs may
+		// still hold them
+		return r.s.HeartBeat(ctx, req)
+	case "HeartBeats":
+		req := new(proto.Leases)
+		if err := protobuf.Unmarshal(cmd.Request, req); err != nil {
+			return nil, err
+		}
+		return r.s.HeartBeats(ctx, req)
+	case "Reset":
+		return r.s.Reset(ctx, empty)
+	case "Import":
+		req := new(proto.ImportRequest)
+		if err := protobuf.Unmarshal(cmd.Request, req); err != nil {
+			return nil, err
+		}
+		return r.s.importJob(req)
+	case "Rescan":
+		r.s.lock.Lock()
+		defer r.s.lock.Unlock()
+		r.s.install(cmd.Tokens)
+		r.s.log.WithField("signal", "rescan").
+			WithField("count", r.s.tokens.len()).
+			Info("installed tokens")
+		return &proto.Ack{N: int32(r.s.tokens.len())}, nil
+	case "Shuffle":
+		return r.s.Shuffle(ctx, empty)
+	case "Drain":
+		return r.s.Drain(ctx, empty)
+	case "Resume":
+		r.s.lock.Lock()
+		defer r.s.lock.Unlock()
+		return r.s.resume(), nil
+	case "Cleanup":
+		r.s.cleanup()
+		return empty, nil
+	}
+	return nil, errors.New("unknown command: " + cmd.Method)
+}
+
+// Snapshot returns the job state, see raft.FSM
+func (r *Replica) Snapshot() ([]byte, error) {
+	r.s.lock.RLock()
+	state := &replicaState{State: r.s.snapshot(), Draining: r.s.draining}
+	r.s.lock.RUnlock()
+	defer r.s.release(state.State)
+	return json.Marshal(state)
+}
+
+// Restore replaces the job state, see raft.FSM
+func (r *Replica) Restore(data []byte) error {
+	state := new(replicaState)
+	if err := json.Unmarshal(data, state); err != nil {
+		return err
+	}
+
+	r.s.lock.Lock()
+	defer r.s.lock.Unlock()
+	if err := r.s.restore(state.State); err != nil {
+		return err
+	}
+	r.s.draining = state.Draining
+	r.s.log.WithField("count", r.s.tokens.len()).
+		WithField("jobs", len(r.s.jobs)).
+		Info("restored replicated state")
+	return nil
+}
+
+// Get names the leases the leader found expired in the command, so that
+// all replicas reassign the same one
+func (r *Replica) Get(ctx context.Context, req *proto.JobID) (*proto.Data, error) {
+	if r.s.stopped() {
+		return nil, status.Error(codes.Unavailable, "server is draining")
+	}
+	if err := r.leading(); err != nil {
+		return nil, err
+	}
+	expired, err := r.expiredKeys(req)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := r.proposeCommand(ctx, &command{Method: "Get", Expired: expired}, req)
+	if err != nil {
+		return nil, err
+	}
+	return reply.(*proto.Data), nil
+}
+
+func (r *Replica) Done(ctx context.Context, req *proto.JobID) (*proto.Ack, error) {
+	return r.ack(r.propose(ctx, "Done", req, nil))
+}
+
+// HeartBeat renews a lease on the leader alone. Renewals are not
+// replicated, a new leader renews all leases when it is elected instead.
+func (r *Replica) HeartBeat(ctx context.Context, req *proto.JobID) (*proto.Ack, error) {
+	if err := r.leading(); err != nil {
+		return nil, err
+	}
+	return r.s.HeartBeat(ctx, req)
+}
+
+// HeartBeats renews leases on the leader alone, see HeartBeat
+func (r *Replica) HeartBeats(ctx context.Context, req *proto.Leases) (*proto.Renewal, error) {
+	if err := r.leading(); err != nil {
+		return nil, err
+	}
+	return r.s.HeartBeats(ctx, req)
+}
+
+func (r *Replica) Reset(ctx context.Context, empty *proto.Empty) (*proto.Ack, error) {
+	return r.ack(r.propose(ctx, "Reset", empty, nil))
+}
+
+// Rescan scans the source on the leader and replicates the tokens it found
+func (r *Replica) Rescan(ctx context.Context, empty *proto.Empty) (*proto.Ack, error) {
+	if err := r.leading(); err != nil {
+		return nil, err
+	}
+
+	tokens, err := r.s.source.Scan()
+	if err != nil {
+		return nil, err
+	}
+	return r.ack(r.propose(ctx, "Rescan", empty, tokens))
+}
+
+func (r *Replica) Shuffle(ctx context.Context, empty *proto.Empty) (*proto.Ack, error) {
+	return r.ack(r.propose(ctx, "Shuffle", empty, nil))
+}
+
+func (r *Replica) Drain(ctx context.Context, empty *proto.Empty) (*proto.Ack, error) {
+	return r.ack(r.propose(ctx, "Drain", empty, nil))
+}
+
+func (r *Replica) Resume(ctx context.Context, empty *proto.Empty) (*proto.Ack, error) {
+	if r.s.stopped() {
+		return nil, status.Error(codes.FailedPrecondition, "server is shutting down")
+	}
+	return r.ack(r.propose(ctx, "Resume", empty, nil))
+}
+
+func (r *Replica) Show(ctx context.Context, empty *proto.Empty) (*proto.Data, error) {
+	if err := r.leading(); err != nil {
+		return nil, err
+	}
+	return r.s.Show(ctx, empty)
+}
+
+func (r *Replica) List(req *proto.ListRequest, stream proto.Tokens_ListServer) error {
+	if err := r.leading(); err != nil {
+		return err
+	}
+	return r.s.List(req, stream)
+}
+
+func (r *Replica) Export(req *proto.JobID, stream proto.Tokens_ExportServer) error {
+	if err := r.leading(); err != nil {
+		return err
+	}
+	return r.s.Export(req, stream)
+}
+
+func (r *Replica) Import(stream proto.Tokens_ImportServer) error {
+	req, err := receiveImport(stream)
+	if err != nil {
+		return err
+	}
+	ack, err := r.ack(r.propose(stream.Context(), "Import", req, nil))
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(ack)
+}
+
+func (r *Replica) Progress(ctx context.Context, req *proto.JobID) (*proto.JobProgress, error) {
+	if err := r.leading(); err != nil {
+		return nil, err
+	}
+	return r.s.Progress(ctx, req)
+}
+
+func (r *Replica) Results(req *proto.JobID, stream proto.Tokens_ResultsServer) error {
+	return r.s.Results(req, stream)
+}
+
+func (r *Replica) ack(reply protobuf.Message, err error) (*proto.Ack, error) {
+	if err != nil {
+		return nil, err
+	}
+	return reply.(*proto.Ack), nil
+}
+
+// RequestVote serves the Raft service, see raft.Node
+func (r *Replica) RequestVote(ctx context.Context, req *proto.VoteRequest) (*proto.VoteReply, error) {
+	node, err := r.raft()
+	if err != nil {
+		return nil, err
+	}
+	return node.RequestVote(ctx, req)
+}
+
+// AppendEntries serves the Raft service, see raft.Node
+func (r *Replica) AppendEntries(ctx context.Context, req *proto.AppendRequest) (*proto.AppendReply, error) {
+	node, err := r.raft()
+	if err != nil {
+		return nil, err
+	}
+	return node.AppendEntries(ctx, req)
+}
+
+// InstallSnapshot serves the Raft service, see raft.Node
+func (r *Replica) InstallSnapshot(ctx context.Context, req *proto.SnapshotRequest) (*proto.SnapshotReply, error) {
+	node, err := r.raft()
+	if err != nil {
+		return nil, err
+	}
+	return node.InstallSnapshot(ctx, req)
+}
@@ -0,0 +1,305 @@
+package scheduler
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+	"github.com/sdeoras/token/raft"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// cluster is a set of replicas serving on local ports
+type cluster struct {
+	replicas []*Replica
+	servers  []*grpc.Server
+	addrs    []string
+}
+
+// startCluster starts n replicas over the same tokens. Each serves the
+// Tokens and Raft services behind policy, if it is not nil, and dials the
+// others with peer.
+func startCluster(t *testing.T, n int, policy *Policy, peer *proto.DialConfig, opts ...Option) *cluster {
+	c := &cluster{}
+	listeners := make([]net.Listener, n)
+	for i := range listeners {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners[i] = lis
+		c.addrs = append(c.addrs, lis.Addr().String())
+	}
+
+	dialOpts, err := peer.DialOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, lis := range listeners {
+		srv := New(append([]Option{WithSource(testTokens(100)), WithLogger(quietLogger())}, opts...)...)
+		replica, err := NewReplica(srv, raft.Config{
+			ID:                c.addrs[i],
+			Peers:             c.addrs,
+			Dir:               t.TempDir(),
+			HeartbeatInterval: 20 * time.Millisecond,
+			ElectionTimeout:   200 * time.Millisecond,
+			DialOptions:       dialOpts,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var serverOpts []grpc.ServerOption
+		if policy != nil {
+			serverOpts = append(serverOpts,
+				grpc.UnaryInterceptor(policy.UnaryInterceptor()),
+				grpc.StreamInterceptor(policy.StreamInterceptor()))
+		}
+		s := grpc.NewServer(serverOpts...)
+		proto.RegisterTokensServer(s, replica)
+		proto.RegisterRaftServer(s, replica)
+		go s.Serve(lis)
+
+		if err := replica.Start(); err != nil {
+			t.Fatal(err)
+		}
+		c.replicas = append(c.replicas, replica)
+		c.servers = append(c.servers, s)
+	}
+
+	t.Cleanup(func() {
+		for i := range c.replicas {
+			c.stop(i)
+		}
+	})
+	return c
+}
+
+// stop stops replica i, a stopped replica is nil
+func (c *cluster) stop(i int) {
+	if c.replicas[i] != nil {
+		c.servers[i].Stop()
+		c.replicas[i].Stop()
+		c.replicas[i] = nil
+	}
+}
+
+// follower returns the index of a running replica that does not lead
+func (c *cluster) follower(leader int) int {
+	for i, replica := range c.replicas {
+		if i != leader && replica != nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// holder waits up to a few seconds for replica i to see key held by worker
+func (c *cluster) holder(i int, id, key, worker string) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		data, unlock := c.replicas[i].s.lockJob(id, false)
+		held := data != nil && data.leases[key] != nil && data.leases[key].worker == worker
+		unlock()
+		if held {
+			return true
+		}
+	}
+	return false
+}
+
+// leader waits up to timeout for a replica to win an election and replay
+// the log and returns its index, or -1
+func (c *cluster) leader(timeout time.Duration) int {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		for i, replica := range c.replicas {
+			if replica == nil {
+				continue
+			}
+			if node, err := replica.raft(); err == nil && node.Ready() {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// writePolicy saves a policy to a file and loads it back
+func writePolicy(t *testing.T, policy string) *Policy {
+	file := filepath.Join(t.TempDir(), "policy.json")
+	if err := ioutil.WriteFile(file, []byte(policy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicy(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestAuthenticatedClusterElectsLeader(t *testing.T) {
+	policy := writePolicy(t, `{"Identities": [
+		{"Name": "worker", "Role": "worker", "Token": "worker-token"},
+		{"Name": "replicas", "Role": "peer", "Token": "peer-token"}
+	]}`)
+
+	tests := []struct {
+		name   string
+		token  string
+		leader bool
+	}{
+		{"peer token", "peer-token", true},
+		{"worker token", "worker-token", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := startCluster(t, 3, policy, &proto.DialConfig{Token: test.token})
+			timeout := 5 * time.Second
+			if !test.leader {
+				timeout = time.Second
+			}
+			if elected := c.leader(timeout) >= 0; elected != test.leader {
+				t.Errorf("expected leader elected to be %v", test.leader)
+			}
+		})
+	}
+}
+
+func TestLeaseRenewalOnLeader(t *testing.T) {
+	clock := newTestClock()
+	c := startCluster(t, 3, nil, &proto.DialConfig{}, WithClock(clock))
+	ctx := context.Background()
+	leader := c.leader(5 * time.Second)
+	if leader < 0 {
+		t.Fatal("no leader elected")
+	}
+
+	data, err := c.replicas[leader].Get(ctx, &proto.JobID{ID: "job", BatchSize: 100, Worker: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	renew := &proto.Leases{ID: "job", Worker: "a", Keys: []string{data.Key}}
+
+	// followers point workers at the leader instead of renewing
+	follower := c.follower(leader)
+	if _, err := c.replicas[follower].HeartBeats(ctx, renew); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected a follower to refuse heartbeats, got %v", err)
+	}
+
+	// the lease expires and is reassigned on all replicas
+	clock.advance(2 * time.Minute)
+	again, err := c.replicas[leader].Get(ctx, &proto.JobID{ID: "job", BatchSize: 100, Worker: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Key != data.Key {
+		t.Fatalf("expected lease %s to be reassigned, got %q", data.Key, again.Key)
+	}
+	for i := range c.replicas {
+		if !c.holder(i, "job", data.Key, "b") {
+			t.Errorf("replica %d does not see the lease reassigned to b", i)
+		}
+	}
+
+	// b keeps its lease alive on the leader alone
+	renew.Worker = "b"
+	for i := 0; i < 2; i++ {
+		clock.advance(50 * time.Second)
+		renewal, err := c.replicas[leader].HeartBeats(ctx, renew)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(renewal.Leases) != 1 || !renewal.Leases[0].Status {
+			t.Fatalf("expected the lease to be renewed, got %v", renewal)
+		}
+	}
+
+	// a new leader gives b a full lease timeout to find it
+	c.stop(leader)
+	leader = c.leader(5 * time.Second)
+	if leader < 0 {
+		t.Fatal("no leader elected after the old one stopped")
+	}
+	data, err = c.replicas[leader].Get(ctx, &proto.JobID{ID: "job", BatchSize: 100, Worker: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Tokens) != 0 {
+		t.Errorf("expected the lease of b to survive the election, got %s reassigned", data.Key)
+	}
+}
+
+func TestFollowersPointAtLeader(t *testing.T) {
+	c := startCluster(t, 3, nil, &proto.DialConfig{})
+	ctx := context.Background()
+	leader := c.leader(5 * time.Second)
+	if leader < 0 {
+		t.Fatal("no leader elected")
+	}
+	follower := c.follower(leader)
+
+	// wait for the follower to hear from the leader
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		_, err := c.replicas[follower].Progress(ctx, &proto.JobID{ID: "job"})
+		if proto.Leader(err) == c.addrs[leader] {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected follower to name leader %s, got %v", c.addrs[leader], err)
+		}
+	}
+	if _, err := c.replicas[follower].Show(ctx, &proto.Empty{}); proto.Leader(err) != c.addrs[leader] {
+		t.Errorf("expected Show to name the leader, got %v", err)
+	}
+
+	// clients listing the follower first fail over to the leader
+	conn, err := (&proto.DialConfig{}).Dial(c.addrs[follower] + "," + c.addrs[leader])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := proto.NewTokensClient(conn)
+	data, err := client.Show(ctx, &proto.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Tokens) != 100 {
+		t.Errorf("expected 100 tokens from the leader, got %d", len(data.Tokens))
+	}
+}
+
+func TestReplicaHealthAfterReplay(t *testing.T) {
+	c := startCluster(t, 3, nil, &proto.DialConfig{})
+	ctx := context.Background()
+	health := func(i int) healthpb.HealthCheckResponse_ServingStatus {
+		reply, err := c.replicas[i].Health().Check(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply.Status
+	}
+
+	// no leader has committed an entry yet, so none of the logs is replayed
+	for i := range c.replicas {
+		if st := health(i); st != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("expected replica %d to report NOT_SERVING before the election, got %s", i, st)
+		}
+	}
+
+	if c.leader(5*time.Second) < 0 {
+		t.Fatal("no leader elected")
+	}
+	for i := range c.replicas {
+		for deadline := time.Now().Add(5 * time.Second); health(i) != healthpb.HealthCheckResponse_SERVING; time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected replica %d to report SERVING once it replayed the log", i)
+			}
+		}
+	}
+}
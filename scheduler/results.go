@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sdeoras/token/proto"
+)
+
+// ResultStore commits results that workers send along with Done()
+type ResultStore interface {
+	// Append commits records for jobID. Either all records are written
+	// or an error is returned.
+	Append(jobID string, records []*proto.Record) error
+
+	// Stream calls send for every record committed for jobID so far
+	Stream(jobID string, send func(*proto.Record) error) error
+}
+
+// resultDir keeps worker results in one append-only JSONL file per job
+type resultDir struct {
+	dir  string
+	lock sync.Mutex
+}
+
+// ResultDir returns a ResultStore keeping results in folder dir
+func ResultDir(dir string) (ResultStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &resultDir{dir: dir}, nil
+}
+
+func (r *resultDir) fileName(jobID string) string {
+	return filepath.Join(r.dir, url.PathEscape(jobID)+".jsonl")
+}
+
+func (r *resultDir) Append(jobID string, records []*proto.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var b []byte
+	for _, record := range records {
+		line, err := proto.MarshalRecord(record)
+		if err != nil {
+			return err
+		}
+		b = append(b, line...)
+		b = append(b, '\n')
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	f, err := os.OpenFile(r.fileName(jobID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	// a write that fails half way is cut off again so that a retry of the
+	// same Done does not leave its records in the file twice
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Truncate(info.Size())
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (r *resultDir) Stream(jobID string, send func(*proto.Record) error) error {
+	// only read what was committed when the call started so that
+	// appends are not held up by slow readers
+	r.lock.Lock()
+	info, err := os.Stat(r.fileName(jobID))
+	r.lock.Unlock()
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(r.fileName(jobID))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(io.LimitReader(f, info.Size()))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return errors.New("truncated result file for JobId: " + jobID)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		record, err := proto.UnmarshalRecord(line)
+		if err != nil {
+			return err
+		}
+		if err := send(record); err != nil {
+			return err
+		}
+	}
+}
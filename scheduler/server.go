@@ -0,0 +1,871 @@
+// Package scheduler hands out tokens, such as file names, to workers in
+// batches and keeps track of which batches are done. Server implements
+// proto.TokensServer so it can be registered on any grpc.Server.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/status"
+)
+
+var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+// job is the bookkeeping for a single job ID. Its fields are guarded by lock
+// while the server lock is held for reading, or by the server lock alone
+// while it is held for writing.
+type job struct {
+	lock sync.Mutex
+
+	currentIndex  int
+	startTime     time.Time
+	endTime       time.Time
+	completed     bool
+	totalDuration time.Duration
+	leases        map[string]*lease
+	expiry        leaseHeap
+	recent        recentKeys // worker that committed a key
+	requests      recentKeys // key of the lease granted to a Get request
+	finished      intervals
+	failed        intervals
+	commits       commits
+	workers       map[string]*throughput
+	shardIndex    []int
+	shardSeen     []time.Time
+	imported      string // fingerprint of the import that created the job
+
+	// lease counters since the job was created
+	leasesGranted    int
+	leasesReassigned int
+	leasesCompleted  int
+	leasesExpired    int
+	tokensCompleted  int
+	history          history
+}
+
+// lease is a range of tokens handed to a worker under a key
+type lease struct {
+	key       string
+	index     int
+	start     int
+	count     int
+	worker    string
+	granted   time.Time
+	heartbeat time.Time
+	missed    bool // expiry was recorded in the audit log
+}
+
+// claim is a range of tokens a worker holds under a key since a time
+type claim struct {
+	key    string
+	start  int
+	count  int
+	worker string
+	time   time.Time
+}
+
+// recentKeys remembers a value per key for a lease timeout, which is as long
+// as a worker may retry the call that stored it
+type recentKeys struct {
+	values map[string]string
+	order  []recentKey
+}
+
+// recentKey is a key stored at time
+type recentKey struct {
+	key  string
+	time time.Time
+}
+
+func (r *recentKeys) add(key, value string, t time.Time) {
+	if r.values == nil {
+		r.values = make(map[string]string)
+	}
+	r.values[key] = value
+	r.order = append(r.order, recentKey{key: key, time: t})
+}
+
+func (r *recentKeys) value(key string) (string, bool) {
+	value, present := r.values[key]
+	return value, present
+}
+
+// expire forgets the keys stored before t
+func (r *recentKeys) expire(t time.Time) {
+	n := 0
+	for n < len(r.order) && r.order[n].time.Before(t) {
+		delete(r.values, r.order[n].key)
+		n++
+	}
+	r.order = r.order[n:]
+}
+
+// Server keeps the list of tokens and per job bookkeeping
+type Server struct {
+	source           Source
+	leaseTimeout     time.Duration
+	targetLease      time.Duration
+	shards           int
+	stealAfter       time.Duration
+	jobTTL           time.Duration
+	cleanupInterval  time.Duration
+	snapshotInterval time.Duration
+	clock            Clock
+	log              logrus.FieldLogger
+	store            StateStore
+	results          ResultStore
+	audit            AuditLog
+	events           *auditQueue
+	partition        *partitionSource
+	manifest         string
+
+	// lock guards the token list, the set of jobs and server wide flags.
+	// Calls on a single job hold it for reading along with the lock of the
+	// job, calls that change all jobs or the token list hold it for writing.
+	lock   timedMutex
+	tokens *tokenList
+	jobs   map[string]*job
+	// generation counts the changes to the token list and its order, a
+	// listing that spans one is aborted
+	generation uint64
+
+	randLock sync.Mutex
+	rand     *rand.Rand
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+
+	// clean drops old jobs, replicas agree on when through the leader
+	clean func()
+	// sweep records leases that missed their heartbeat, replicas only on
+	// the leader since only it receives heartbeats
+	sweep func()
+	// reassignable picks the expired lease Get hands out again, replicas
+	// take the one the leader picked
+	reassignable func(data *job, req *proto.JobID, now time.Time) (*lease, error)
+
+	metrics  *metrics
+	health   *health.Server
+	serving  bool
+	draining bool
+	stopping chan struct{}
+	shutdown sync.Once
+
+	// replaying keeps health at NOT_SERVING after Start until a replica has
+	// caught up with the replicated log
+	replaying bool
+}
+
+// New returns a Server configured by opts. It does not hand out tokens
+// until Start is called.
+func New(opts ...Option) *Server {
+	s := &Server{
+		leaseTimeout:     time.Minute,
+		jobTTL:           time.Hour * 24,
+		cleanupInterval:  time.Hour,
+		snapshotInterval: time.Minute,
+		clock:            systemClock{},
+		log:              logrus.StandardLogger(),
+		jobs:             make(map[string]*job),
+		stopping:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.rand = rand.New(rand.NewSource(s.clock.Now().UnixNano()))
+	s.clean = s.cleanup
+	s.sweep = func() { s.expireLeases(s.clock.Now()) }
+	s.reassignable = s.reassignExpired
+	s.metrics = newMetrics(s)
+	s.lock.wait = s.metrics.lockWait
+	s.health = health.NewServer()
+	s.setServing(false)
+	return s
+}
+
+// Start replays state from the state store, if there is any, or else scans
+// the source for tokens. It then starts background bookkeeping.
+func (s *Server) Start() error {
+	if s.source == nil {
+		return errors.New("no token source configured")
+	}
+	if s.leaseTimeout <= 0 {
+		return errors.New("lease timeout has to be positive")
+	}
+	if s.targetLease < 0 || s.shards < 0 || s.stealAfter < 0 {
+		return errors.New("target lease, shards and steal after cannot be negative")
+	}
+	if s.partition != nil {
+		if s.partition.index < 0 || s.partition.index >= len(s.partition.partitions) {
+			return errors.New("partition index out of range")
+		}
+		s.partition.source = s.source
+		s.source = s.partition
+	}
+	if s.audit != nil && s.events == nil {
+		s.events = newAuditQueue()
+		go s.writeEvents(s.events)
+	}
+
+	replayed, err := s.load()
+	if err != nil {
+		return err
+	}
+	if !replayed {
+		if err := s.rescan(); err != nil {
+			return err
+		}
+	}
+
+	s.lock.Lock()
+	s.serving = true
+	if !s.replaying {
+		s.setServing(true)
+	}
+	s.lock.Unlock()
+
+	s.quit = make(chan struct{})
+	s.wg.Add(1)
+	go s.run()
+	return nil
+}
+
+// Stop ends background bookkeeping, saves state to the state store and
+// closes the audit log
+func (s *Server) Stop() error {
+	if s.quit != nil {
+		close(s.quit)
+		s.wg.Wait()
+		s.quit = nil
+	}
+	err := s.save()
+	if cerr := s.closeAudit(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// run drops old jobs, records expired leases and snapshots state until
+// Stop is called
+func (s *Server) run() {
+	defer s.wg.Done()
+	s.log.Info("starting cleaner bot")
+
+	cleanup := time.NewTicker(s.cleanupInterval)
+	defer cleanup.Stop()
+	snapshot := time.NewTicker(s.snapshotInterval)
+	defer snapshot.Stop()
+	sweep := time.NewTicker(s.sweepInterval())
+	defer sweep.Stop()
+
+	for {
+		select {
+		case <-s.quit:
+			s.log.Info("stopping cleaner bot")
+			return
+		case <-cleanup.C:
+			s.clean()
+		case <-sweep.C:
+			s.sweep()
+		case <-snapshot.C:
+			if err := s.save(); err != nil {
+				s.log.WithField("error", err).Error("could not save state")
+			}
+		}
+	}
+}
+
+func (s *Server) cleanup() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.log.WithField("count", s.tokens.len()).Info("cleanup bot")
+	for id, data := range s.jobs {
+		if s.clock.Now().Sub(data.startTime) >= s.jobTTL {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func (s *Server) initJob(id string) *job {
+	data, present := s.jobs[id]
+	if !present {
+		data = &job{
+			leases:    make(map[string]*lease),
+			workers:   make(map[string]*throughput),
+			startTime: s.clock.Now(),
+		}
+		if s.shards > 0 {
+			s.initShards(data)
+		}
+		s.jobs[id] = data
+		s.event(Event{Type: eventJobCreated, JobID: id})
+	}
+	return data
+}
+
+// lockJob locks the job with id and the server lock for reading, creating
+// the job if create is set. It returns nil if the job is not present.
+// Callers must call unlock once done, also when the job is nil.
+func (s *Server) lockJob(id string, create bool) (data *job, unlock func()) {
+	s.lock.RLock()
+	data, present := s.jobs[id]
+	for !present && create {
+		s.lock.RUnlock()
+		s.lock.Lock()
+		s.initJob(id)
+		s.lock.Unlock()
+
+		// the job may be gone again by the time the read lock is held
+		s.lock.RLock()
+		data, present = s.jobs[id]
+	}
+	if !present {
+		return nil, s.lock.RUnlock
+	}
+
+	data.lock.Lock()
+	return data, func() {
+		data.lock.Unlock()
+		s.lock.RUnlock()
+	}
+}
+
+// leaseData lists the tokens covered by l
+func (s *Server) leaseData(key string, l *lease) *proto.Data {
+	return &proto.Data{Tokens: s.tokens.names(l.start, l.count), Key: key, Bytes: s.rangeBytes(l.start, l.count)}
+}
+
+func (s *Server) Get(ctx context.Context, req *proto.JobID) (*proto.Data, error) {
+	s.lock.RLock()
+	err := s.ready()
+	s.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkShard(req); err != nil {
+		return nil, err
+	}
+
+	data, unlock := s.lockJob(req.ID, true)
+	defer unlock()
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	if out := s.retried(data, req, now); out != nil {
+		return out, nil
+	}
+	if s.draining {
+		// workers stop as if the job was done instead of waiting for
+		// the server to resume
+		return &proto.Data{Draining: true}, nil
+	}
+	s.log.WithField("jobID", req.ID).
+		WithField("worker", req.Worker).
+		WithField("shard", req.Shard).
+		WithField("signal", "get").
+		Info("get request")
+
+	next := s.nextCursor(data, req)
+
+	// tokens that were finished when the job was imported are stepped over
+	// and fresh leases stop short of them
+	ind, end := data.finished.gap(*next.next)
+	if ind > next.end {
+		ind = next.end
+	}
+	if end < next.end {
+		next.end = end
+	}
+	*next.next = ind
+
+	// a byte budget without a count is bounded only by what's left
+	requested := int(req.BatchSize)
+	if requested <= 0 && req.ByteBudget > 0 {
+		requested = next.end - ind
+	}
+
+	batchSize := s.adaptiveBatchSize(data, req.Worker, requested)
+	if batchSize > next.end-ind {
+		batchSize = next.end - ind
+	}
+	batchSize = s.budgetBatchSize(ind, batchSize, req.ByteBudget)
+
+	if batchSize > 0 {
+		key := s.newKey(data)
+		*next.next += batchSize
+		l := &lease{start: ind, count: batchSize, worker: req.Worker, granted: now, heartbeat: now}
+		data.grant(key, l)
+		data.leasesGranted++
+		data.completed = false
+		s.event(Event{Type: eventLeaseGranted, JobID: req.ID, Key: key, Worker: req.Worker, Tokens: l.tokenRange()})
+		data.granted(req, key, now)
+		out := s.leaseData(key, l)
+		s.log.WithField("key", key).
+			WithField("count", len(out.Tokens)).
+			WithField("bytes", out.Bytes).
+			WithField("jobID", req.ID).
+			Info("assigned")
+		return out, nil
+	}
+
+	// try to assign previously assigned work
+	reassigned, err := s.reassignable(data, req, now)
+	if err != nil {
+		return nil, err
+	}
+	if l := reassigned; l != nil {
+		s.event(Event{Type: eventLeaseReassigned, JobID: req.ID, Key: l.key, Worker: req.Worker,
+			Previous: l.worker, Tokens: l.tokenRange()})
+		l.worker = req.Worker
+		l.granted = now
+		data.renew(l, now)
+		data.leasesReassigned++
+		data.granted(req, l.key, now)
+		out := s.leaseData(l.key, l)
+		s.log.WithField("key", l.key).
+			WithField("count", len(out.Tokens)).
+			WithField("jobID", req.ID).
+			Info("re-assigned")
+		return out, nil
+	}
+
+	if len(data.leases) == 0 {
+		s.log.WithField("jobID", req.ID).
+			Info("nothing pending")
+	}
+	return &proto.Data{}, nil
+}
+
+// retried returns the lease granted to an earlier attempt of the Get request
+// req if its worker still holds it
+func (s *Server) retried(data *job, req *proto.JobID, now time.Time) *proto.Data {
+	if req.Request == "" {
+		return nil
+	}
+	data.requests.expire(now.Add(-s.leaseTimeout))
+	key, present := data.requests.value(req.Request)
+	if !present {
+		return nil
+	}
+	l, present := data.leases[key]
+	if !present || l.worker != req.Worker {
+		return nil
+	}
+	s.log.WithField("jobID", req.ID).
+		WithField("key", key).
+		WithField("worker", req.Worker).
+		Info("returned lease to retried get")
+	return s.leaseData(key, l)
+}
+
+// granted remembers the lease granted to the Get request req
+func (data *job) granted(req *proto.JobID, key string, now time.Time) {
+	if req.Request != "" {
+		data.requests.add(req.Request, key, now)
+	}
+}
+
+// reassignExpired returns the first lease the worker of req may take over
+// because it missed its heartbeats, if any
+func (s *Server) reassignExpired(data *job, req *proto.JobID, now time.Time) (*lease, error) {
+	leases, err := s.expiredLeases(data, req, now, 1)
+	if len(leases) == 0 {
+		return nil, err
+	}
+	return leases[0], err
+}
+
+// expiredLeases returns up to max leases the worker of req may take over,
+// they are found through the heap ordered by their last heartbeat. The
+// caller must hold the lock of the job.
+func (s *Server) expiredLeases(data *job, req *proto.JobID, now time.Time, max int) ([]*lease, error) {
+	var leases []*lease
+	var fault bool
+	data.expiry.expired(now.Add(-s.leaseTimeout), func(l *lease) bool {
+		// check sanity of values
+		if !s.validLease(l) {
+			fault = true
+			return true
+		}
+		if !l.missed {
+			s.missed(req.ID, data, l)
+		}
+		if s.mayReassign(data, req, l.start) {
+			leases = append(leases, l)
+		}
+		return len(leases) >= max
+	})
+	if fault {
+		return nil, errors.New("bookkeeping fault for JobId: " + req.ID)
+	}
+	return leases, nil
+}
+
+// validLease tells whether l covers tokens that exist
+func (s *Server) validLease(l *lease) bool {
+	return l.start >= 0 && l.count >= 0 && l.start+l.count <= s.tokens.len()
+}
+
+func (s *Server) Reset(ctx context.Context, empty *proto.Empty) (*proto.Ack, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	s.log.WithField("signal", "reset").
+		Info("deleting history")
+
+	s.event(Event{Type: eventReset, Count: len(s.jobs)})
+	s.jobs = make(map[string]*job)
+	return &proto.Ack{N: int32(s.tokens.len())}, nil
+}
+
+func (s *Server) Rescan(ctx context.Context, empty *proto.Empty) (*proto.Ack, error) {
+	s.lock.Lock()
+	err := s.ready()
+	s.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.rescan(); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.log.WithField("signal", "rescan").
+		WithField("count", s.tokens.len()).
+		Info("scanning folder")
+
+	return &proto.Ack{N: int32(s.tokens.len())}, nil
+}
+
+// rescan replaces the token list with what the source holds now. Job
+// bookkeeping refers to token indexes and is therefore dropped.
+func (s *Server) rescan() error {
+	tokens, err := s.source.Scan()
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.install(tokens)
+	return nil
+}
+
+// install replaces the token list and drops job bookkeeping, the caller
+// must hold the lock
+func (s *Server) install(tokens []Token) {
+	s.setTokens(tokens)
+	s.jobs = make(map[string]*job)
+	s.event(Event{Type: eventRescan, Count: s.tokens.len()})
+}
+
+// setTokens replaces the token list with tokens, see setList
+func (s *Server) setTokens(tokens []Token) {
+	s.setList(newTokenList(tokens))
+}
+
+// setList replaces the token list, which is mapped from the manifest if
+// there is one. A manifest that cannot be written leaves the list on the
+// heap. The caller must hold the lock.
+func (s *Server) setList(list *tokenList) {
+	if s.manifest != "" {
+		mapped, err := s.mapTokens(list)
+		if err != nil {
+			s.log.WithField("manifest", s.manifest).
+				WithField("error", err).
+				Error("could not map token manifest")
+		} else {
+			mapped.order = list.order
+			list = mapped
+		}
+	}
+
+	if err := s.tokens.close(); err != nil {
+		s.log.WithField("error", err).Error("could not unmap token manifest")
+	}
+	s.tokens = list
+	s.generation++
+}
+
+// tokenGeneration returns the generation of the token list
+func (s *Server) tokenGeneration() uint64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.generation
+}
+
+// mapTokens writes list to the manifest and maps it back
+func (s *Server) mapTokens(list *tokenList) (*tokenList, error) {
+	if err := writeManifest(s.manifest, list); err != nil {
+		return nil, err
+	}
+	return openManifest(s.manifest)
+}
+
+func (s *Server) Shuffle(ctx context.Context, empty *proto.Empty) (*proto.Ack, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+
+	s.log.WithField("signal", "shuffle").
+		WithField("count", s.tokens.len()).
+		Info("shuffling tokens")
+	s.tokens.shuffle(s.rand)
+	s.generation++
+	s.event(Event{Type: eventShuffle, Count: s.tokens.len()})
+	return &proto.Ack{N: int32(s.tokens.len())}, nil
+}
+
+func (s *Server) Show(ctx context.Context, empty *proto.Empty) (*proto.Data, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+
+	s.log.WithField("signal", "show").
+		WithField("count", s.tokens.len()).
+		Info("listing tokens")
+	return &proto.Data{Tokens: s.tokens.names(0, s.tokens.len())}, nil
+}
+
+func (s *Server) Done(ctx context.Context, req *proto.JobID) (*proto.Ack, error) {
+	ack, err := s.done(req)
+	if err == nil && ack.Draining {
+		s.lock.Lock()
+		if s.draining && s.outstanding() == 0 {
+			s.log.Info("drained, no leases remain")
+		}
+		s.lock.Unlock()
+	}
+	return ack, err
+}
+
+// done commits the lease of req under the lock of its job
+func (s *Server) done(req *proto.JobID) (*proto.Ack, error) {
+	data, unlock := s.lockJob(req.ID, false)
+	defer unlock()
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+
+	s.log.WithField("signal", "done").
+		WithField("jobID", req.ID).WithField("key", req.Key).
+		WithField("worker", req.Worker).
+		WithField("records", len(req.Records)).
+		Info("done")
+
+	if data == nil {
+		s.log.WithField("jobID", req.ID).
+			Info("server could not find job id")
+		return &proto.Ack{}, nil
+	}
+
+	now := s.clock.Now()
+	data.recent.expire(now.Add(-s.leaseTimeout))
+	l, present := data.leases[req.Key]
+	if !present {
+		// a retry of a Done that was already committed by the same
+		// holder within a lease timeout gets the original answer, results
+		// are not written again. Anonymous workers cannot tell their
+		// retries apart from another worker's Done and are refused.
+		if holder, present := data.recent.value(req.Key); present && req.Worker != "" && holder == req.Worker {
+			s.log.WithField("jobID", req.ID).
+				WithField("key", req.Key).
+				WithField("worker", req.Worker).
+				Info("key already committed by worker")
+			return &proto.Ack{Status: true}, nil
+		}
+
+		s.log.WithField("jobID", req.ID).
+			WithField("key", req.Key).
+			Info("key not found")
+		return &proto.Ack{}, nil
+	}
+	if l.worker != req.Worker {
+		// the lease expired and was handed to another worker, whose
+		// results count instead
+		s.log.WithField("jobID", req.ID).
+			WithField("key", req.Key).
+			WithField("worker", req.Worker).
+			WithField("holder", l.worker).
+			Info("key held by another worker")
+		return &proto.Ack{}, nil
+	}
+
+	// results are committed before the key is released so that
+	// a failed write leaves the lease in place for a retry
+	if s.results != nil {
+		if err := s.results.Append(req.ID, req.Records); err != nil {
+			return nil, err
+		}
+	}
+
+	s.log.WithField("jobID", req.ID).
+		WithField("key", req.Key).
+		Info("deleting key")
+	s.recordDone(data, l)
+	data.release(req.Key)
+	data.leasesCompleted++
+	data.tokensCompleted += l.count
+	data.finished.add(l.start, l.start+l.count)
+	s.event(Event{Type: eventDone, JobID: req.ID, Key: req.Key, Worker: req.Worker, Tokens: l.tokenRange()})
+	if n := s.recordFailed(data, l, req.Records); n > 0 {
+		s.event(Event{Type: eventFailed, JobID: req.ID, Key: req.Key, Worker: req.Worker,
+			Tokens: l.tokenRange(), Count: n})
+	}
+	data.commits.add(l.start, l.start+l.count, req.Worker, now)
+	data.history.add(now, l.count)
+	data.recent.add(req.Key, req.Worker, now)
+	if len(data.leases) == 0 {
+		data.completed = true
+		data.endTime = now
+		data.totalDuration = data.endTime.Sub(data.startTime)
+		s.log.WithField("jobID", req.ID).
+			WithField("completed", data.completed).
+			WithField("duration", data.totalDuration).Info("done")
+	}
+	return &proto.Ack{Status: true, Draining: s.draining}, nil
+}
+
+func (s *Server) Results(req *proto.JobID, stream proto.Tokens_ResultsServer) error {
+	s.log.WithField("signal", "results").
+		WithField("jobID", req.ID).
+		Info("streaming results")
+
+	if s.results == nil {
+		return errors.New("result collection is not configured")
+	}
+	// streams end when the server shuts down so that it need not wait for
+	// slow readers
+	return s.results.Stream(req.ID, func(record *proto.Record) error {
+		if s.stopped() {
+			return status.Error(codes.Unavailable, "server is draining")
+		}
+		return stream.Send(record)
+	})
+}
+
+// HeartBeat renews a single lease. A lease that is unknown or was reassigned
+// to another worker is reported lost and is not renewed.
+func (s *Server) HeartBeat(ctx context.Context, req *proto.JobID) (*proto.Ack, error) {
+	data, unlock := s.lockJob(req.ID, false)
+	defer unlock()
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	s.log.WithField("jobID", req.ID).
+		WithField("key", req.Key).
+		WithField("signal", "heartbeat").
+		Info("received heartbeat")
+	if data == nil {
+		return &proto.Ack{}, errors.New("job id not present")
+	}
+	l, present := data.leases[req.Key]
+	if !present || (req.Worker != "" && l.worker != req.Worker) {
+		return &proto.Ack{Status: data.completed, Lost: true, Draining: s.draining}, nil
+	}
+	data.renew(l, s.clock.Now())
+	return &proto.Ack{Status: data.completed, Draining: s.draining}, nil
+}
+
+// HeartBeats renews all leases a worker holds for a job under a single lock.
+// A lease that was reassigned to another worker is reported lost and is not
+// renewed.
+func (s *Server) HeartBeats(ctx context.Context, req *proto.Leases) (*proto.Renewal, error) {
+	data, unlock := s.lockJob(req.ID, false)
+	defer unlock()
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	s.log.WithField("jobID", req.ID).
+		WithField("worker", req.Worker).
+		WithField("count", len(req.Keys)).
+		WithField("signal", "heartbeats").
+		Info("received heartbeats")
+	if data == nil {
+		return &proto.Renewal{}, errors.New("job id not present")
+	}
+
+	now := s.clock.Now()
+	out := &proto.Renewal{
+		Leases:    make([]*proto.LeaseStatus, len(req.Keys)),
+		Completed: data.completed,
+		Draining:  s.draining,
+	}
+	for i, key := range req.Keys {
+		status := &proto.LeaseStatus{Key: key}
+		if l, present := data.leases[key]; present && (req.Worker == "" || l.worker == req.Worker) {
+			data.renew(l, now)
+			status.Status = true
+		}
+		out.Leases[i] = status
+	}
+	return out, nil
+}
+
+// recordFailed marks the tokens of l that records report an error for and
+// returns how many there are
+func (s *Server) recordFailed(data *job, l *lease, records []*proto.Record) int {
+	var failed map[string]bool
+	for _, record := range records {
+		if record.Error != "" {
+			if failed == nil {
+				failed = make(map[string]bool)
+			}
+			failed[record.Token] = true
+		}
+	}
+	if failed == nil {
+		return 0
+	}
+
+	n := 0
+	for i, name := range s.tokens.names(l.start, l.count) {
+		if failed[name] {
+			data.failed.add(l.start+i, l.start+i+1)
+			n++
+		}
+	}
+	return n
+}
+
+// newKey returns a lease key that has not been used for the job before
+func (s *Server) newKey(data *job) string {
+	for {
+		key := s.partitionKey(s.randStringRunes(8)) // generate 8 char wide random string
+		if _, present := data.leases[key]; present {
+			continue
+		}
+		if _, present := data.recent.value(key); present {
+			continue
+		}
+		return key
+	}
+}
+
+func (s *Server) randStringRunes(n int) string {
+	s.randLock.Lock()
+	defer s.randLock.Unlock()
+	b := make([]rune, n)
+	for i := range b {
+		b[i] = letterRunes[s.rand.Intn(len(letterRunes))]
+	}
+	return string(b)
+}
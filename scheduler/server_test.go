@@ -0,0 +1,729 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	protobuf "github.com/golang/protobuf/proto"
+	"github.com/sdeoras/token/proto"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// staticSource hands out a fixed list of tokens
+type staticSource []Token
+
+func (s staticSource) Scan() ([]Token, error) {
+	return append([]Token(nil), s...), nil
+}
+
+// testTokens returns n tokens named like the files of a folder
+func testTokens(n int) staticSource {
+	tokens := make(staticSource, n)
+	for i := range tokens {
+		tokens[i] = Token{Name: fmt.Sprintf("img%06d.jpg", i), Size: int64(i + 1)}
+	}
+	return tokens
+}
+
+// testClock only moves when told to
+type testClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2018, 12, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+	c.lock.Unlock()
+}
+
+// quietLogger drops the per call logging of the server
+func quietLogger() logrus.FieldLogger {
+	log := logrus.New()
+	log.Out = ioutil.Discard
+	log.SetLevel(logrus.WarnLevel)
+	return log
+}
+
+// newTestServer starts a server over n tokens with a lease timeout of a
+// minute on a clock that only moves when advanced
+func newTestServer(tb testing.TB, n int, opts ...Option) (*Server, *testClock) {
+	clock := newTestClock()
+	opts = append([]Option{
+		WithSource(testTokens(n)),
+		WithClock(clock),
+		WithLogger(quietLogger()),
+	}, opts...)
+	s := New(opts...)
+	if err := s.Start(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if err := s.Stop(); err != nil {
+			tb.Error(err)
+		}
+	})
+	return s, clock
+}
+
+// memResults keeps appended records in memory
+type memResults struct {
+	lock    sync.Mutex
+	records map[string][]*proto.Record
+}
+
+func (m *memResults) Append(jobID string, records []*proto.Record) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.records == nil {
+		m.records = make(map[string][]*proto.Record)
+	}
+	m.records[jobID] = append(m.records[jobID], records...)
+	return nil
+}
+
+func (m *memResults) Stream(jobID string, send func(*proto.Record) error) error {
+	m.lock.Lock()
+	records := append([]*proto.Record(nil), m.records[jobID]...)
+	m.lock.Unlock()
+	for _, record := range records {
+		if err := send(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memResults) count(jobID string) int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.records[jobID])
+}
+
+// records returns one result record per token of data
+func records(data *proto.Data) []*proto.Record {
+	out := make([]*proto.Record, len(data.Tokens))
+	for i, token := range data.Tokens {
+		out[i] = &proto.Record{Token: token}
+	}
+	return out
+}
+
+func TestResultDir(t *testing.T) {
+	dir := t.TempDir()
+	store, err := ResultDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := func(jobID string) ([]*proto.Record, error) {
+		var out []*proto.Record
+		err := store.Stream(jobID, func(record *proto.Record) error {
+			out = append(out, record)
+			return nil
+		})
+		return out, err
+	}
+
+	records := []*proto.Record{
+		{Token: "img000000.jpg", Data: []byte(`{"label":"cat"}`)},
+		{Token: "img000001.jpg", Error: "corrupt image"},
+		{Token: "img000002.jpg", Data: []byte("dog")},
+	}
+	for _, batch := range [][]*proto.Record{records[:2], nil, records[2:]} {
+		if err := store.Append("runs/1", batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// data that is not JSON comes back as a JSON string
+	want := []*proto.Record{
+		records[0],
+		records[1],
+		{Token: "img000002.jpg", Data: []byte(`"dog"`)},
+	}
+
+	got, err := stream("runs/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d records, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if !protobuf.Equal(got[i], want[i]) {
+			t.Errorf("record %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+
+	if got, err := stream("other"); err != nil || len(got) != 0 {
+		t.Errorf("expected no records of an unknown job, got %v, %v", got, err)
+	}
+
+	// a line cut off by a crash is reported rather than dropped
+	f, err := os.OpenFile(filepath.Join(dir, url.PathEscape("runs/1")+".jsonl"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"token":"img0`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, err := stream("runs/1"); err == nil {
+		t.Error("expected a truncated result file to fail the stream")
+	}
+}
+
+func TestAdaptiveBatchSize(t *testing.T) {
+	tests := []struct {
+		name   string
+		worker string
+		// elapsed is how long each lease takes before it is committed
+		elapsed []time.Duration
+		// want is the size of each lease granted
+		want []int
+	}{
+		{
+			// 10 tokens/s, then 15 and 10 as samples of 20 and 5 are
+			// folded in, for leases of 20s
+			name:    "follows measured throughput",
+			worker:  "a",
+			elapsed: []time.Duration{100 * time.Second, 10 * time.Second, 60 * time.Second, 0},
+			want:    []int{1000, 200, 300, 200},
+		},
+		{
+			name:    "never exceeds the requested size",
+			worker:  "a",
+			elapsed: []time.Duration{10 * time.Second, 0},
+			want:    []int{1000, 1000},
+		},
+		{
+			name:    "callers without a worker ID get what they ask for",
+			elapsed: []time.Duration{100 * time.Second, 10 * time.Second, 0},
+			want:    []int{1000, 1000, 1000},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, clock := newTestServer(t, 10000, WithTargetLease(20*time.Second), WithLeaseTimeout(time.Hour))
+			ctx := context.Background()
+			for i, want := range test.want {
+				data, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 1000, Worker: test.worker})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(data.Tokens) != want {
+					t.Errorf("lease %d: expected %d tokens, got %d", i, want, len(data.Tokens))
+				}
+				clock.advance(test.elapsed[i])
+				if _, err := s.Done(ctx, &proto.JobID{ID: "job", Key: data.Key, Worker: test.worker}); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestByteBudget(t *testing.T) {
+	sized := func(sizes ...int64) staticSource {
+		tokens := make(staticSource, len(sizes))
+		for i, size := range sizes {
+			tokens[i] = Token{Name: fmt.Sprintf("img%06d.jpg", i), Size: size}
+		}
+		return tokens
+	}
+
+	tests := []struct {
+		name      string
+		tokens    staticSource
+		batchSize int32
+		budget    int64
+		// want is the number of tokens and bytes of each lease granted
+		want [][2]int64
+	}{
+		{
+			name:   "budget alone",
+			tokens: sized(1, 2, 3, 4, 5),
+			budget: 10,
+			want:   [][2]int64{{4, 10}, {1, 5}},
+		},
+		{
+			name:      "count below the budget",
+			tokens:    sized(1, 2, 3, 4, 5),
+			batchSize: 2,
+			budget:    100,
+			want:      [][2]int64{{2, 3}, {2, 7}, {1, 5}},
+		},
+		{
+			name:      "budget below the count",
+			tokens:    sized(4, 4, 4, 4, 4),
+			batchSize: 4,
+			budget:    9,
+			want:      [][2]int64{{2, 8}, {2, 8}, {1, 4}},
+		},
+		{
+			name:   "oversized token is granted alone",
+			tokens: sized(5, 50, 5, 5),
+			budget: 20,
+			want:   [][2]int64{{1, 5}, {1, 50}, {2, 10}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, _ := newTestServer(t, 0, WithSource(test.tokens))
+			for i, want := range append(test.want, [2]int64{0, 0}) {
+				data, err := s.Get(context.Background(), &proto.JobID{ID: "job", BatchSize: test.batchSize, ByteBudget: test.budget})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := [2]int64{int64(len(data.Tokens)), data.Bytes}; got != want {
+					t.Errorf("lease %d: expected %d tokens of %d bytes, got %d of %d", i, want[0], want[1], got[0], got[1])
+				}
+			}
+		})
+	}
+}
+
+func TestDoneOwnership(t *testing.T) {
+	type done struct {
+		worker string
+		ok     bool
+	}
+	tests := []struct {
+		name string
+		// expire lets the lease of a time out before b asks for tokens
+		expire bool
+		dones  []done
+		// results is how many records are committed in the end
+		results int
+	}{
+		{
+			name:    "holder commits",
+			dones:   []done{{"a", true}},
+			results: 10,
+		},
+		{
+			name:    "other worker is refused",
+			dones:   []done{{"b", false}, {"a", true}},
+			results: 10,
+		},
+		{
+			name:    "retry of holder is acknowledged once",
+			dones:   []done{{"a", true}, {"a", true}},
+			results: 10,
+		},
+		{
+			name:    "retry of another worker is refused",
+			dones:   []done{{"a", true}, {"b", false}},
+			results: 10,
+		},
+		{
+			name:    "previous holder of a reassigned lease is refused",
+			expire:  true,
+			dones:   []done{{"a", false}, {"b", true}, {"a", false}},
+			results: 10,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results := &memResults{}
+			s, clock := newTestServer(t, 10, WithResultStore(results))
+			ctx := context.Background()
+
+			data, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 10, Worker: "a"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.expire {
+				clock.advance(2 * time.Minute)
+				again, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 10, Worker: "b"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if again.Key != data.Key {
+					t.Fatalf("expected lease %s to be reassigned, got %q", data.Key, again.Key)
+				}
+			}
+
+			for i, d := range test.dones {
+				ack, err := s.Done(ctx, &proto.JobID{ID: "job", Key: data.Key, Worker: d.worker, Records: records(data)})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ack.Status != d.ok {
+					t.Errorf("done %d by %s: expected status %v, got %v", i, d.worker, d.ok, ack.Status)
+				}
+			}
+			if n := results.count("job"); n != test.results {
+				t.Errorf("expected %d records, got %d", test.results, n)
+			}
+		})
+	}
+}
+
+func TestDoneRetryNeedsWorker(t *testing.T) {
+	s, _ := newTestServer(t, 10)
+	ctx := context.Background()
+
+	data, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ok := range []bool{true, false} {
+		ack, err := s.Done(ctx, &proto.JobID{ID: "job", Key: data.Key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ack.Status != ok {
+			t.Errorf("done %d: expected status %v, got %v", i, ok, ack.Status)
+		}
+	}
+}
+
+func TestHeartBeatOwnership(t *testing.T) {
+	s, clock := newTestServer(t, 10)
+	ctx := context.Background()
+
+	data, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 10, Worker: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(2 * time.Minute)
+	again, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 10, Worker: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Key != data.Key {
+		t.Fatalf("expected lease %s to be reassigned, got %q", data.Key, again.Key)
+	}
+
+	// a heartbeat of the previous holder must not keep the lease of b alive
+	clock.advance(50 * time.Second)
+	ack, err := s.HeartBeat(ctx, &proto.JobID{ID: "job", Key: data.Key, Worker: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ack.Lost {
+		t.Error("expected heartbeat of the previous holder to report the lease lost")
+	}
+	clock.advance(20 * time.Second)
+	if _, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 10, Worker: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	ack, err = s.Done(ctx, &proto.JobID{ID: "job", Key: data.Key, Worker: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.Status {
+		t.Error("expected lease of b to expire despite the heartbeat of a")
+	}
+
+	ack, err = s.HeartBeat(ctx, &proto.JobID{ID: "job", Key: data.Key, Worker: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.Lost {
+		t.Error("expected heartbeat of the holder to renew the lease")
+	}
+}
+
+func TestRetriedGet(t *testing.T) {
+	s, _ := newTestServer(t, 10)
+	ctx := context.Background()
+	get := func(worker, request string) *proto.Data {
+		data, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 2, Worker: worker, Request: request})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	first := get("a", "r1")
+	if again := get("a", "r1"); again.Key != first.Key {
+		t.Errorf("expected retry to return lease %s, got %s", first.Key, again.Key)
+	}
+	if other := get("a", "r2"); other.Key == first.Key {
+		t.Errorf("expected another request to get another lease, got %s", other.Key)
+	}
+	if other := get("b", "r1"); other.Key == first.Key {
+		t.Errorf("expected another worker to get another lease, got %s", other.Key)
+	}
+
+	if _, err := s.Done(ctx, &proto.JobID{ID: "job", Key: first.Key, Worker: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if again := get("a", "r1"); again.Key == first.Key {
+		t.Errorf("expected retry after the commit to get another lease, got %s", again.Key)
+	}
+}
+
+func TestDrainEndsGet(t *testing.T) {
+	s, _ := newTestServer(t, 10)
+	ctx := context.Background()
+
+	held, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 5, Worker: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Drain(ctx, &proto.Empty{}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 5, Worker: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !data.Draining || len(data.Tokens) != 0 {
+		t.Errorf("expected no tokens while draining, got %v", data)
+	}
+
+	// leases granted before the drain can still be committed
+	ack, err := s.Done(ctx, &proto.JobID{ID: "job", Key: held.Key, Worker: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ack.Status || !ack.Draining {
+		t.Errorf("expected the lease to be committed while draining, got %v", ack)
+	}
+}
+
+func TestShutdownWaitsForLeases(t *testing.T) {
+	s, _ := newTestServer(t, 10)
+	ctx := context.Background()
+
+	held, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 5, Worker: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- s.Shutdown(ctx)
+	}()
+
+	select {
+	case err := <-shutdown:
+		t.Fatalf("expected shutdown to wait for the outstanding lease, got %v", err)
+	case <-time.After(3 * drainPollInterval):
+	}
+
+	// heartbeats and commits are still answered while shutting down
+	ack, err := s.HeartBeat(ctx, &proto.JobID{ID: "job", Key: held.Key, Worker: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.Lost || !ack.Draining {
+		t.Errorf("expected the lease to be renewed while draining, got %v", ack)
+	}
+
+	ack, err = s.Done(ctx, &proto.JobID{ID: "job", Key: held.Key, Worker: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ack.Status {
+		t.Errorf("expected the lease to be committed while shutting down, got %v", ack)
+	}
+	if err := <-shutdown; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	s, _ := newTestServer(t, 10)
+	if _, err := s.Get(context.Background(), &proto.JobID{ID: "job", BatchSize: 5, Worker: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*drainPollInterval)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected shutdown to give up on the outstanding lease, got %v", err)
+	}
+}
+
+// blockingSource signals scanned when Scan is called and then waits for
+// release before returning its tokens
+type blockingSource struct {
+	staticSource
+	scanned, release chan struct{}
+}
+
+func (s blockingSource) Scan() ([]Token, error) {
+	close(s.scanned)
+	<-s.release
+	return s.staticSource.Scan()
+}
+
+func TestHealth(t *testing.T) {
+	source := blockingSource{staticSource: testTokens(10), scanned: make(chan struct{}), release: make(chan struct{})}
+	s := New(WithSource(source), WithClock(newTestClock()), WithLogger(quietLogger()))
+	ctx := context.Background()
+	check := func(want healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		for _, service := range []string{"", proto.ServiceName} {
+			reply, err := s.Health().Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reply.Status != want {
+				t.Errorf("expected %q to be %s, got %s", service, want, reply.Status)
+			}
+		}
+	}
+
+	started := make(chan error, 1)
+	go func() {
+		started <- s.Start()
+	}()
+	<-source.scanned
+	check(healthpb.HealthCheckResponse_NOT_SERVING)
+	if _, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 5}); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable while starting, got %v", err)
+	}
+
+	close(source.release)
+	if err := <-started; err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	check(healthpb.HealthCheckResponse_SERVING)
+
+	held, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 5, Worker: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- s.Shutdown(ctx)
+	}()
+	<-s.stopping
+	check(healthpb.HealthCheckResponse_NOT_SERVING)
+	select {
+	case err := <-shutdown:
+		t.Fatalf("expected shutdown to wait for the outstanding lease, got %v", err)
+	default:
+	}
+
+	if _, err := s.Done(ctx, &proto.JobID{ID: "job", Key: held.Key, Worker: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Error(err)
+	}
+	check(healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+// benchLeases is how many leases stay outstanding while heartbeats and
+// expired leases are timed
+const benchLeases = 10000
+
+// takeLeases takes n leases of a single token each for worker and returns them
+// the way they are heartbeat and committed
+func takeLeases(b *testing.B, s *Server, n int, worker string) []*proto.JobID {
+	keys := make([]*proto.JobID, n)
+	for i := range keys {
+		data, err := s.Get(context.Background(), &proto.JobID{ID: "bench", BatchSize: 1, Worker: worker})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if data.Key == "" {
+			b.Fatalf("no lease granted after %d", i)
+		}
+		keys[i] = &proto.JobID{ID: "bench", Key: data.Key, Worker: worker}
+	}
+	return keys
+}
+
+// parallel calls call with the indexes 0 to b.N-1 from concurrent callers
+func parallel(b *testing.B, call func(i int) error) {
+	var next int64 = -1
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := call(int(atomic.AddInt64(&next, 1))); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	s, _ := newTestServer(b, b.N)
+	parallel(b, func(i int) error {
+		data, err := s.Get(context.Background(), &proto.JobID{ID: "bench", BatchSize: 1, Worker: "bench"})
+		if err == nil && data.Key == "" {
+			err = fmt.Errorf("no lease granted for call %d", i)
+		}
+		return err
+	})
+}
+
+func BenchmarkHeartBeat(b *testing.B) {
+	s, _ := newTestServer(b, benchLeases)
+	keys := takeLeases(b, s, benchLeases, "bench")
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		for pb.Next() {
+			if _, err := s.HeartBeat(context.Background(), keys[r.Intn(len(keys))]); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func BenchmarkDone(b *testing.B) {
+	s, _ := newTestServer(b, b.N)
+	keys := takeLeases(b, s, b.N, "bench")
+	parallel(b, func(i int) error {
+		ack, err := s.Done(context.Background(), keys[i])
+		if err == nil && !ack.Status {
+			err = fmt.Errorf("lease %s was not committed", keys[i].Key)
+		}
+		return err
+	})
+}
+
+// BenchmarkExpiredGet times Get finding the b.N leases that missed their
+// heartbeat among benchLeases that did not
+func BenchmarkExpiredGet(b *testing.B) {
+	s, clock := newTestServer(b, benchLeases+b.N)
+	alive := takeLeases(b, s, benchLeases, "alive")
+	takeLeases(b, s, b.N, "expired")
+	clock.advance(2 * time.Minute)
+	for _, key := range alive {
+		if _, err := s.HeartBeat(context.Background(), key); err != nil {
+			b.Fatal(err)
+		}
+	}
+	parallel(b, func(i int) error {
+		data, err := s.Get(context.Background(), &proto.JobID{ID: "bench", BatchSize: 1, Worker: "bench"})
+		if err == nil && data.Key == "" {
+			err = fmt.Errorf("no expired lease found for call %d", i)
+		}
+		return err
+	})
+}
@@ -0,0 +1,113 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/sdeoras/token/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// cursor points at the next token index that has not been leased yet and
+// at the end of the range it may advance over. In dynamic mode it is the job
+// wide index, in static sharding mode it belongs to a single shard.
+type cursor struct {
+	next *int
+	end  int
+}
+
+// shardBounds returns the half open range of token indexes in shard i
+func (s *Server) shardBounds(i int) (int, int) {
+	return i * s.tokens.len() / s.shards, (i + 1) * s.tokens.len() / s.shards
+}
+
+// shardOf returns the shard that token index ind belongs to
+func (s *Server) shardOf(ind int) int {
+	for i := 0; i < s.shards; i++ {
+		if _, end := s.shardBounds(i); ind < end {
+			return i
+		}
+	}
+	return s.shards - 1
+}
+
+// initShards places each shard cursor at the start of its shard and
+// counts the time until shards may be stolen from now on
+func (s *Server) initShards(data *job) {
+	data.shardIndex = make([]int, s.shards)
+	data.shardSeen = make([]time.Time, s.shards)
+	for i := range data.shardIndex {
+		data.shardIndex[i], _ = s.shardBounds(i)
+		data.shardSeen[i] = s.clock.Now()
+	}
+}
+
+// checkShard makes sure the worker agrees with the server on the sharding mode
+func (s *Server) checkShard(req *proto.JobID) error {
+	if int(req.NumShards) != s.shards {
+		return status.Errorf(codes.InvalidArgument, "server runs with %d shards, worker asked for %d", s.shards, req.NumShards)
+	}
+	if s.shards > 0 && (req.Shard < 0 || int(req.Shard) >= s.shards) {
+		return status.Errorf(codes.InvalidArgument, "shard %d out of range for JobId: %s", req.Shard, req.ID)
+	}
+	return nil
+}
+
+// stealable reports whether shard i has been abandoned by its owner long
+// enough for other workers to take over its tokens
+func (s *Server) stealable(data *job, i int) bool {
+	return s.stealAfter > 0 && s.clock.Now().Sub(data.shardSeen[i]) > s.stealAfter
+}
+
+// nextCursor returns where the next fresh lease for a job comes from. Workers
+// in sharded mode draw from their own shard and, once it is exhausted, from
+// shards whose owner never showed up if stealing is enabled.
+func (s *Server) nextCursor(data *job, req *proto.JobID) cursor {
+	if s.shards == 0 {
+		return cursor{next: &data.currentIndex, end: s.tokens.len()}
+	}
+
+	shard := int(req.Shard)
+	data.shardSeen[shard] = s.clock.Now()
+	_, end := s.shardBounds(shard)
+	if data.shardIndex[shard] < end {
+		return cursor{next: &data.shardIndex[shard], end: end}
+	}
+
+	for i := range data.shardIndex {
+		if _, end := s.shardBounds(i); data.shardIndex[i] < end && s.stealable(data, i) {
+			s.log.WithField("jobID", req.ID).
+				WithField("shard", i).
+				WithField("worker", req.Worker).
+				Info("stealing from shard")
+			return cursor{next: &data.shardIndex[i], end: end}
+		}
+	}
+
+	return cursor{next: &data.shardIndex[shard], end: end}
+}
+
+// mayReassign reports whether an expired lease starting at token index ind
+// can be handed to the requesting worker
+func (s *Server) mayReassign(data *job, req *proto.JobID, ind int) bool {
+	if s.shards == 0 {
+		return true
+	}
+
+	shard := s.shardOf(ind)
+	return shard == int(req.Shard) || s.stealable(data, shard)
+}
+
+// dispatched returns how many tokens of a job have been leased at least once
+func (s *Server) dispatched(data *job) int {
+	if s.shards == 0 || len(data.shardIndex) != s.shards {
+		return data.currentIndex
+	}
+
+	n := 0
+	for i, ind := range data.shardIndex {
+		start, _ := s.shardBounds(i)
+		n += ind - start
+	}
+	return n
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestShardBounds(t *testing.T) {
+	tests := []struct {
+		tokens int
+		shards int
+	}{
+		{10, 1},
+		{10, 3},
+		{10, 10},
+		{3, 5},
+		{1000, 7},
+	}
+	for _, test := range tests {
+		s, _ := newTestServer(t, test.tokens, WithShards(test.shards, 0))
+		next := 0
+		for i := 0; i < test.shards; i++ {
+			start, end := s.shardBounds(i)
+			if start != next {
+				t.Errorf("%d tokens in %d shards: shard %d starts at %d, expected %d", test.tokens, test.shards, i, start, next)
+			}
+			if n := end - start; n < test.tokens/test.shards || n > test.tokens/test.shards+1 {
+				t.Errorf("%d tokens in %d shards: shard %d holds %d", test.tokens, test.shards, i, n)
+			}
+			for ind := start; ind < end; ind++ {
+				if shard := s.shardOf(ind); shard != i {
+					t.Errorf("%d tokens in %d shards: token %d is in shard %d, expected %d", test.tokens, test.shards, ind, shard, i)
+				}
+			}
+			next = end
+		}
+		if next != test.tokens {
+			t.Errorf("%d tokens in %d shards: shards end at %d", test.tokens, test.shards, next)
+		}
+	}
+}
+
+func TestShardStealing(t *testing.T) {
+	tests := []struct {
+		name       string
+		stealAfter time.Duration
+		// want is the first token granted to shard 0 once it is exhausted,
+		// right away and after its neighbour was idle for two minutes
+		want [2]string
+	}{
+		{"disabled", 0, [2]string{"", ""}},
+		{"after the neighbour was idle", time.Minute, [2]string{"", "img000005.jpg"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, clock := newTestServer(t, 10, WithShards(2, test.stealAfter), WithLeaseTimeout(time.Hour))
+			ctx := context.Background()
+			get := func() string {
+				data, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 10, Worker: "a", Shard: 0, NumShards: 2})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(data.Tokens) == 0 {
+					return ""
+				}
+				return data.Tokens[0]
+			}
+
+			if first := get(); first != "img000000.jpg" {
+				t.Fatalf("expected shard 0 to start at its first token, got %q", first)
+			}
+			if got := get(); got != test.want[0] {
+				t.Errorf("expected %q from an exhausted shard, got %q", test.want[0], got)
+			}
+			clock.advance(2 * time.Minute)
+			if got := get(); got != test.want[1] {
+				t.Errorf("expected %q once the other shard was idle, got %q", test.want[1], got)
+			}
+		})
+	}
+}
+
+func TestCheckShard(t *testing.T) {
+	s, _ := newTestServer(t, 10, WithShards(2, 0))
+	tests := []struct {
+		name      string
+		shard     int32
+		numShards int32
+		code      codes.Code
+	}{
+		{"first shard", 0, 2, codes.OK},
+		{"last shard", 1, 2, codes.OK},
+		{"shard past the end", 2, 2, codes.InvalidArgument},
+		{"negative shard", -1, 2, codes.InvalidArgument},
+		{"dynamic worker", 0, 0, codes.InvalidArgument},
+		{"other shard count", 0, 3, codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := s.Get(context.Background(), &proto.JobID{ID: "job", BatchSize: 1, Shard: test.shard, NumShards: test.numShards})
+			if code := status.Code(err); code != test.code {
+				t.Errorf("expected %s, got %v", test.code, err)
+			}
+		})
+	}
+}
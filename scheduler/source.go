@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"io/ioutil"
+)
+
+// Token is a unit of work along with the size of the file backing it
+type Token struct {
+	Name string
+	Size int64
+}
+
+// Source lists the tokens a server hands out
+type Source interface {
+	Scan() ([]Token, error)
+}
+
+type dirSource struct {
+	folder string
+}
+
+// DirSource lists the files in folder, sub folders are skipped
+func DirSource(folder string) Source {
+	return &dirSource{folder: folder}
+}
+
+func (d *dirSource) Scan() ([]Token, error) {
+	files, err := ioutil.ReadDir(d.folder)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]Token, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		tokens = append(tokens, Token{Name: file.Name(), Size: file.Size()})
+	}
+
+	return tokens, nil
+}
+
+func (d *dirSource) String() string {
+	return d.folder
+}
@@ -0,0 +1,338 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StateStore persists a snapshot of server state. Load returns nil and no
+// error when nothing has been saved yet.
+type StateStore interface {
+	Load() (*State, error)
+	Save(*State) error
+}
+
+// State is a snapshot of the token list and job bookkeeping. The token list
+// is kept in the bytes it is held in, see tokenList.
+type State struct {
+	Count int
+	Index []byte
+	Sizes []byte
+	Arena []byte
+	Order []byte `json:",omitempty"`
+	Jobs  map[string]*JobState
+
+	// list holds the bytes above and stays mapped until release
+	list *tokenList
+}
+
+// JobState is the persisted bookkeeping of a single job
+type JobState struct {
+	CurrentIndex  int
+	StartTime     time.Time
+	EndTime       time.Time
+	Completed     bool
+	TotalDuration time.Duration
+	Leases        map[string]LeaseState
+	Recent        []RecentState  `json:",omitempty"`
+	Requests      []RequestState `json:",omitempty"`
+	Finished      []Interval     `json:",omitempty"`
+	Failed        []Interval     `json:",omitempty"`
+	Commits       []CommitState  `json:",omitempty"`
+	ShardIndex    []int
+	ShardSeen     []time.Time
+	Throughput    map[string]float64
+	Imported      string `json:",omitempty"`
+
+	LeasesGranted    int
+	LeasesReassigned int
+	LeasesCompleted  int
+	LeasesExpired    int
+	TokensCompleted  int
+}
+
+// LeaseState is an outstanding lease of Count tokens starting at Start
+type LeaseState struct {
+	Start     int
+	Count     int
+	Worker    string
+	Granted   time.Time
+	Heartbeat time.Time
+}
+
+// CommitState is a run of Count tokens starting at Start that Worker
+// committed, the last of them at Time
+type CommitState struct {
+	Start  int
+	Count  int
+	Worker string
+	Time   time.Time
+}
+
+// RecentState is a key Worker committed at Time, which a retried Done of
+// the key is acknowledged by
+type RecentState struct {
+	Key    string
+	Worker string
+	Time   time.Time
+}
+
+// RequestState is a Get Request granted the lease Key at Time, which a
+// retried Get of the request is answered with
+type RequestState struct {
+	Request string
+	Key     string
+	Time    time.Time
+}
+
+type fileStore struct {
+	path string
+}
+
+// FileStore saves state as JSON in the file at path. Saves go to a
+// temporary file first so that a crash never leaves a partial snapshot.
+func FileStore(path string) StateStore {
+	return &fileStore{path: path}
+}
+
+func (f *fileStore) Load() (*State, error) {
+	b, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := new(State)
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (f *fileStore) Save(state *State) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// snapshot copies job bookkeeping, the caller must hold the lock at least
+// for reading. The token list is not copied, its bytes do not change and
+// stay mapped until the state is released, so that it is encoded without
+// holding any lock.
+func (s *Server) snapshot() *State {
+	state := &State{Jobs: make(map[string]*JobState, len(s.jobs))}
+	if s.tokens != nil {
+		s.tokens.acquire()
+		state.list = s.tokens
+		state.Count = s.tokens.n
+		state.Index = s.tokens.index
+		state.Sizes = s.tokens.sizes
+		state.Arena = s.tokens.arena
+		state.Order = s.tokens.order
+	}
+
+	for id, data := range s.jobs {
+		data.lock.Lock()
+		state.Jobs[id] = snapshotJob(data)
+		data.lock.Unlock()
+	}
+	return state
+}
+
+// snapshotJob copies the bookkeeping of a job, the caller must hold its lock
+func snapshotJob(data *job) *JobState {
+	js := &JobState{
+		CurrentIndex:  data.currentIndex,
+		StartTime:     data.startTime,
+		EndTime:       data.endTime,
+		Completed:     data.completed,
+		TotalDuration: data.totalDuration,
+		Leases:        make(map[string]LeaseState, len(data.leases)),
+		ShardIndex:    append([]int(nil), data.shardIndex...),
+		ShardSeen:     append([]time.Time(nil), data.shardSeen...),
+		Finished:      append([]Interval(nil), data.finished...),
+		Failed:        append([]Interval(nil), data.failed...),
+		Throughput:    make(map[string]float64, len(data.workers)),
+		Imported:      data.imported,
+
+		LeasesGranted:    data.leasesGranted,
+		LeasesReassigned: data.leasesReassigned,
+		LeasesCompleted:  data.leasesCompleted,
+		LeasesExpired:    data.leasesExpired,
+		TokensCompleted:  data.tokensCompleted,
+	}
+	for key, l := range data.leases {
+		js.Leases[key] = LeaseState{
+			Start:     l.start,
+			Count:     l.count,
+			Worker:    l.worker,
+			Granted:   l.granted,
+			Heartbeat: l.heartbeat,
+		}
+	}
+	for _, r := range data.recent.order {
+		js.Recent = append(js.Recent, RecentState{
+			Key:    r.key,
+			Worker: data.recent.values[r.key],
+			Time:   r.time,
+		})
+	}
+	for _, r := range data.requests.order {
+		js.Requests = append(js.Requests, RequestState{
+			Request: r.key,
+			Key:     data.requests.values[r.key],
+			Time:    r.time,
+		})
+	}
+	for _, c := range data.commits {
+		js.Commits = append(js.Commits, CommitState{
+			Start:  c.start,
+			Count:  c.end - c.start,
+			Worker: c.worker,
+			Time:   c.time,
+		})
+	}
+	for worker, t := range data.workers {
+		js.Throughput[worker] = t.rate
+	}
+	return js
+}
+
+// release lets go of the token list of a snapshot once it is encoded
+func (s *Server) release(state *State) {
+	if state.list == nil {
+		return
+	}
+	if err := state.list.release(); err != nil {
+		s.log.WithField("error", err).Error("could not unmap token manifest")
+	}
+}
+
+// restore replaces server state with state, the caller must hold the lock
+func (s *Server) restore(state *State) error {
+	list, err := loadTokenList(state.Count, state.Index, state.Sizes, state.Arena, state.Order)
+	if err != nil {
+		return err
+	}
+	s.setList(list)
+	s.jobs = make(map[string]*job, len(state.Jobs))
+
+	for id, js := range state.Jobs {
+		data := &job{
+			currentIndex:  js.CurrentIndex,
+			startTime:     js.StartTime,
+			endTime:       js.EndTime,
+			completed:     js.Completed,
+			totalDuration: js.TotalDuration,
+			leases:        make(map[string]*lease, len(js.Leases)),
+			finished:      intervals(js.Finished),
+			failed:        intervals(js.Failed),
+			workers:       make(map[string]*throughput),
+			imported:      js.Imported,
+
+			leasesGranted:    js.LeasesGranted,
+			leasesReassigned: js.LeasesReassigned,
+			leasesCompleted:  js.LeasesCompleted,
+			leasesExpired:    js.LeasesExpired,
+			tokensCompleted:  js.TokensCompleted,
+		}
+		for key, l := range js.Leases {
+			data.grant(key, &lease{
+				start:     l.Start,
+				count:     l.Count,
+				worker:    l.Worker,
+				granted:   l.Granted,
+				heartbeat: l.Heartbeat,
+			})
+		}
+		for _, r := range js.Recent {
+			data.recent.add(r.Key, r.Worker, r.Time)
+		}
+		for _, r := range js.Requests {
+			data.requests.add(r.Request, r.Key, r.Time)
+		}
+		for _, c := range js.Commits {
+			data.commits = append(data.commits, commit{
+				start:  c.Start,
+				end:    c.Start + c.Count,
+				worker: c.Worker,
+				time:   c.Time,
+			})
+		}
+		for worker, rate := range js.Throughput {
+			data.workers[worker] = &throughput{rate: rate}
+		}
+		if s.shards > 0 {
+			s.initShards(data)
+			if len(js.ShardIndex) == s.shards {
+				copy(data.shardIndex, js.ShardIndex)
+			}
+			if len(js.ShardSeen) == s.shards {
+				copy(data.shardSeen, js.ShardSeen)
+			}
+		}
+		s.jobs[id] = data
+	}
+	return nil
+}
+
+// load replays state from the state store and reports whether there was any
+func (s *Server) load() (bool, error) {
+	if s.store == nil {
+		return false, nil
+	}
+
+	state, err := s.store.Load()
+	if err != nil || state == nil {
+		return false, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.restore(state); err != nil {
+		return false, err
+	}
+	s.log.WithField("count", s.tokens.len()).
+		WithField("jobs", len(s.jobs)).
+		Info("replayed state")
+	return true, nil
+}
+
+// save writes a snapshot to the state store, if there is one
+func (s *Server) save() error {
+	if s.store == nil {
+		return nil
+	}
+
+	s.lock.RLock()
+	state := s.snapshot()
+	s.lock.RUnlock()
+	defer s.release(state)
+	return s.store.Save(state)
+}
@@ -0,0 +1,146 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+)
+
+// exportAll returns the state of every token of a job
+func exportAll(t *testing.T, s *Server, id string) []*proto.TokenState {
+	var states []*proto.TokenState
+	if err := s.export(&proto.JobID{ID: id}, func(page *proto.TokenStates) error {
+		states = append(states, page.Tokens...)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return states
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest bool
+	}{
+		{"on the heap", false},
+		{"mapped", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := []Option{WithStateStore(FileStore(filepath.Join(dir, "state.json")))}
+			if test.manifest {
+				opts = append(opts, WithManifest(filepath.Join(dir, "manifest")))
+			}
+			s, clock := newTestServer(t, 50, opts...)
+			ctx := context.Background()
+
+			if _, err := s.Shuffle(ctx, &proto.Empty{}); err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for i, worker := range []string{"a", "b", "a"} {
+				data, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 10, Worker: worker, Request: fmt.Sprint(i)})
+				if err != nil {
+					t.Fatal(err)
+				}
+				keys = append(keys, data.Key)
+				clock.advance(time.Second)
+			}
+			for i, worker := range []string{"a", "b"} {
+				if _, err := s.Done(ctx, &proto.JobID{ID: "job", Key: keys[i], Worker: worker}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.save(); err != nil {
+				t.Fatal(err)
+			}
+
+			restored := New(append([]Option{
+				WithSource(testTokens(0)),
+				WithClock(clock),
+				WithLogger(quietLogger()),
+			}, opts...)...)
+			if err := restored.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer restored.Stop()
+
+			want := fmt.Sprint(exportAll(t, s, "job"))
+			if got := fmt.Sprint(exportAll(t, restored, "job")); got != want {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+
+			// a retried Done is still acknowledged after the restore
+			ack, err := restored.Done(ctx, &proto.JobID{ID: "job", Key: keys[1], Worker: "b"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ack.Status {
+				t.Error("expected the retry of a restored commit to be acknowledged")
+			}
+
+			// as is a retried Get of a lease that is still held
+			data, err := restored.Get(ctx, &proto.JobID{ID: "job", BatchSize: 10, Worker: "a", Request: "2"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data.Key != keys[2] {
+				t.Errorf("expected the retry of a restored Get to return lease %s, got %s", keys[2], data.Key)
+			}
+		})
+	}
+}
+
+func TestSnapshotKeepsListMapped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest")
+	s, _ := newTestServer(t, 100, WithManifest(path))
+
+	s.lock.RLock()
+	state := s.snapshot()
+	s.lock.RUnlock()
+
+	// a rescan replaces the list while the snapshot is still to be encoded
+	if err := s.rescan(); err != nil {
+		t.Fatal(err)
+	}
+	list, err := loadTokenList(state.Count, state.Index, state.Sizes, state.Arena, state.Order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTokenList(t, list, testTokens(100))
+	s.release(state)
+}
+
+func TestDoneRetryWindow(t *testing.T) {
+	s, clock := newTestServer(t, 10)
+	ctx := context.Background()
+
+	data, err := s.Get(ctx, &proto.JobID{ID: "job", BatchSize: 5, Worker: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := &proto.JobID{ID: "job", Key: data.Key, Worker: "a"}
+	for i, test := range []struct {
+		wait time.Duration
+		ok   bool
+	}{
+		{0, true},
+		{30 * time.Second, true},
+		{2 * time.Minute, false},
+	} {
+		clock.advance(test.wait)
+		ack, err := s.Done(ctx, done)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ack.Status != test.ok {
+			t.Errorf("done %d: expected status %v, got %v", i, test.ok, ack.Status)
+		}
+	}
+}
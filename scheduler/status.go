@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// JobStatus summarizes the progress of a job
+type JobStatus struct {
+	ID               string        `json:"id"`
+	Tokens           int           `json:"tokens"`
+	Dispatched       int           `json:"dispatched"`
+	TokensCompleted  int           `json:"tokens_completed"`
+	LeasesGranted    int           `json:"leases_granted"`
+	LeasesReassigned int           `json:"leases_reassigned"`
+	LeasesCompleted  int           `json:"leases_completed"`
+	Completed        bool          `json:"completed"`
+	StartTime        time.Time     `json:"start_time"`
+	EndTime          time.Time     `json:"end_time,omitempty"`
+	Duration         time.Duration `json:"duration"`
+	Leases           []LeaseStatus `json:"leases"`
+	// Throughput is completed tokens per second over the last hour in
+	// ThroughputInterval wide samples, oldest first
+	Throughput         []float64     `json:"throughput"`
+	ThroughputInterval time.Duration `json:"throughput_interval"`
+}
+
+// DatasetStatus describes the tokens the server hands out
+type DatasetStatus struct {
+	Source string `json:"source"`
+	Tokens int    `json:"tokens"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// LeaseStatus describes an outstanding lease
+type LeaseStatus struct {
+	Key       string        `json:"key"`
+	Worker    string        `json:"worker"`
+	Count     int           `json:"count"`
+	Age       time.Duration `json:"age"`
+	Heartbeat time.Duration `json:"heartbeat"`
+	Expired   bool          `json:"expired"`
+}
+
+// Dataset describes the token list. The source is named if it implements
+// fmt.Stringer.
+func (s *Server) Dataset() *DatasetStatus {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	out := &DatasetStatus{Tokens: s.tokens.len(), Bytes: s.tokens.bytes()}
+	if name, ok := s.source.(fmt.Stringer); ok {
+		out.Source = name.String()
+	}
+	return out
+}
+
+// Status returns the status of the job with the given ID
+func (s *Server) Status(id string) (*JobStatus, bool) {
+	data, unlock := s.lockJob(id, false)
+	defer unlock()
+
+	if data == nil {
+		return nil, false
+	}
+	return s.jobStatus(id, data), true
+}
+
+// Statuses returns the status of all jobs ordered by start time
+func (s *Server) Statuses() []*JobStatus {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	out := make([]*JobStatus, 0, len(s.jobs))
+	for id, data := range s.jobs {
+		data.lock.Lock()
+		out = append(out, s.jobStatus(id, data))
+		data.lock.Unlock()
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].StartTime.Equal(out[j].StartTime) {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].StartTime.Before(out[j].StartTime)
+	})
+	return out
+}
+
+// jobStatus summarizes data, the caller must hold the server lock for
+// reading and the lock of the job
+func (s *Server) jobStatus(id string, data *job) *JobStatus {
+	now := s.clock.Now()
+	out := &JobStatus{
+		ID:               id,
+		Tokens:           s.tokens.len(),
+		Dispatched:       s.dispatched(data),
+		TokensCompleted:  data.tokensCompleted,
+		LeasesGranted:    data.leasesGranted,
+		LeasesReassigned: data.leasesReassigned,
+		LeasesCompleted:  data.leasesCompleted,
+		Completed:        data.completed,
+		StartTime:        data.startTime,
+		EndTime:          data.endTime,
+		Duration:         data.totalDuration,
+		Leases:           make([]LeaseStatus, 0, len(data.leases)),
+
+		Throughput:         data.history.rates(now),
+		ThroughputInterval: historyBucket,
+	}
+	if !data.completed {
+		out.Duration = now.Sub(data.startTime)
+	}
+
+	for key, l := range data.leases {
+		out.Leases = append(out.Leases, LeaseStatus{
+			Key:       key,
+			Worker:    l.worker,
+			Count:     l.count,
+			Age:       now.Sub(l.granted),
+			Heartbeat: now.Sub(l.heartbeat),
+			Expired:   now.Sub(l.heartbeat) > s.leaseTimeout,
+		})
+	}
+	sort.Slice(out.Leases, func(i, j int) bool {
+		return out.Leases[i].Age > out.Leases[j].Age
+	})
+	return out
+}
+
+// Progress counts the tokens and leases of a job, clients that spread a job
+// across partitions add up the progress of each
+func (s *Server) Progress(ctx context.Context, req *proto.JobID) (*proto.JobProgress, error) {
+	data, unlock := s.lockJob(req.ID, false)
+	defer unlock()
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, status.Error(codes.NotFound, "job id not present")
+	}
+	return &proto.JobProgress{
+		ID:                req.ID,
+		Tokens:            int64(s.tokens.len()),
+		Dispatched:        int64(s.dispatched(data)),
+		TokensCompleted:   int64(data.tokensCompleted),
+		LeasesGranted:     int64(data.leasesGranted),
+		LeasesReassigned:  int64(data.leasesReassigned),
+		LeasesCompleted:   int64(data.leasesCompleted),
+		LeasesOutstanding: int64(len(data.leases)),
+		Completed:         data.completed,
+	}, nil
+}
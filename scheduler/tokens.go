@@ -0,0 +1,333 @@
+package scheduler
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// tokenBlock is how many names share a block, the first name of a block is
+// stored in full and the others only by what differs from the name before
+const tokenBlock = 16
+
+// manifestMagic starts every manifest file
+const manifestMagic = "TOKMAN1\n"
+
+// tokenList holds token names front coded back to back in a single arena,
+// so that millions of names cost little more than their differing suffixes
+// and nothing for the garbage collector to scan. Each entry is the length
+// of the prefix shared with the name before, the length of the rest and the
+// rest. The arena, the offset of each block in it and the sizes are plain
+// bytes so that they can be mapped from a manifest file as well, or saved
+// as they are. None of them change once the list is built, shuffling
+// replaces the order instead of permuting it in place.
+type tokenList struct {
+	n     int
+	index []byte // little endian uint64 arena offset per block
+	sizes []byte // little endian int64 size per token
+	arena []byte
+	order []byte // little endian uint32 position per token once shuffled
+	total int64  // sum of the sizes, added up when the list is built
+
+	// a mapped list stays mapped while snapshots still read it
+	lock   sync.Mutex
+	unmap  func() error
+	users  int
+	closed bool
+}
+
+// newTokenList front codes tokens in the order given
+func newTokenList(tokens []Token) *tokenList {
+	l := &tokenList{
+		n:     len(tokens),
+		index: make([]byte, 0, 8*((len(tokens)+tokenBlock-1)/tokenBlock)),
+		sizes: make([]byte, 8*len(tokens)),
+	}
+
+	var prev string
+	var buf [binary.MaxVarintLen64]byte
+	for i, token := range tokens {
+		shared := 0
+		if i%tokenBlock == 0 {
+			l.index = binary.LittleEndian.AppendUint64(l.index, uint64(len(l.arena)))
+		} else {
+			for shared < len(prev) && shared < len(token.Name) && prev[shared] == token.Name[shared] {
+				shared++
+			}
+		}
+		l.arena = append(l.arena, buf[:binary.PutUvarint(buf[:], uint64(shared))]...)
+		l.arena = append(l.arena, buf[:binary.PutUvarint(buf[:], uint64(len(token.Name)-shared))]...)
+		l.arena = append(l.arena, token.Name[shared:]...)
+		binary.LittleEndian.PutUint64(l.sizes[8*i:], uint64(token.Size))
+		l.total += token.Size
+		prev = token.Name
+	}
+	return l
+}
+
+func (l *tokenList) len() int {
+	if l == nil {
+		return 0
+	}
+	return l.n
+}
+
+// position returns where token i is stored
+func (l *tokenList) position(i int) int {
+	if l.order != nil {
+		return int(binary.LittleEndian.Uint32(l.order[4*i:]))
+	}
+	return i
+}
+
+// decoder walks the arena from the start of a block
+type decoder struct {
+	arena []byte
+	off   int
+	name  []byte
+}
+
+func (d *decoder) next() {
+	shared, n := binary.Uvarint(d.arena[d.off:])
+	d.off += n
+	rest, n := binary.Uvarint(d.arena[d.off:])
+	d.off += n
+	d.name = append(d.name[:shared], d.arena[d.off:d.off+int(rest)]...)
+	d.off += int(rest)
+}
+
+// decoderAt returns a decoder that has just read the name stored at pos
+func (l *tokenList) decoderAt(pos int) *decoder {
+	block := pos / tokenBlock
+	d := &decoder{arena: l.arena, off: int(binary.LittleEndian.Uint64(l.index[8*block:]))}
+	for i := block * tokenBlock; i <= pos; i++ {
+		d.next()
+	}
+	return d
+}
+
+// names returns the names of tokens start to start+count
+func (l *tokenList) names(start, count int) []string {
+	out := make([]string, count)
+	if count == 0 {
+		return out
+	}
+	if l.order != nil {
+		for i := range out {
+			out[i] = string(l.decoderAt(l.position(start + i)).name)
+		}
+		return out
+	}
+
+	// names stored one after the other are decoded in a single pass
+	d := l.decoderAt(start)
+	out[0] = string(d.name)
+	for i := 1; i < count; i++ {
+		d.next()
+		out[i] = string(d.name)
+	}
+	return out
+}
+
+// each calls visit with the index and name of tokens start to end in order,
+// name is only valid during the call
+func (l *tokenList) each(start, end int, visit func(i int, name []byte)) {
+	if start >= end {
+		return
+	}
+	if l.order != nil {
+		for i := start; i < end; i++ {
+			visit(i, l.decoderAt(l.position(i)).name)
+		}
+		return
+	}
+
+	d := l.decoderAt(start)
+	visit(start, d.name)
+	for i := start + 1; i < end; i++ {
+		d.next()
+		visit(i, d.name)
+	}
+}
+
+func (l *tokenList) size(i int) int64 {
+	return int64(binary.LittleEndian.Uint64(l.sizes[8*l.position(i):]))
+}
+
+// bytes returns the total size of all tokens
+func (l *tokenList) bytes() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.total
+}
+
+// sumSizes adds up the sizes of a list that was not built from tokens
+func (l *tokenList) sumSizes() {
+	l.total = 0
+	for i := 0; i < l.n; i++ {
+		l.total += int64(binary.LittleEndian.Uint64(l.sizes[8*i:]))
+	}
+}
+
+// shuffle permutes the tokens without moving them in the arena
+func (l *tokenList) shuffle(r *rand.Rand) {
+	order := make([]uint32, l.n)
+	for i := range order {
+		order[i] = uint32(l.position(i))
+	}
+	for i := range order {
+		j := r.Intn(l.n)
+		order[i], order[j] = order[j], order[i]
+	}
+
+	b := make([]byte, 4*l.n)
+	for i, pos := range order {
+		binary.LittleEndian.PutUint32(b[4*i:], pos)
+	}
+	l.order = b
+}
+
+// tokens lists names and sizes in the current order
+func (l *tokenList) tokens() []Token {
+	names := l.names(0, l.len())
+	out := make([]Token, len(names))
+	for i, name := range names {
+		out[i] = Token{Name: name, Size: l.size(i)}
+	}
+	return out
+}
+
+// acquire keeps the list mapped until release is called
+func (l *tokenList) acquire() {
+	l.lock.Lock()
+	l.users++
+	l.lock.Unlock()
+}
+
+// release undoes acquire and unmaps a closed list once nothing reads it
+func (l *tokenList) release() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.users--
+	return l.unmapIdle()
+}
+
+// close releases the manifest the list is mapped from, if any, as soon as
+// no snapshot reads it
+func (l *tokenList) close() error {
+	if l == nil {
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.closed = true
+	return l.unmapIdle()
+}
+
+// unmapIdle unmaps a closed list nothing reads, the caller must hold the
+// lock of the list
+func (l *tokenList) unmapIdle() error {
+	if !l.closed || l.users > 0 || l.unmap == nil {
+		return nil
+	}
+	unmap := l.unmap
+	l.unmap = nil
+	return unmap()
+}
+
+// loadTokenList returns the list of n tokens held by the parts of another,
+// see tokenList
+func loadTokenList(n int, index, sizes, arena, order []byte) (*tokenList, error) {
+	blocks := (n + tokenBlock - 1) / tokenBlock
+	if len(index) != 8*blocks || len(sizes) != 8*n || (order != nil && len(order) != 4*n) {
+		return nil, errors.New("token list does not hold the tokens it counts")
+	}
+	for i := 0; i < blocks; i++ {
+		if binary.LittleEndian.Uint64(index[8*i:]) >= uint64(len(arena)) {
+			return nil, errors.New("token list block lies outside the arena")
+		}
+	}
+	for i := 0; i < len(order); i += 4 {
+		if binary.LittleEndian.Uint32(order[i:]) >= uint32(n) {
+			return nil, errors.New("token list order names a token it does not hold")
+		}
+	}
+	l := &tokenList{n: n, index: index, sizes: sizes, arena: arena, order: order}
+	l.sumSizes()
+	return l, nil
+}
+
+// writeManifest saves the arena of l to path so that it can be mapped into
+// memory instead of held on the heap. The order of a shuffled list is not
+// saved.
+func writeManifest(path string, l *tokenList) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	header := []byte(manifestMagic)
+	for _, v := range []int{l.n, len(l.index) / 8, len(l.arena)} {
+		header = binary.LittleEndian.AppendUint64(header, uint64(v))
+	}
+	for _, b := range [][]byte{header, l.index, l.sizes, l.arena} {
+		w.Write(b)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// openManifest maps the manifest at path into memory
+func openManifest(path string) (*tokenList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(manifestMagic)+24)
+	if _, err := io.ReadFull(f, header); err != nil || string(header[:len(manifestMagic)]) != manifestMagic {
+		return nil, errors.New("not a token manifest: " + path)
+	}
+	n := int(binary.LittleEndian.Uint64(header[len(manifestMagic):]))
+	blocks := int(binary.LittleEndian.Uint64(header[len(manifestMagic)+8:]))
+	arena := int(binary.LittleEndian.Uint64(header[len(manifestMagic)+16:]))
+	if int64(len(header)+8*blocks+8*n+arena) != info.Size() {
+		return nil, errors.New("token manifest is truncated: " + path)
+	}
+
+	b, unmap, err := mapFile(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+	b = b[len(header):]
+	l := &tokenList{
+		n:     n,
+		index: b[:8*blocks],
+		sizes: b[8*blocks : 8*blocks+8*n],
+		arena: b[8*blocks+8*n:],
+		unmap: unmap,
+	}
+	l.sumSizes()
+	return l, nil
+}
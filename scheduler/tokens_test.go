@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+func TestTokenList(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+	}{
+		{"empty", nil},
+		{"single", []string{"a"}},
+		{"shared prefixes", []string{"img0001.jpg", "img0002.jpg", "img0010.jpg", "img0100.jpg"}},
+		{"prefix of the next", []string{"a", "ab", "abc", "b"}},
+		{"empty name", []string{"", "a", ""}},
+		{"several blocks", testNames(3*tokenBlock + 5)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens := make([]Token, len(test.names))
+			for i, name := range test.names {
+				tokens[i] = Token{Name: name, Size: int64(10 * i)}
+			}
+			l := newTokenList(tokens)
+			checkTokenList(t, l, tokens)
+
+			// any stretch reads the same as the whole list
+			for start := 0; start < len(tokens); start++ {
+				for end := start; end <= len(tokens); end++ {
+					if got := l.names(start, end-start); fmt.Sprint(got) != fmt.Sprint(test.names[start:end]) {
+						t.Fatalf("names(%d, %d): expected %v, got %v", start, end-start, test.names[start:end], got)
+					}
+				}
+			}
+
+			// a manifest maps back to the same list
+			path := filepath.Join(t.TempDir(), "manifest")
+			if err := writeManifest(path, l); err != nil {
+				t.Fatal(err)
+			}
+			mapped, err := openManifest(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mapped.close()
+			checkTokenList(t, mapped, tokens)
+		})
+	}
+}
+
+func TestTokenListShuffle(t *testing.T) {
+	tokens := testTokens(100)
+	l := newTokenList(tokens)
+	r := rand.New(rand.NewSource(1))
+	l.shuffle(r)
+
+	// shuffling again leaves the order a snapshot may be reading alone
+	order := l.order
+	saved := string(order)
+	l.shuffle(r)
+	if string(order) != saved {
+		t.Error("shuffle changed the previous order in place")
+	}
+
+	// the shuffled list holds each token once, with its size
+	seen := make(map[string]bool)
+	l.each(0, l.len(), func(i int, name []byte) {
+		seen[string(name)] = true
+		if want := int64(1 + sscanIndex(t, string(name))); l.size(i) != want {
+			t.Errorf("token %s: expected size %d, got %d", name, want, l.size(i))
+		}
+	})
+	if len(seen) != len(tokens) {
+		t.Errorf("expected %d tokens, got %d", len(tokens), len(seen))
+	}
+
+	// a list loaded from the bytes of another reads the same
+	loaded, err := loadTokenList(l.n, l.index, l.sizes, l.arena, l.order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTokenList(t, loaded, l.tokens())
+	if _, err := loadTokenList(l.n+1, l.index, l.sizes, l.arena, l.order); err == nil {
+		t.Error("expected a list with too few sizes to be refused")
+	}
+}
+
+func testNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("dir/%d/file-%04d", i%3, i)
+	}
+	return names
+}
+
+func sscanIndex(t *testing.T, name string) int {
+	var i int
+	if _, err := fmt.Sscanf(name, "img%06d.jpg", &i); err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+// checkTokenList compares l to tokens one at a time and in a single pass
+func checkTokenList(t *testing.T, l *tokenList, tokens []Token) {
+	t.Helper()
+	if l.len() != len(tokens) {
+		t.Fatalf("expected %d tokens, got %d", len(tokens), l.len())
+	}
+	var total int64
+	for i, token := range tokens {
+		if got := l.names(i, 1)[0]; got != token.Name {
+			t.Errorf("token %d: expected %q, got %q", i, token.Name, got)
+		}
+		if got := l.size(i); got != token.Size {
+			t.Errorf("token %d: expected size %d, got %d", i, token.Size, got)
+		}
+		total += token.Size
+	}
+	if fmt.Sprint(l.tokens()) != fmt.Sprint(tokens) {
+		t.Errorf("expected %v, got %v", tokens, l.tokens())
+	}
+	if l.bytes() != total {
+		t.Errorf("expected %d bytes, got %d", total, l.bytes())
+	}
+}
@@ -2,318 +2,320 @@ package main
 
 import (
 	"context"
-	"errors"
 	"flag"
-	"io/ioutil"
-	"math/rand"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
 	"github.com/sdeoras/token/proto"
+	"github.com/sdeoras/token/raft"
+	"github.com/sdeoras/token/scheduler"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 )
 
-var (
-	Tokens      []string
-	JobData     map[string]*Data
-	Lock        sync.Mutex
-	Folder      string
-	letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
-)
-
-type Data struct {
-	currentIndex  int
-	StartTime     time.Time
-	EndTime       time.Time
-	Completed     bool
-	TotalDuration time.Duration
-	lastHeartbeat map[string]time.Time
-	keyMap        map[string][]int
+// service is a single server or a replica of a highly available one
+type service interface {
+	proto.TokensServer
+	Start() error
+	Shutdown(context.Context) error
+	Stop() error
+	Gateway(*scheduler.Policy) http.Handler
 }
 
-type server struct{}
-
 func main() {
-	rand.Seed(time.Now().UnixNano())
-
 	folder := flag.String("dir", "/tf/images", "folder to scan for files")
 	host := flag.String("host", ":7001", "gRPC host in host:port format")
+	leaseTimeout := flag.Duration("lease-timeout", time.Minute,
+		"reassign leases that have not sent a heartbeat this long")
+	targetLease := flag.Duration("target-lease", 0,
+		"adapt batch sizes so leases take this long, batch size is then an upper bound (0 disables)")
+	shards := flag.Int("shards", 0, "statically split each job into this many shards (0 dispatches dynamically)")
+	stealAfter := flag.Duration("shard-steal-after", 0,
+		"let workers take over shards whose owner has not asked for work this long (0 disables)")
+	resultsDir := flag.String("results-dir", "", "folder to collect worker results in (empty disables)")
+	manifest := flag.String("manifest", "",
+		"file to keep the token list in, mapped into memory instead of held on the heap (empty disables)")
+	stateFile := flag.String("state-file", "", "file to persist job state in across restarts (empty disables)")
+	auditLog := flag.String("audit-log", "",
+		"file to append a JSON line to for every lease, commit and admin call (empty disables)")
+	auditLogMaxSize := flag.Int64("audit-log-max-size", 100<<20,
+		"bytes after which the audit log is rotated (0 never rotates)")
+	auditLogBackups := flag.Int("audit-log-backups", 5, "number of rotated audit logs to keep")
+	keepaliveMinTime := flag.Duration("keepalive-min-time", time.Second*10,
+		"close connections of clients that ping more often than this")
+	tlsCert := flag.String("tls-cert", "", "server certificate, enables TLS")
+	tlsKey := flag.String("tls-key", "", "server key")
+	tlsClientCA := flag.String("tls-client-ca", "", "CA that client certificates have to be signed by, enables mutual TLS")
+	authPolicy := flag.String("auth-policy", "",
+		"JSON file with identities and roles allowed to call the server (empty allows anyone)")
+	gatewayHost := flag.String("gateway-host", "", "serve an HTTP/JSON API under /v1/ at host:port (empty disables)")
+	dashboardHost := flag.String("dashboard-host", "", "serve a read-only web dashboard at host:port (empty disables)")
+	metricsHost := flag.String("metrics-host", "", "serve Prometheus metrics on /metrics at host:port (empty disables)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", time.Second*30,
+		"on SIGTERM or SIGINT wait this long for leases and calls in flight before closing connections")
+	raftPeers := flag.String("raft-peers", "",
+		"comma separated addresses of all replicas including this one, enables high availability (empty disables)")
+	raftID := flag.String("raft-id", "", "address other replicas and clients reach this replica at, one of --raft-peers")
+	raftDir := flag.String("raft-dir", "raft", "folder to keep the replicated log and snapshots in")
+	peerToken := flag.String("peer-token", os.Getenv("TOKEN_PEER_TOKEN"),
+		"bearer token replicas authenticate to each other with, defaults to $TOKEN_PEER_TOKEN")
+	peerCA := flag.String("peer-ca", "", "CA to verify other replicas with, defaults to --tls-client-ca")
+	partitions := flag.String("partitions", "",
+		"comma separated addresses of all servers splitting the dataset, in the order clients list them (empty disables)")
+	partition := flag.String("partition", "", "address of this server, one of --partitions")
+	logLevel := flag.String("log-level", "info", "log level: debug, info, warn or error")
 	flag.Parse()
 
+	level, err := logrus.ParseLevel(*logLevel)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	logrus.SetLevel(level)
+
 	if !strings.Contains(*host, ":") {
 		logrus.Fatal("--host requires a port number")
 	}
 
-	// these are bookkeeping memory structures
-	JobData = make(map[string]*Data)
-	Tokens = make([]string, 0, 0)
-
-	Folder = *folder
-	if err := scanFiles(Folder); err != nil {
-		logrus.Fatal(err)
+	if (*tlsCert == "") != (*tlsKey == "") || (*tlsClientCA != "" && *tlsCert == "") {
+		logrus.Fatal("--tls-cert and --tls-key are required together and by --tls-client-ca")
 	}
 
-	//start grpc server on localhost
-	lis, err := net.Listen("tcp", *host)
-	if err != nil {
-		logrus.Fatal(err)
+	opts := []scheduler.Option{
+		scheduler.WithSource(scheduler.DirSource(*folder)),
+		scheduler.WithLeaseTimeout(*leaseTimeout),
+		scheduler.WithTargetLease(*targetLease),
+		scheduler.WithShards(*shards, *stealAfter),
 	}
-	s := grpc.NewServer()
-	proto.RegisterTokensServer(s, &server{})
-	reflection.Register(s)
-
-	cerr := make(chan error)
-	go func(c chan error) {
-		logrus.Info("starting server")
-		c <- s.Serve(lis)
-	}(cerr)
 
-	go func() {
-		logrus.Info("starting cleaner bot")
-		for {
-			select {
-			case <-time.After(time.Hour):
-				logrus.WithField("count", len(Tokens)).Info("cleanup bot")
-				Lock.Lock()
-				tmp := make(map[string]*Data)
-				for key, val := range JobData {
-					if time.Since(val.StartTime) < time.Hour*24 {
-						tmp[key] = val
-					}
-				}
-				JobData = nil
-				JobData = tmp
-				Lock.Unlock()
-			}
+	if *resultsDir != "" {
+		store, err := scheduler.ResultDir(*resultsDir)
+		if err != nil {
+			logrus.Fatal(err)
 		}
-	}()
-
-	time.Sleep(time.Second * 2)
-
-	// block forever on error
-	logrus.Info("listening on ", *host)
-	logrus.Info("ctrl-c to exit")
-	logrus.Fatal(<-cerr)
-}
-
-func initJobData(id string) *Data {
-	data, present := JobData[id]
-	if !present {
-		JobData[id] = new(Data)
-		data = JobData[id]
-		data.keyMap = make(map[string][]int)
-		data.lastHeartbeat = make(map[string]time.Time)
-		data.StartTime = time.Now()
+		opts = append(opts, scheduler.WithResultStore(store))
 	}
-	return data
-}
-
-func (s *server) Get(ctx context.Context, job *proto.JobID) (*proto.Data, error) {
-	Lock.Lock()
-	defer Lock.Unlock()
-	logrus.WithField("jobID", job.ID).
-		WithField("signal", "get").
-		Info("get request")
-
-	data := initJobData(job.ID)
-	keyMap := data.keyMap
-	ind := data.currentIndex
 
-	newkey := randStringRunes(8) // generate 8 char wide random string
+	if *manifest != "" {
+		opts = append(opts, scheduler.WithManifest(*manifest))
+	}
 
-	batchSize := int(job.BatchSize)
-	if batchSize > len(Tokens)-ind {
-		batchSize = len(Tokens) - ind
+	if *stateFile != "" {
+		opts = append(opts, scheduler.WithStateStore(scheduler.FileStore(*stateFile)))
 	}
 
-	var tokens []string
-	if batchSize > 0 {
-		data.currentIndex += batchSize
-		tokens = make([]string, batchSize)
-		for i := range tokens {
-			tokens[i] = Tokens[ind+i]
+	if *auditLog != "" {
+		audit, err := scheduler.AuditFile(*auditLog, *auditLogMaxSize, *auditLogBackups)
+		if err != nil {
+			logrus.Fatal(err)
 		}
-		keyMap[newkey] = []int{ind, batchSize}
-		data.Completed = false
-		logrus.WithField("key", newkey).
-			WithField("count", len(tokens)).
-			WithField("jobID", job.ID).
-			Info("assigned")
-	} else {
-		// try to assign previously assigned work
-		if len(keyMap) > 0 {
-			for key, value := range keyMap {
-				// check sanity of values
-				if len(value) != 2 {
-					return nil, errors.New("bookkeeping fault for JobId: " + job.ID)
-				}
-				if value[0]+value[1] > len(Tokens) {
-					return nil, errors.New("bookkeeping fault for JobId: " + job.ID)
-				}
-				if value[0] < 0 || value[1] < 0 {
-					return nil, errors.New("bookkeeping fault for JobId: " + job.ID)
-				}
+		opts = append(opts, scheduler.WithAuditLog(audit))
+	}
 
-				if time.Since(data.lastHeartbeat[key]) > time.Minute {
-					tokens = make([]string, value[1])
-					for i := range tokens {
-						tokens[i] = Tokens[value[0]+i]
-					}
-					newkey = key
-					logrus.WithField("key", newkey).
-						WithField("count", len(tokens)).
-						WithField("jobID", job.ID).
-						Info("re-assigned")
-					break
-				}
-			}
-		} else {
-			newkey = ""
-			tokens = nil
-			if len(keyMap) == 0 {
-				logrus.WithField("jobID", job.ID).
-					Info("nothing pending")
+	if *partitions != "" {
+		index := -1
+		names := strings.Split(*partitions, ",")
+		for i, name := range names {
+			if name == *partition {
+				index = i
 			}
 		}
+		if index < 0 {
+			logrus.Fatal("--partition has to be one of --partitions")
+		}
+		opts = append(opts, scheduler.WithPartition(names, index))
 	}
 
-	return &proto.Data{Tokens: tokens, Key: newkey}, nil
-}
-
-func (s *server) Reset(ctx context.Context, empty *proto.Empty) (*proto.Ack, error) {
-	Lock.Lock()
-	defer Lock.Unlock()
-	logrus.WithField("signal", "reset").
-		Info("deleting history")
-
-	JobData = make(map[string]*Data)
-	return &proto.Ack{N: int32(len(Tokens))}, nil
-}
-
-func (s *server) Rescan(ctx context.Context, empty *proto.Empty) (*proto.Ack, error) {
-	if err := scanFiles(Folder); err != nil {
-		return nil, err
+	if *raftPeers != "" && *stateFile != "" {
+		logrus.Fatal("--state-file cannot be used with --raft-peers, replicas keep state in --raft-dir")
 	}
-	logrus.WithField("signal", "rescan").
-		WithField("count", len(Tokens)).
-		Info("scanning folder")
 
-	return &proto.Ack{N: int32(len(Tokens))}, nil
-}
-
-func (s *server) Shuffle(ctx context.Context, empty *proto.Empty) (*proto.Ack, error) {
-	Lock.Lock()
-	defer Lock.Unlock()
+	srv := scheduler.New(opts...)
+	var tokens service = srv
+	var replica *scheduler.Replica
+	if *raftPeers != "" {
+		// replicas dial each other with their server certificate
+		peerDial := proto.DefaultDialConfig()
+		peerDial.Token = *peerToken
+		// a replica that comes back has to hear from the leader before it
+		// stands for election
+		peerDial.Backoff.Max = time.Second
+		if *tlsCert != "" {
+			peerDial.CAFile, peerDial.CertFile, peerDial.KeyFile = *peerCA, *tlsCert, *tlsKey
+			if *peerCA == "" {
+				peerDial.CAFile = *tlsClientCA
+			}
+		}
+		dialOpts, err := peerDial.DialOptions()
+		if err != nil {
+			logrus.Fatal(err)
+		}
 
-	logrus.WithField("signal", "shuffle").
-		WithField("count", len(Tokens)).
-		Info("shuffling tokens")
-	n := len(Tokens)
-	for i := 0; i < len(Tokens); i++ {
-		j := rand.Intn(n)
-		Tokens[i], Tokens[j] = Tokens[j], Tokens[i]
+		replica, err = scheduler.NewReplica(srv, raft.Config{
+			ID:          *raftID,
+			Peers:       strings.Split(*raftPeers, ","),
+			Dir:         *raftDir,
+			DialOptions: dialOpts,
+		})
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		tokens = replica
 	}
-	return &proto.Ack{N: int32(len(Tokens))}, nil
-}
-
-func (s *server) Show(ctx context.Context, empty *proto.Empty) (*proto.Data, error) {
-	Lock.Lock()
-	defer Lock.Unlock()
 
-	logrus.WithField("signal", "show").
-		WithField("count", len(Tokens)).
-		Info("listing tokens")
-	Out := new(proto.Data)
-	Out.Tokens = make([]string, len(Tokens))
-	for i, token := range Tokens {
-		Out.Tokens[i] = token
+	//start grpc server on localhost
+	lis, err := net.Listen("tcp", *host)
+	if err != nil {
+		logrus.Fatal(err)
 	}
+	serverOpts := []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             *keepaliveMinTime,
+			PermitWithoutStream: true,
+		}),
+	}
+	if *tlsCert != "" {
+		creds, err := scheduler.TLSCredentials(*tlsCert, *tlsKey, *tlsClientCA)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(creds))
+	}
+	// latency is measured around authorization so that denied calls count
+	unary := []grpc.UnaryServerInterceptor{srv.UnaryInterceptor()}
+	stream := []grpc.StreamServerInterceptor{srv.StreamInterceptor()}
+	var policy *scheduler.Policy
+	if *authPolicy != "" {
+		policy, err = scheduler.LoadPolicy(*authPolicy)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		// replicas have to be let in to elect a leader
+		if replica != nil && *peerToken != "" {
+			policy.Identities = append(policy.Identities,
+				scheduler.Identity{Name: "peer", Role: scheduler.RolePeer, Token: *peerToken})
+		}
+		unary = append(unary, policy.UnaryInterceptor())
+		stream = append(stream, policy.StreamInterceptor())
+	}
+	serverOpts = append(serverOpts,
+		grpc.UnaryInterceptor(scheduler.ChainUnary(unary...)),
+		grpc.StreamInterceptor(scheduler.ChainStream(stream...)))
+	if replica != nil {
+		// snapshots and rescans carry the whole token list
+		serverOpts = append(serverOpts, grpc.MaxRecvMsgSize(raft.MaxMessageSize))
+	}
+	s := grpc.NewServer(serverOpts...)
+	proto.RegisterTokensServer(s, tokens)
+	if replica != nil {
+		proto.RegisterRaftServer(s, replica)
+	}
+	healthpb.RegisterHealthServer(s, srv.Health())
+	reflection.Register(s)
 
-	return Out, nil
-}
-
-func (s *server) Done(ctx context.Context, key *proto.JobID) (*proto.Ack, error) {
-	Lock.Lock()
-	defer Lock.Unlock()
-
-	logrus.WithField("signal", "done").
-		WithField("jobID", key.ID).WithField("key", key.Key).
-		Info("done")
+	cerr := make(chan error)
+	go func(c chan error) {
+		logrus.Info("starting server")
+		c <- s.Serve(lis)
+	}(cerr)
 
-	if data, present := JobData[key.ID]; !present {
-		logrus.WithField("jobID", key.ID).
-			Info("server could not find job id")
-		return &proto.Ack{}, nil
-	} else {
-		if _, present = data.keyMap[key.Key]; !present {
-			logrus.WithField("jobID", key.ID).
-				WithField("key", key.Key).
-				Info("key not found")
+	// http endpoints that are given the same host share a listener
+	muxes := make(map[string]*http.ServeMux)
+	handle := func(host, pattern string, handler http.Handler) {
+		if _, present := muxes[host]; !present {
+			muxes[host] = http.NewServeMux()
+		}
+		muxes[host].Handle(pattern, handler)
+	}
+	if *metricsHost != "" {
+		handle(*metricsHost, "/metrics", srv.MetricsHandler())
+	}
+	if *gatewayHost != "" {
+		handle(*gatewayHost, "/v1/", tokens.Gateway(policy))
+	}
+	if *dashboardHost != "" {
+		handle(*dashboardHost, "/{$}", srv.Dashboard(policy))
+	}
 
-			return &proto.Ack{}, nil
-		} else {
-			logrus.WithField("jobID", key.ID).
-				WithField("key", key.Key).
-				Info("deleting key")
-			delete(data.keyMap, key.Key)
-			if len(data.keyMap) == 0 {
-				data.Completed = true
-				data.EndTime = time.Now()
-				data.TotalDuration = time.Since(data.StartTime)
-				logrus.WithField("jobID", key.ID).
-					WithField("completed", data.Completed).
-					WithField("duration", data.TotalDuration).Info("done")
+	var httpServers []*http.Server
+	for httpHost, mux := range muxes {
+		hs := &http.Server{Addr: httpHost, Handler: mux}
+		httpServers = append(httpServers, hs)
+		// the gateway and dashboard show tokens and results, so they are
+		// encrypted like grpc
+		if *tlsCert != "" && (httpHost == *gatewayHost || httpHost == *dashboardHost) {
+			hs.TLSConfig, err = scheduler.TLSConfig(*tlsCert, *tlsKey, *tlsClientCA)
+			if err != nil {
+				logrus.Fatal(err)
 			}
-			return &proto.Ack{Status: true}, nil
 		}
+		go func(c chan error, hs *http.Server) {
+			logrus.Info("serving http on ", hs.Addr)
+			if hs.TLSConfig != nil {
+				c <- hs.ListenAndServeTLS("", "")
+				return
+			}
+			c <- hs.ListenAndServe()
+		}(cerr, hs)
 	}
-}
 
-func (s *server) HeartBeat(ctx context.Context, job *proto.JobID) (*proto.Ack, error) {
-	Lock.Lock()
-	defer Lock.Unlock()
-	logrus.WithField("jobID", job.ID).
-		WithField("key", job.Key).
-		WithField("signal", "heartbeat").
-		Info("received heartbeat")
-	data, present := JobData[job.ID]
-	if !present {
-		return &proto.Ack{}, errors.New("job id not present")
-	}
-	data.lastHeartbeat[job.Key] = time.Now()
-	return &proto.Ack{Status: data.Completed}, nil
-}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT)
 
-func randStringRunes(n int) string {
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letterRunes[rand.Intn(len(letterRunes))]
+	// calls are answered with codes.Unavailable and health checks with
+	// NOT_SERVING until tokens have been scanned or replayed
+	logrus.Info("listening on ", *host)
+	if err := tokens.Start(); err != nil {
+		logrus.Fatal(err)
 	}
-	return string(b)
-}
+	logrus.Info("serving")
 
-func scanFiles(folder string) error {
-	Lock.Lock()
-	defer Lock.Unlock()
-	Tokens = make([]string, 0, 0)
-	JobData = make(map[string]*Data)
+	// block until a signal or an error
+	logrus.Info("ctrl-c to exit")
+	select {
+	case err := <-cerr:
+		logrus.Fatal(err)
+	case received := <-sig:
+		logrus.WithField("signal", received).Info("received signal")
+	}
 
-	files, err := ioutil.ReadDir(folder)
-	if err != nil {
-		return err
+	// stop granting leases and keep answering heartbeats and commits until
+	// leases in flight are committed, connections that are still open when
+	// the deadline passes are closed
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := tokens.Shutdown(ctx); err != nil {
+		logrus.WithField("error", err).Warn("shutdown timeout reached with leases outstanding")
+	}
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		logrus.Warn("shutdown timeout reached, closing connections")
+		s.Stop()
 	}
 
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+	httpCtx, httpCancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer httpCancel()
+	for _, hs := range httpServers {
+		if err := hs.Shutdown(httpCtx); err != nil {
+			logrus.WithField("host", hs.Addr).WithField("error", err).Error("could not shut down http server")
 		}
-
-		Tokens = append(Tokens, file.Name())
 	}
 
-	return nil
+	if err := tokens.Stop(); err != nil {
+		logrus.Fatal(err)
+	}
+	logrus.Info("stopped")
 }
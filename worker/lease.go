@@ -0,0 +1,166 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// held is a lease the worker is processing or has prefetched. Its context is
+// cancelled once the lease is lost.
+type held struct {
+	data     *proto.Data
+	ctx      context.Context
+	cancel   context.CancelFunc
+	failures int
+}
+
+// hold registers a lease so that it is covered by heartbeats
+func (w *Worker) hold(data *proto.Data) *held {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &held{data: data, ctx: ctx, cancel: cancel}
+
+	w.lock.Lock()
+	w.held[data.Key] = h
+	w.lock.Unlock()
+	return h
+}
+
+// release stops heart-beating a lease
+func (w *Worker) release(h *held) {
+	w.lock.Lock()
+	delete(w.held, h.data.Key)
+	w.lock.Unlock()
+	h.cancel()
+}
+
+// heartbeats renews all held leases every heartbeat interval until ctx is
+// done. Leases are renewed with a single HeartBeats() call, servers that do
+// not implement it are sent a HeartBeat() per lease instead.
+func (w *Worker) heartbeats(ctx context.Context) {
+	ticker := time.NewTicker(w.heartbeatInterval)
+	defer ticker.Stop()
+
+	batched := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		w.lock.Lock()
+		leases := make([]*held, 0, len(w.held))
+		for _, h := range w.held {
+			leases = append(leases, h)
+		}
+		w.lock.Unlock()
+		if len(leases) == 0 {
+			continue
+		}
+
+		if batched {
+			err := w.renew(ctx, leases)
+			if status.Code(err) != codes.Unimplemented {
+				continue
+			}
+			w.log.WithField("jobID", w.jobID).
+				Warn("server does not batch heartbeats, sending one per lease")
+			batched = false
+		}
+
+		for _, h := range leases {
+			ack, err := w.client.HeartBeat(ctx, &proto.JobID{ID: w.jobID, Key: h.data.Key, Worker: w.worker})
+			if err == nil {
+				w.drain(ack.Draining)
+				if ack.Lost {
+					w.log.WithField("jobID", w.jobID).
+						WithField("key", h.data.Key).
+						Error("server no longer holds lease for this worker")
+					w.release(h)
+					continue
+				}
+			}
+			w.renewed(ctx, h, err == nil, err)
+		}
+	}
+}
+
+// renew sends a single HeartBeats() call covering leases
+func (w *Worker) renew(ctx context.Context, leases []*held) error {
+	req := &proto.Leases{ID: w.jobID, Worker: w.worker, Keys: make([]string, len(leases))}
+	for i, h := range leases {
+		req.Keys[i] = h.data.Key
+	}
+
+	renewal, err := w.client.HeartBeats(ctx, req)
+	if status.Code(err) == codes.Unimplemented {
+		return err
+	}
+	if err != nil {
+		for _, h := range leases {
+			w.renewed(ctx, h, false, err)
+		}
+		return err
+	}
+	w.drain(renewal.Draining)
+
+	alive := make(map[string]bool, len(renewal.Leases))
+	for _, l := range renewal.Leases {
+		alive[l.Key] = l.Status
+	}
+	for _, h := range leases {
+		if !alive[h.data.Key] {
+			w.log.WithField("jobID", w.jobID).
+				WithField("key", h.data.Key).
+				Error("server no longer holds lease for this worker")
+			w.release(h)
+			continue
+		}
+		w.renewed(ctx, h, true, nil)
+	}
+	return nil
+}
+
+// renewed records the outcome of renewing a lease. A lease is given up if
+// the server cannot be reached for too long or rejects the heartbeat.
+func (w *Worker) renewed(ctx context.Context, h *held, ok bool, err error) {
+	if ctx.Err() != nil || h.ctx.Err() != nil {
+		return
+	}
+	if ok {
+		h.failures = 0
+		return
+	}
+
+	h.failures++
+	w.log.WithField("jobID", w.jobID).
+		WithField("key", h.data.Key).
+		WithField("error", err).
+		Warn("heartbeat failed")
+	if h.failures > w.backoff.Retries || !proto.Retryable(err) {
+		w.release(h)
+	}
+}
+
+// drain logs when the server starts or stops draining. Leases already held
+// can still be committed, the next Get tells the worker to stop.
+func (w *Worker) drain(draining bool) {
+	w.lock.Lock()
+	changed := w.draining != draining
+	w.draining = draining
+	w.lock.Unlock()
+	if !changed {
+		return
+	}
+
+	if draining {
+		w.log.WithField("jobID", w.jobID).
+			Warn("server is draining, finishing held leases")
+		return
+	}
+	w.log.WithField("jobID", w.jobID).Info("server stopped draining")
+}
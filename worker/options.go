@@ -0,0 +1,118 @@
+package worker
+
+import (
+	"time"
+
+	"github.com/sdeoras/token/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// Option configures a Worker
+type Option func(*Worker)
+
+// WithBatchSize sets the number of tokens requested per lease, it
+// defaults to 100
+func WithBatchSize(n int) Option {
+	return func(w *Worker) {
+		w.batchSize = n
+	}
+}
+
+// WithByteBudget caps the total size of files per lease
+func WithByteBudget(n int64) Option {
+	return func(w *Worker) {
+		w.byteBudget = n
+	}
+}
+
+// WithNumBatches stops the worker after n leases, by default it runs until
+// the job is out of tokens
+func WithNumBatches(n int) Option {
+	return func(w *Worker) {
+		w.numBatches = n
+	}
+}
+
+// WithShard identifies the worker as shard of numShards for servers that
+// run in static sharding mode
+func WithShard(shard, numShards int) Option {
+	return func(w *Worker) {
+		w.shard = shard
+		w.numShards = numShards
+	}
+}
+
+// WithConcurrency sets the number of goroutines that process tokens of a
+// lease in Run, it defaults to 1
+func WithConcurrency(n int) Option {
+	return func(w *Worker) {
+		if n > 0 {
+			w.concurrency = n
+		}
+	}
+}
+
+// WithLeases sets the number of leases the worker holds at a time, each
+// processed by its own loop. It defaults to 1.
+func WithLeases(n int) Option {
+	return func(w *Worker) {
+		if n > 0 {
+			w.leases = n
+		}
+	}
+}
+
+// WithPrefetch requests the next lease while the current one is still being
+// processed so that workers do not idle between batches
+func WithPrefetch(prefetch bool) Option {
+	return func(w *Worker) {
+		w.prefetch = prefetch
+	}
+}
+
+// WithRetry sets how often a call that failed with a transient error is
+// retried and how long to wait before the first retry, later retries back
+// off exponentially
+func WithRetry(retries int, delay time.Duration) Option {
+	return func(w *Worker) {
+		w.backoff.Retries = retries
+		w.backoff.Initial = delay
+	}
+}
+
+// WithBackoff replaces the retry policy, it defaults to proto.DefaultBackoff
+func WithBackoff(b proto.Backoff) Option {
+	return func(w *Worker) {
+		w.backoff = b
+	}
+}
+
+// WithHeartbeatInterval sets how often leases are renewed, it defaults to
+// 10 seconds and has to be well below the server's lease timeout
+func WithHeartbeatInterval(interval time.Duration) Option {
+	return func(w *Worker) {
+		w.heartbeatInterval = interval
+	}
+}
+
+// WithWorkerID replaces the default worker identity derived from the host
+// name and process id
+func WithWorkerID(id string) Option {
+	return func(w *Worker) {
+		w.worker = id
+	}
+}
+
+// WithCommitHandler calls f with the records of every committed lease
+func WithCommitHandler(f CommitFunc) Option {
+	return func(w *Worker) {
+		w.onCommit = f
+	}
+}
+
+// WithLogger replaces the standard logrus logger
+func WithLogger(log logrus.FieldLogger) Option {
+	return func(w *Worker) {
+		w.log = log
+	}
+}
@@ -0,0 +1,382 @@
+// Package worker runs the client side of the token protocol. It leases
+// batches of tokens from a server, keeps leases alive with heartbeats while a
+// user supplied function processes them, and commits the results with Done().
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// Func processes a single token. The returned bytes become the data of the
+// token's result record, an error marks the token as failed.
+type Func func(ctx context.Context, token string) ([]byte, error)
+
+// BatchFunc processes all tokens of a lease and returns their result records.
+// An error abandons the lease and the worker moves on to the next one, the
+// abandoned lease is handed to another worker once it times out on the server.
+type BatchFunc func(ctx context.Context, tokens []string) ([]*proto.Record, error)
+
+// CommitFunc is called with the records of every batch the server accepted
+type CommitFunc func(records []*proto.Record)
+
+// Worker leases tokens for a single job
+type Worker struct {
+	client            proto.TokensClient
+	jobID             string
+	worker            string
+	batchSize         int
+	byteBudget        int64
+	numBatches        int
+	shard             int
+	numShards         int
+	concurrency       int
+	leases            int
+	prefetch          bool
+	backoff           proto.Backoff
+	heartbeatInterval time.Duration
+	doneTimeout       time.Duration
+	onCommit          CommitFunc
+	log               logrus.FieldLogger
+
+	lock       sync.Mutex
+	held       map[string]*held
+	batches    int
+	exhausted  bool
+	draining   bool
+	commitLock sync.Mutex
+}
+
+// New returns a Worker that leases tokens of job jobID through client
+func New(client proto.TokensClient, jobID string, opts ...Option) *Worker {
+	w := &Worker{
+		client:            client,
+		jobID:             jobID,
+		worker:            proto.WorkerID(),
+		batchSize:         100,
+		concurrency:       1,
+		leases:            1,
+		backoff:           proto.DefaultBackoff(),
+		heartbeatInterval: time.Second * 10,
+		doneTimeout:       time.Minute,
+		log:               logrus.StandardLogger(),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.backoff.OnRetry == nil {
+		w.backoff.OnRetry = w.logRetry
+	}
+	return w
+}
+
+// Run processes tokens one at a time with fn, or with as many goroutines per
+// lease as set by WithConcurrency, until the job runs out of tokens, the
+// batch limit is reached or ctx is cancelled. Cancelling ctx does not abort
+// leases being processed, they are finished and committed first. fn is
+// called concurrently when either concurrency or leases is above one.
+func (w *Worker) Run(ctx context.Context, fn Func) error {
+	return w.RunBatch(ctx, w.batchFunc(fn))
+}
+
+// RunBatch is like Run but hands all tokens of a lease to fn at once
+func (w *Worker) RunBatch(ctx context.Context, fn BatchFunc) error {
+	w.lock.Lock()
+	w.held = make(map[string]*held)
+	w.batches = 0
+	w.exhausted = false
+	w.lock.Unlock()
+
+	// a single heartbeat loop renews every lease this worker holds
+	beatCtx, stopBeating := context.WithCancel(context.Background())
+	var beating sync.WaitGroup
+	beating.Add(1)
+	go func() {
+		defer beating.Done()
+		w.heartbeats(beatCtx)
+	}()
+	defer func() {
+		stopBeating()
+		beating.Wait()
+	}()
+
+	// the first error stops all loops from taking on new leases
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	errs := make(chan error, w.leases)
+	var wg sync.WaitGroup
+	for i := 0; i < w.leases; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.loop(runCtx, fn); err != nil {
+				stop()
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	if ctx.Err() != nil {
+		w.log.WithField("jobID", w.jobID).Info("stopped, no more leases were requested")
+	}
+	return <-errs
+}
+
+// acquired is the outcome of a prefetched Get
+type acquired struct {
+	lease *held
+	err   error
+}
+
+// loop processes leases one after another. With prefetching enabled the
+// next lease is requested while the current one is being processed.
+func (w *Worker) loop(ctx context.Context, fn BatchFunc) error {
+	h, err := w.acquire(ctx)
+	for h != nil && err == nil {
+		var next chan acquired
+		if w.prefetch {
+			next = make(chan acquired, 1)
+			go func() {
+				h, err := w.acquire(ctx)
+				next <- acquired{lease: h, err: err}
+			}()
+		}
+
+		if err := w.process(h, fn); err != nil {
+			if next != nil {
+				if a := <-next; a.lease != nil {
+					w.release(a.lease)
+				}
+			}
+			return err
+		}
+
+		if next != nil {
+			a := <-next
+			h, err = a.lease, a.err
+		} else {
+			h, err = w.acquire(ctx)
+		}
+	}
+	return err
+}
+
+// acquire leases the next batch of tokens. It returns no lease and no error
+// once there is nothing more to do.
+func (w *Worker) acquire(ctx context.Context) (*held, error) {
+	if ctx.Err() != nil {
+		return nil, nil
+	}
+
+	w.lock.Lock()
+	if w.exhausted || (w.numBatches > 0 && w.batches >= w.numBatches) {
+		w.lock.Unlock()
+		return nil, nil
+	}
+	w.batches++
+	w.lock.Unlock()
+
+	w.log.WithField("jobID", w.jobID).
+		WithField("batchSize", w.batchSize).
+		WithField("byteBudget", w.byteBudget).
+		Info("requesting job tokens")
+	data, err := w.get(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(data.Tokens) == 0 {
+		if data.Draining {
+			w.log.WithField("jobID", w.jobID).Info("server is draining, exiting")
+		} else {
+			w.log.WithField("jobID", w.jobID).Info("received no tokens, exiting")
+		}
+		w.lock.Lock()
+		w.exhausted = true
+		w.lock.Unlock()
+		return nil, nil
+	}
+	w.log.WithField("jobID", w.jobID).
+		WithField("key", data.Key).
+		WithField("count", len(data.Tokens)).
+		WithField("bytes", data.Bytes).
+		Info("received job tokens")
+
+	return w.hold(data), nil
+}
+
+// process runs fn on a lease and commits its results. The lease is kept
+// alive by heartbeats until Done returns so that a slow commit does not let
+// it expire and get handed to another worker.
+func (w *Worker) process(h *held, fn BatchFunc) error {
+	defer w.release(h)
+
+	t := time.Now()
+	records, err := fn(h.ctx, h.data.Tokens)
+	if h.ctx.Err() != nil {
+		w.log.WithField("jobID", w.jobID).
+			WithField("key", h.data.Key).
+			Error("lease lost, dropping results")
+		return nil
+	}
+	if err != nil {
+		w.log.WithField("jobID", w.jobID).
+			WithField("key", h.data.Key).
+			WithField("error", err).
+			Error("could not process tokens, abandoning lease")
+		return nil
+	}
+	w.log.WithField("jobID", w.jobID).
+		WithField("key", h.data.Key).
+		WithField("duration", time.Since(t)).
+		Info("processed tokens")
+
+	ack, err := w.done(h.data.Key, records)
+	if err != nil {
+		return err
+	}
+	if !ack.Status {
+		w.log.WithField("jobID", w.jobID).
+			WithField("key", h.data.Key).
+			Info("received not-ok to write signal from server")
+		return nil
+	}
+
+	w.log.WithField("jobID", w.jobID).
+		WithField("key", h.data.Key).
+		Info("received ok to write signal from server")
+	if w.onCommit != nil {
+		w.commitLock.Lock()
+		w.onCommit(records)
+		w.commitLock.Unlock()
+	}
+	return nil
+}
+
+func (w *Worker) get(ctx context.Context) (*proto.Data, error) {
+	req := &proto.JobID{
+		ID:         w.jobID,
+		BatchSize:  int32(w.batchSize),
+		ByteBudget: w.byteBudget,
+		Worker:     w.worker,
+		Shard:      int32(w.shard),
+		NumShards:  int32(w.numShards),
+		// a retry of a Get that reached the server returns the lease
+		// it was granted instead of leaving that lease to expire
+		Request: requestID(),
+	}
+
+	var data *proto.Data
+	err := w.backoff.Retry(ctx, func(ctx context.Context) error {
+		var err error
+		data, err = w.client.Get(ctx, req)
+		return err
+	})
+	return data, err
+}
+
+// requestID returns a random token that identifies a Get across retries
+func requestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// done commits records for key. It does not honor cancellation of the run
+// so that a finished lease is not thrown away on shutdown.
+func (w *Worker) done(key string, records []*proto.Record) (*proto.Ack, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), w.doneTimeout)
+	defer cancel()
+
+	req := &proto.JobID{ID: w.jobID, Key: key, Worker: w.worker, Records: records}
+
+	var ack *proto.Ack
+	err := w.backoff.Retry(ctx, func(ctx context.Context) error {
+		var err error
+		ack, err = w.client.Done(ctx, req)
+		return err
+	})
+	return ack, err
+}
+
+// logRetry logs a retry of a call to the server
+func (w *Worker) logRetry(attempt int, delay time.Duration, err error) {
+	w.log.WithField("jobID", w.jobID).
+		WithField("attempt", attempt).
+		WithField("delay", delay).
+		WithField("error", err).
+		Warn("retrying")
+}
+
+// batchFunc runs fn over the tokens of a lease with w.concurrency goroutines
+func (w *Worker) batchFunc(fn Func) BatchFunc {
+	return func(ctx context.Context, tokens []string) ([]*proto.Record, error) {
+		records := make([]*proto.Record, len(tokens))
+		indexes := make(chan int)
+
+		var wg sync.WaitGroup
+		for i := 0; i < w.concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for ind := range indexes {
+					record := &proto.Record{Token: tokens[ind]}
+					data, err := fn(ctx, tokens[ind])
+					if err != nil {
+						w.log.WithField("token", tokens[ind]).
+							WithField("error", err).
+							Error("token failed")
+						record.Error = err.Error()
+					}
+					record.Data = data
+					records[ind] = record
+				}
+			}()
+		}
+
+		for ind := range tokens {
+			if ctx.Err() != nil {
+				break
+			}
+			indexes <- ind
+		}
+		close(indexes)
+		wg.Wait()
+
+		if ctx.Err() != nil {
+			return nil, errors.New("lease lost while processing tokens")
+		}
+		return records, nil
+	}
+}
+
+// SignalContext returns a context that is cancelled on SIGINT or SIGTERM,
+// so that a worker finishes its current lease before it exits
+func SignalContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-c
+		logrus.WithField("signal", sig).Info("finishing current lease before exiting")
+		cancel()
+	}()
+	return ctx
+}
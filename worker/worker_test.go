@@ -0,0 +1,172 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sdeoras/token/proto"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeServer hands out leases and counts the heartbeats it receives. Calls
+// it does not implement panic through the nil interface.
+type fakeServer struct {
+	proto.TokensClient
+
+	doneDelay time.Duration
+	// batches is how many leases are handed out, one if it is zero
+	batches int
+	// getErrs are returned by the first calls of Get
+	getErrs []error
+	// draining answers Get with Draining once the lease is handed out
+	draining bool
+
+	lock         sync.Mutex
+	leased       int
+	beats        int
+	beatsInDone  int
+	committedKey string
+	committed    []string
+}
+
+func (f *fakeServer) Get(ctx context.Context, in *proto.JobID, opts ...grpc.CallOption) (*proto.Data, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if len(f.getErrs) > 0 {
+		err := f.getErrs[0]
+		f.getErrs = f.getErrs[1:]
+		return nil, err
+	}
+	if f.leased >= f.batches && f.leased > 0 {
+		return &proto.Data{Draining: f.draining}, nil
+	}
+	f.leased++
+	key := "key"
+	if f.leased > 1 {
+		key = fmt.Sprint("key", f.leased)
+	}
+	return &proto.Data{Key: key, Tokens: []string{"a", "b"}}, nil
+}
+
+func (f *fakeServer) HeartBeats(ctx context.Context, in *proto.Leases, opts ...grpc.CallOption) (*proto.Renewal, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.beats++
+	renewal := &proto.Renewal{}
+	for _, key := range in.Keys {
+		renewal.Leases = append(renewal.Leases, &proto.LeaseStatus{Key: key, Status: true})
+	}
+	return renewal, nil
+}
+
+func (f *fakeServer) Done(ctx context.Context, in *proto.JobID, opts ...grpc.CallOption) (*proto.Ack, error) {
+	f.lock.Lock()
+	before := f.beats
+	f.lock.Unlock()
+
+	time.Sleep(f.doneDelay)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.beatsInDone = f.beats - before
+	f.committedKey = in.Key
+	f.committed = append(f.committed, in.Key)
+	return &proto.Ack{Status: true}, nil
+}
+
+func quietLogger() logrus.FieldLogger {
+	log := logrus.New()
+	log.Out = ioutil.Discard
+	return log
+}
+
+func TestHeartbeatsDuringDone(t *testing.T) {
+	f := &fakeServer{doneDelay: 100 * time.Millisecond}
+	w := New(f, "job",
+		WithHeartbeatInterval(10*time.Millisecond),
+		WithLogger(quietLogger()))
+
+	if err := w.Run(context.Background(), func(ctx context.Context, token string) ([]byte, error) {
+		return nil, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.committedKey != "key" {
+		t.Fatalf("expected the lease to be committed, got %q", f.committedKey)
+	}
+	if f.beatsInDone == 0 {
+		t.Error("lease was not renewed while Done was in flight")
+	}
+}
+
+func TestRetryAndDrain(t *testing.T) {
+	f := &fakeServer{
+		getErrs:  []error{status.Error(codes.Unavailable, "restarting"), status.Error(codes.Unavailable, "restarting")},
+		draining: true,
+	}
+
+	var retries []int
+	backoff := proto.Backoff{
+		Retries: 3,
+		Initial: time.Millisecond,
+		OnRetry: func(attempt int, delay time.Duration, err error) {
+			retries = append(retries, attempt)
+		},
+	}
+	w := New(f, "job", WithBackoff(backoff), WithLogger(quietLogger()))
+
+	var processed []string
+	if err := w.Run(context.Background(), func(ctx context.Context, token string) ([]byte, error) {
+		processed = append(processed, token)
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("expected drain to end the run cleanly, got %v", err)
+	}
+	if len(retries) != 2 || retries[0] != 1 || retries[1] != 2 {
+		t.Errorf("expected retries 1 and 2 to be reported, got %v", retries)
+	}
+	if len(processed) != 2 {
+		t.Errorf("expected the lease to be processed, got %v", processed)
+	}
+}
+
+func TestBatchFailureReleasesLease(t *testing.T) {
+	f := &fakeServer{batches: 3}
+	w := New(f, "job", WithLogger(quietLogger()))
+
+	calls := 0
+	if err := w.RunBatch(context.Background(), func(ctx context.Context, tokens []string) ([]*proto.Record, error) {
+		calls++
+		if calls == 2 {
+			return nil, errors.New("model failed to load")
+		}
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("expected a failed batch not to end the run, got %v", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("expected all 3 leases to be processed, got %d", calls)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if fmt.Sprint(f.committed) != "[key key3]" {
+		t.Errorf("expected the failed lease not to be committed, got %v", f.committed)
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if len(w.held) != 0 {
+		t.Errorf("expected the failed lease to be released, %d are held", len(w.held))
+	}
+}